@@ -5,6 +5,7 @@ import (
 	"github.com/caarlos0/env"
 	"log"
 	"metrify/internal/agent"
+	models "metrify/internal/model"
 	"metrify/internal/service"
 	"os"
 )
@@ -16,10 +17,18 @@ type flags struct {
 	ReportInterval int    `env:"REPORT_INTERVAL"`
 	BatchUpdate    bool   `env:"BATCH_UPDATE"`
 	Key            string `env:"KEY"`
+	KeyID          string `env:"KEY_ID"`
+	Token          string `env:"TOKEN"`
 	RateLimit      int    `env:"RATE_LIMIT"`
 	CryptoKey      string `env:"CRYPTO_KEY"`
 	Config         string `env:"CONFIG"`
 	Protocol       string `env:"PROTOCOL"`
+	TLSCA          string `env:"TLS_CA"`
+	TLSCert        string `env:"TLS_CERT"`
+	TLSKey         string `env:"TLS_KEY"`
+	Compress       string `env:"COMPRESS"`
+	Format         string `env:"FORMAT"`
+	CryptoScheme   string `env:"CRYPTO_SCHEME"`
 }
 
 func parseFlags() *flags {
@@ -36,6 +45,17 @@ func parseFlags() *flags {
 		f.ReportInterval = config.ReportInterval
 		f.PollInterval = config.PollInterval
 		f.CryptoKey = config.CryptoKey
+		f.TLSCA = config.TLSCA
+		f.TLSCert = config.TLSCert
+		f.TLSKey = config.TLSKey
+		f.KeyID = config.KeyID
+		f.Token = config.Token
+		f.Compress = config.Compress
+		f.Format = config.Format
+		f.CryptoScheme = config.CryptoScheme
+		if f.Compress == "" {
+			f.Compress = service.EncodingGzip
+		}
 	} else {
 		setDefaults(&f)
 	}
@@ -50,13 +70,47 @@ func parseFlags() *flags {
 	flag.IntVar(&f.ReportInterval, "r", f.ReportInterval, "interval between reports")
 	flag.BoolVar(&f.BatchUpdate, "b", f.BatchUpdate, "send metrics in batches")
 	flag.StringVar(&f.Key, "k", f.Key, "private key to use for authentication")
+	flag.StringVar(&f.Token, "token", f.Token, "API token for server authentication")
+	flag.StringVar(&f.KeyID, "key-id", f.KeyID, "identifier of the signing key in the server key ring")
 	flag.IntVar(&f.RateLimit, "l", f.RateLimit, "rate limit")
 	flag.StringVar(&f.CryptoKey, "crypto-key", f.CryptoKey, "crypto key")
 	flag.StringVar(&f.Config, "config", f.Config, "configuration file")
 	flag.StringVar(&f.Protocol, "protocol", "http", "transport protocol: http or grpc")
+	flag.StringVar(&f.TLSCA, "tls-ca", f.TLSCA, "path to CA bundle to verify the server (enables TLS)")
+	flag.StringVar(&f.TLSCert, "tls-cert", f.TLSCert, "path to client TLS certificate for mTLS")
+	flag.StringVar(&f.TLSKey, "tls-key", f.TLSKey, "path to client TLS private key for mTLS")
+	flag.StringVar(&f.Compress, "compress", f.Compress, "request body compression: gzip, deflate, zstd or none")
+	flag.StringVar(&f.Format, "format", f.Format, "batch body format over http: json or protobuf")
+	flag.StringVar(&f.CryptoScheme, "crypto-scheme", f.CryptoScheme, "http body encryption with crypto key: hybrid or rsa-pkcs1v15 for servers without hybrid support")
 
 	flag.Parse()
 
+	switch f.Compress {
+	case "none":
+		f.Compress = ""
+	case "", service.EncodingGzip, service.EncodingDeflate, service.EncodingZstd:
+	default:
+		log.Fatalf("unknown compression %q (expect gzip|deflate|zstd|none)", f.Compress)
+	}
+
+	switch f.Format {
+	case "", "json":
+		f.Format = models.ContentTypeJSON
+	case "protobuf":
+		f.Format = models.ContentTypeProtobuf
+	default:
+		log.Fatalf("unknown format %q (expect json|protobuf)", f.Format)
+	}
+
+	switch f.CryptoScheme {
+	case "", "hybrid":
+		f.CryptoScheme = service.EncryptionHybrid
+	case "rsa-pkcs1v15":
+		f.CryptoScheme = service.EncryptionRSAPKCS1v15
+	default:
+		log.Fatalf("unknown crypto scheme %q (expect hybrid|rsa-pkcs1v15)", f.CryptoScheme)
+	}
+
 	return &f
 }
 
@@ -86,8 +140,14 @@ func setDefaults(f *flags) {
 	f.ReportInterval = 10
 	f.BatchUpdate = false
 	f.Key = ""
+	f.KeyID = ""
+	f.Token = ""
 	f.RateLimit = 1
 	f.CryptoKey = ""
 	f.Config = ""
 	f.Protocol = "http"
+	f.TLSCA = ""
+	f.TLSCert = ""
+	f.TLSKey = ""
+	f.Compress = service.EncodingGzip
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -41,8 +42,17 @@ func main() {
 		}
 	}
 
+	var tlsConfig *tls.Config
+	if f.TLSCA != "" || f.TLSCert != "" || f.TLSKey != "" {
+		var err error
+		tlsConfig, err = service.ClientTLSConfig(f.TLSCA, f.TLSCert, f.TLSKey)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
 	var client agent.Sender
-	client, err := agent.NewSender(f.Protocol, normalizedHost, logger, f.Key, publicKey)
+	client, err := agent.NewSender(f.Protocol, normalizedHost, logger, f.Key, f.KeyID, f.Token, publicKey, tlsConfig, f.Compress, f.Format, f.CryptoScheme)
 	if err != nil {
 		logger.Fatal(err)
 	}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"github.com/caarlos0/env"
 	"log"
+	"metrify/internal/alert"
 	"metrify/internal/config"
 	"metrify/internal/service"
 	"os"
@@ -12,11 +13,14 @@ import (
 
 type flags struct {
 	RunAddr            string        `env:"ADDRESS"`
+	GRPCAddr           string        `env:"GRPC_ADDRESS"`
 	StoreInterval      int           `env:"STORE_INTERVAL"`
 	FileStorePath      string        `env:"FILE_STORE_PATH"`
 	Restore            bool          `env:"RESTORE"`
 	Dsn                string        `env:"DATABASE_DSN"`
 	Key                string        `env:"KEY"`
+	KeyRingFile        string        `env:"KEY_RING_FILE"`
+	AuthTokensFile     string        `env:"AUTH_TOKENS_FILE"`
 	AuditFile          string        `env:"AUDIT_FILE"`
 	AuditURL           string        `env:"AUDIT_URL"`
 	CPUProfileFile     string        `env:"CPU_PROFILE_FILE"`
@@ -24,7 +28,25 @@ type flags struct {
 	MemProfileFile     string        `env:"MEM_PROFILE_FILE"`
 	CryptoKey          string        `env:"CRYPTO_KEY"`
 	TrustedSubnet      string        `env:"TRUSTED_SUBNET"`
+	TrustedProxies     string        `env:"TRUSTED_PROXIES"`
 	Protocol           string        `env:"PROTOCOL"`
+	TLSCert            string        `env:"TLS_CERT"`
+	TLSKey             string        `env:"TLS_KEY"`
+	TLSClientCA        string        `env:"TLS_CLIENT_CA"`
+	ReplayWindow       time.Duration `env:"REPLAY_WINDOW"`
+	StrictSignature    bool          `env:"STRICT_SIGNATURE"`
+	TenantQuota        int           `env:"TENANT_QUOTA"`
+	TenantQuotas       string        `env:"TENANT_QUOTAS"`
+	MaxBodySize        int64         `env:"MAX_BODY_SIZE"`
+	MaxBatchSize       int           `env:"MAX_BATCH_SIZE"`
+	MaxImportSize      int64         `env:"MAX_IMPORT_SIZE"`
+	RateLimit          float64       `env:"CLIENT_RATE_LIMIT"`
+	RateBurst          int           `env:"CLIENT_RATE_BURST"`
+	ReservedPrefixes   string        `env:"RESERVED_PREFIXES"`
+	HistorySize        int           `env:"HISTORY_SIZE"`
+	AlertRulesFile     string        `env:"ALERT_RULES_FILE"`
+	AlertInterval      time.Duration `env:"ALERT_INTERVAL"`
+	NotifyConfig       string        `env:"NOTIFY_CONFIG"`
 }
 
 func parseFlags() *flags {
@@ -38,10 +60,49 @@ func parseFlags() *flags {
 		}
 
 		f.RunAddr = servConfig.Address
+		f.GRPCAddr = servConfig.GRPCAddress
+		f.Protocol = servConfig.Protocol
 		f.Restore = servConfig.Restore
 		f.FileStorePath = servConfig.StoreFile
 		f.Dsn = servConfig.DatabaseDsn
 		f.CryptoKey = servConfig.CryptoKey
+		f.TrustedSubnet = servConfig.TrustedSubnet
+		f.TrustedProxies = servConfig.TrustedProxies
+		f.KeyRingFile = servConfig.KeyRingFile
+		f.AuthTokensFile = servConfig.AuthTokensFile
+		f.TLSCert = servConfig.TLSCert
+		f.TLSKey = servConfig.TLSKey
+		f.TLSClientCA = servConfig.TLSClientCA
+		f.StrictSignature = servConfig.StrictSignature
+		f.TenantQuota = servConfig.TenantQuota
+		f.TenantQuotas = servConfig.TenantQuotas
+		f.MaxBodySize = servConfig.MaxBodySize
+		f.MaxBatchSize = servConfig.MaxBatchSize
+		f.MaxImportSize = servConfig.MaxImportSize
+		f.RateLimit = servConfig.ClientRateLimit
+		f.RateBurst = servConfig.ClientRateBurst
+		f.ReservedPrefixes = servConfig.ReservedPrefixes
+		f.HistorySize = servConfig.HistorySize
+		f.AlertRulesFile = servConfig.AlertRulesFile
+		f.AlertInterval = alert.DefaultInterval
+		f.NotifyConfig = servConfig.NotifyConfig
+
+		if f.MaxBodySize == 0 {
+			f.MaxBodySize = service.DefaultMaxBodySize
+		}
+		if f.MaxBatchSize == 0 {
+			f.MaxBatchSize = service.DefaultMaxBatchSize
+		}
+		if f.MaxImportSize == 0 {
+			f.MaxImportSize = service.DefaultMaxImportSize
+		}
+		if f.RateBurst == 0 {
+			f.RateBurst = service.DefaultRateBurst
+		}
+		if f.HistorySize == 0 {
+			f.HistorySize = service.DefaultHistorySize
+		}
+		f.ReplayWindow = service.DefaultReplayWindow
 
 		if servConfig.StoreInterval != "" {
 			d, err := time.ParseDuration(servConfig.StoreInterval)
@@ -50,6 +111,22 @@ func parseFlags() *flags {
 			}
 			f.StoreInterval = int(d.Seconds())
 		}
+
+		if servConfig.AlertInterval != "" {
+			d, err := time.ParseDuration(servConfig.AlertInterval)
+			if err != nil {
+				log.Fatalf("invalid alert_interval in servConfig: %v", err)
+			}
+			f.AlertInterval = d
+		}
+
+		if servConfig.ReplayWindow != "" {
+			d, err := time.ParseDuration(servConfig.ReplayWindow)
+			if err != nil {
+				log.Fatalf("invalid replay_window in servConfig: %v", err)
+			}
+			f.ReplayWindow = d
+		}
 	} else {
 		setDefaults(&f)
 	}
@@ -64,17 +141,50 @@ func parseFlags() *flags {
 	flag.BoolVar(&f.Restore, "r", f.Restore, "restore metrics")
 	flag.StringVar(&f.Dsn, "d", f.Dsn, "database connection string")
 	flag.StringVar(&f.Key, "k", f.Key, "key to use for encryption")
+	flag.StringVar(&f.AuthTokensFile, "auth-tokens", f.AuthTokensFile, "path to JSON file with hashed API tokens (enables authentication)")
+	flag.StringVar(&f.KeyRingFile, "key-ring", f.KeyRingFile, "path to JSON file with additional signing keys addressed by X-Key-ID")
 	flag.StringVar(&f.AuditFile, "audit-file", f.AuditFile, "path to audit log file (disables audit if empty)")
 	flag.StringVar(&f.AuditURL, "audit-url", f.AuditURL, "audit receiver URL (POST, disables audit if empty)")
 	flag.StringVar(&f.CPUProfileFile, "cpu-profile-file", f.CPUProfileFile, "path to CPU profile file")
 	flag.DurationVar(&f.CPUProfileDuration, "cpu-profile-duration", f.CPUProfileDuration, "path to CPU profile duration")
 	flag.StringVar(&f.MemProfileFile, "mem-profile-file", f.MemProfileFile, "path to memory profile file")
 	flag.StringVar(&f.CryptoKey, "crypto-key", f.CryptoKey, "crypto key")
-	flag.StringVar(&f.TrustedSubnet, "t", f.CryptoKey, "trusted subnet")
-	flag.StringVar(&f.Protocol, "protocol", "http", "transport protocol: http or grpc")
+	flag.StringVar(&f.TrustedSubnet, "t", f.TrustedSubnet, "trusted subnets, comma separated CIDRs (disables the check if empty)")
+	flag.StringVar(&f.TrustedProxies, "trusted-proxies", f.TrustedProxies, "comma separated CIDRs of proxies whose X-Forwarded-For and X-Real-IP are honored")
+	flag.StringVar(&f.GRPCAddr, "grpc-address", f.GRPCAddr, "address and port to run grpc server")
+	flag.StringVar(&f.Protocol, "protocol", f.Protocol, "servers to run: http, grpc or all")
+	flag.StringVar(&f.TLSCert, "tls-cert", f.TLSCert, "path to TLS certificate (enables TLS for http and grpc)")
+	flag.StringVar(&f.TLSKey, "tls-key", f.TLSKey, "path to TLS private key")
+	flag.StringVar(&f.TLSClientCA, "tls-client-ca", f.TLSClientCA, "path to CA bundle for client certificate verification (enables mTLS)")
+	flag.DurationVar(&f.ReplayWindow, "replay-window", f.ReplayWindow, "allowed clock skew for signed requests")
+	flag.BoolVar(&f.StrictSignature, "strict-signature", f.StrictSignature, "reject unsigned requests (except HTTP GET and HEAD) and signed requests without timestamp and nonce")
+	flag.IntVar(&f.TenantQuota, "tenant-quota", f.TenantQuota, "max number of metrics per tenant (0 - unlimited)")
+	flag.StringVar(&f.TenantQuotas, "tenant-quotas", f.TenantQuotas, "per-tenant quota overrides: tenant=limit,...")
+	flag.Int64Var(&f.MaxBodySize, "max-body-size", f.MaxBodySize, "max request body size in bytes after decompression (0 - unlimited)")
+	flag.IntVar(&f.MaxBatchSize, "max-batch-size", f.MaxBatchSize, "max number of metrics in one batch update (0 - unlimited)")
+	flag.Int64Var(&f.MaxImportSize, "max-import-size", f.MaxImportSize, "max bulk import body size in bytes after decompression (0 - unlimited)")
+	flag.Float64Var(&f.RateLimit, "client-rate-limit", f.RateLimit, "requests per second allowed per client ip and per token (0 - unlimited)")
+	flag.IntVar(&f.RateBurst, "client-rate-burst", f.RateBurst, "burst of requests allowed per client ip and per token above the rate limit")
+	flag.StringVar(&f.ReservedPrefixes, "reserved-prefixes", f.ReservedPrefixes, "comma separated metric name prefixes clients may not write (metrics restored from the snapshot are kept)")
+	flag.IntVar(&f.HistorySize, "history-size", f.HistorySize, "number of recent values per metric kept for the dashboard (0 - disabled)")
+	flag.StringVar(&f.AlertRulesFile, "alert-rules", f.AlertRulesFile, "path to JSON file with alert rules (disables alerting if empty)")
+	flag.DurationVar(&f.AlertInterval, "alert-interval", f.AlertInterval, "how often alert rules are evaluated")
+	flag.StringVar(&f.NotifyConfig, "notify-config", f.NotifyConfig, "path to JSON file with alert notification routes and webhooks (disables notifications if empty)")
 
 	flag.Parse()
 
+	switch f.Protocol {
+	case "":
+		f.Protocol = "all"
+	case "http", "grpc", "all":
+	default:
+		log.Fatalf("unknown protocol %q (expect http|grpc|all)", f.Protocol)
+	}
+
+	if f.GRPCAddr == "" {
+		f.GRPCAddr = ":3200"
+	}
+
 	return &f
 }
 
@@ -100,11 +210,14 @@ func getConfigPath() string {
 
 func setDefaults(f *flags) {
 	f.RunAddr = ":8080"
+	f.GRPCAddr = ":3200"
 	f.StoreInterval = 5
 	f.FileStorePath = "./metrics.json"
 	f.Restore = true
 	f.Dsn = ""
 	f.Key = ""
+	f.KeyRingFile = ""
+	f.AuthTokensFile = ""
 	f.AuditFile = ""
 	f.AuditURL = ""
 	f.CPUProfileFile = ""
@@ -112,5 +225,23 @@ func setDefaults(f *flags) {
 	f.MemProfileFile = ""
 	f.CryptoKey = ""
 	f.TrustedSubnet = ""
-	f.Protocol = "http"
+	f.TrustedProxies = ""
+	f.Protocol = "all"
+	f.TLSCert = ""
+	f.TLSKey = ""
+	f.TLSClientCA = ""
+	f.ReplayWindow = service.DefaultReplayWindow
+	f.StrictSignature = false
+	f.TenantQuota = 0
+	f.TenantQuotas = ""
+	f.MaxBodySize = service.DefaultMaxBodySize
+	f.MaxBatchSize = service.DefaultMaxBatchSize
+	f.MaxImportSize = service.DefaultMaxImportSize
+	f.RateLimit = 0
+	f.RateBurst = service.DefaultRateBurst
+	f.ReservedPrefixes = ""
+	f.HistorySize = service.DefaultHistorySize
+	f.AlertRulesFile = ""
+	f.AlertInterval = alert.DefaultInterval
+	f.NotifyConfig = ""
 }
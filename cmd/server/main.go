@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
 	"encoding/pem"
@@ -15,14 +16,23 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"log"
+	"metrify/internal/alert"
 	"metrify/internal/audit"
+	"metrify/internal/auth"
 	"metrify/internal/handler"
+	models "metrify/internal/model"
+	"metrify/internal/notify"
 	"metrify/internal/pprof"
 	"metrify/internal/proto"
 	"metrify/internal/router"
 	"metrify/internal/rpc"
 	"metrify/internal/service"
+	"metrify/internal/silence"
+	"metrify/internal/validation"
 	"net"
 	"net/http"
 	"net/url"
@@ -44,6 +54,11 @@ var (
 // @description     Metrics collection service API.
 // @BasePath        /
 // @schemes         http
+//
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 "Bearer <token>"; required when the server is started with -auth-tokens.
 func main() {
 	fmt.Printf("version=%s, time=%s\n, commit=%s\n", BuildVersion, BuildTime, BuildCommit)
 
@@ -51,20 +66,67 @@ func main() {
 
 	db := initDB(f.Dsn)
 	ms := service.NewMemStorage(f.FileStorePath, db)
+	quotas, err := service.ParseQuotas(f.TenantQuota, f.TenantQuotas)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ms.SetQuotas(quotas)
+	ms.SetValidator(metricValidator(f))
+	ms.SetHistory(service.NewHistory(f.HistorySize))
+	silences := silence.NewStore()
+	ms.SetSilences(silences)
 	logger := service.NewLogger()
 
 	rootCtx := context.Background()
 	ctx, cancel := signal.NotifyContext(rootCtx, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	defer cancel()
 
+	health := initHealth(db, f)
+
+	var restoreErr error
 	if f.Restore {
 		if err := ms.ReadFromFile(f.FileStorePath); err != nil {
 			log.Printf("could not read from file store: %v", err)
+			// Первый запуск без снимка и отброшенные метрики — не ошибка, битый снимок — ошибка.
+			if err := service.RestoreStatus(err); err != nil {
+				restoreErr = fmt.Errorf("restore from %s: %w", f.FileStorePath, err)
+			}
 		}
 	}
+	health.Set("storage", restoreErr)
+
+	sec, err := initSecurity(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	alerts, err := initAlerts(ms, logger, f)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	notifier, err := initNotifier(alerts, logger, f)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if notifier != nil {
+		notifier.Silences = silences
+	}
 
 	g, ctx := errgroup.WithContext(ctx)
 
+	if alerts != nil {
+		g.Go(func() error {
+			return alerts.Run(ctx, f.AlertInterval)
+		})
+	}
+
+	if notifier != nil {
+		g.Go(func() error {
+			return notifier.Run(ctx, func() []models.Alert { return alerts.Alerts("", "") }, notify.DefaultTick)
+		})
+	}
+
 	g.Go(func() error {
 		return runMetricDumper(ctx, ms, f)
 	})
@@ -74,31 +136,24 @@ func main() {
 		return nil
 	})
 
-	g.Go(func() error {
-		if f.Protocol == "http" {
-			return runHTTPServer(ctx, ms, db, logger, f)
-		} else {
-			interceptor, err := rpc.NewTrustedSubnetInterceptor(f.TrustedSubnet)
-			if err != nil {
-				logger.Error(err)
-			}
-
-			grpcServer := grpc.NewServer(
-				grpc.UnaryInterceptor(interceptor),
-			)
-
-			proto.RegisterMetricsServer(grpcServer, rpc.NewMetricsService(ms))
+	if f.Protocol != "grpc" {
+		g.Go(func() error {
+			return runHTTPServer(ctx, ms, db, logger, sec, health, alerts, silences, f)
+		})
+	}
 
-			return err
-		}
-	})
+	if f.Protocol != "http" {
+		g.Go(func() error {
+			return runGRPCServer(ctx, ms, logger, sec, health, alerts, f)
+		})
+	}
 
 	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
 		log.Fatal(err)
 	}
 }
 
-func runHTTPServer(ctx context.Context, ms *service.MemStorage, db *sql.DB, logger *zap.SugaredLogger, f *flags) error {
+func runHTTPServer(ctx context.Context, ms *service.MemStorage, db *sql.DB, logger *zap.SugaredLogger, sec *security, health *service.Health, alerts *alert.Engine, silences *silence.Store, f *flags) error {
 	f.RunAddr = normalizeAddr(f.RunAddr)
 	fmt.Println("Running server on", f.RunAddr)
 
@@ -121,12 +176,24 @@ func runHTTPServer(ctx context.Context, ms *service.MemStorage, db *sql.DB, logg
 		f.StoreInterval == 0,
 		f.Key,
 		privKey,
-		f.TrustedSubnet,
+		sec.subnet,
 	)
+	h.Keys = sec.keys
+	h.Auth = sec.auth
+	h.Replay = service.NewReplayGuard(f.ReplayWindow, f.StrictSignature)
+	h.MaxBodySize = f.MaxBodySize
+	h.MaxBatchSize = f.MaxBatchSize
+	h.MaxImportSize = f.MaxImportSize
+	h.Limiter = sec.limiter
+	h.Validator = metricValidator(f)
+	h.Health = health
+	h.Alerts = alerts
+	h.Silences = silences
 
 	srv := &http.Server{
-		Addr:    f.RunAddr,
-		Handler: router.Metric(h),
+		Addr:      f.RunAddr,
+		Handler:   router.Metric(h),
+		TLSConfig: sec.tls,
 	}
 
 	shutdownErr := make(chan error, 1)
@@ -139,7 +206,12 @@ func runHTTPServer(ctx context.Context, ms *service.MemStorage, db *sql.DB, logg
 		shutdownErr <- srv.Shutdown(ctxTimeout)
 	}()
 
-	err := srv.ListenAndServe()
+	var err error
+	if sec.tls != nil {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
 	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
@@ -152,6 +224,120 @@ func runHTTPServer(ctx context.Context, ms *service.MemStorage, db *sql.DB, logg
 	}
 }
 
+func runGRPCServer(ctx context.Context, ms *service.MemStorage, logger *zap.SugaredLogger, sec *security, health *service.Health, alerts *alert.Engine, f *flags) error {
+	if f.CryptoKey != "" {
+		privKey, err := readPrivateKeyFromFile(f.CryptoKey)
+		if err != nil {
+			return err
+		}
+		rpc.RegisterEncryptionCodec(privKey)
+	}
+
+	replay := service.NewReplayGuard(f.ReplayWindow, f.StrictSignature)
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(
+			rpc.NewTrustedSubnetInterceptor(sec.subnet),
+			rpc.NewPeerRateLimitInterceptor(sec.limiter, sec.subnet),
+			rpc.NewAuthInterceptor(sec.auth),
+			rpc.NewTenantInterceptor(),
+			rpc.NewRateLimitInterceptor(sec.limiter),
+			rpc.NewHashInterceptor(sec.keys, replay),
+			rpc.NewResponseSigningInterceptor(sec.keys),
+		),
+		grpc.ChainStreamInterceptor(
+			rpc.NewTrustedSubnetStreamInterceptor(sec.subnet),
+			rpc.NewPeerRateLimitStreamInterceptor(sec.limiter, sec.subnet),
+			rpc.NewAuthStreamInterceptor(sec.auth),
+			rpc.NewTenantStreamInterceptor(),
+			rpc.NewRateLimitStreamInterceptor(sec.limiter),
+			rpc.NewHashStreamInterceptor(sec.keys, replay),
+		),
+	}
+
+	if f.MaxBodySize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(f.MaxBodySize)))
+	}
+
+	if sec.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(sec.tls)))
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+
+	metricsService := rpc.NewMetricsService(ms)
+	metricsService.MaxBatchSize = f.MaxBatchSize
+	metricsService.Validator = metricValidator(f)
+	metricsService.Alerts = alerts
+	proto.RegisterMetricsServer(grpcServer, metricsService)
+
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	fmt.Println("Running grpc server on", f.GRPCAddr)
+
+	lis, err := net.Listen("tcp", f.GRPCAddr)
+	if err != nil {
+		health.Set("grpc", err)
+		return err
+	}
+	health.Set("grpc", nil)
+
+	go rpc.WatchHealth(ctx, healthServer, health, rpc.DefaultHealthInterval)
+
+	return rpc.ServeGRPC(ctx, lis, grpcServer, logger)
+}
+
+// initHealth регистрирует проверки /readyz и grpc.health.v1. Хранилище и
+// gRPC-слушатель не готовы, пока main не отметит их.
+func initHealth(db *sql.DB, f *flags) *service.Health {
+	health := service.NewHealth()
+	health.Set("storage", service.ErrNotReady)
+
+	if db != nil {
+		health.Add("database", db.PingContext)
+	}
+	if f.FileStorePath != "" {
+		health.Add("file_store", service.FileWritable(f.FileStorePath))
+	}
+	if f.Protocol != "http" {
+		health.Set("grpc", service.ErrNotReady)
+	}
+
+	return health
+}
+
+// initAlerts загружает правила алертов; без файла правил алертинг выключен.
+func initAlerts(ms *service.MemStorage, logger *zap.SugaredLogger, f *flags) (*alert.Engine, error) {
+	if f.AlertRulesFile == "" {
+		return nil, nil
+	}
+
+	rules, err := alert.LoadRules(f.AlertRulesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return alert.NewEngine(ms, rules, logger)
+}
+
+// initNotifier загружает маршруты уведомлений; без файла уведомления не отправляются.
+func initNotifier(alerts *alert.Engine, logger *zap.SugaredLogger, f *flags) (*notify.Notifier, error) {
+	if f.NotifyConfig == "" {
+		return nil, nil
+	}
+	if alerts == nil {
+		return nil, errors.New("notify config requires alert rules (ALERT_RULES_FILE)")
+	}
+
+	cfg, err := notify.LoadConfig(f.NotifyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return notify.New(cfg, logger)
+}
+
 func runMetricDumper(ctx context.Context, ms *service.MemStorage, f *flags) error {
 	if f.StoreInterval <= 0 {
 		return nil
@@ -262,6 +448,61 @@ func initAuditPublisher(f *flags) *audit.Publisher {
 	return p
 }
 
+// security — общие для HTTP и gRPC настройки защиты.
+type security struct {
+	tls    *tls.Config
+	keys   *service.KeyRing
+	auth   *auth.Authenticator
+	subnet *service.TrustedSubnet
+	// limiter общий для HTTP и gRPC, чтобы клиент не получал двойной лимит.
+	limiter *service.RateLimiter
+}
+
+func initSecurity(f *flags) (*security, error) {
+	tlsConfig, err := initTLS(f)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := service.NewKeyRing(f.KeyRingFile, f.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	subnet, err := service.NewTrustedSubnet(f.TrustedSubnet, f.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	sec := &security{
+		tls:     tlsConfig,
+		keys:    keys,
+		subnet:  subnet,
+		limiter: service.NewRateLimiter(f.RateLimit, f.RateBurst),
+	}
+
+	if f.AuthTokensFile != "" {
+		sec.auth, err = auth.NewAuthenticator(f.AuthTokensFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sec, nil
+}
+
+func metricValidator(f *flags) *validation.Validator {
+	return validation.New(strings.Split(f.ReservedPrefixes, ",")...)
+}
+
+func initTLS(f *flags) (*tls.Config, error) {
+	if f.TLSCert == "" && f.TLSKey == "" {
+		return nil, nil
+	}
+
+	return service.ServerTLSConfig(f.TLSCert, f.TLSKey, f.TLSClientCA)
+}
+
 func readPrivateKeyFromFile(filepath string) (*rsa.PrivateKey, error) {
 	keyBytes, err := os.ReadFile(filepath)
 	if err != nil {
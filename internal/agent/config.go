@@ -5,4 +5,12 @@ type Config struct {
 	ReportInterval int    `json:"report_interval"`
 	PollInterval   int    `json:"poll_interval"`
 	CryptoKey      string `json:"crypto_key"`
+	TLSCA          string `json:"tls_ca"`
+	TLSCert        string `json:"tls_cert"`
+	TLSKey         string `json:"tls_key"`
+	KeyID          string `json:"key_id"`
+	Token          string `json:"token"`
+	Compress       string `json:"compress"`
+	Format         string `json:"format"`
+	CryptoScheme   string `json:"crypto_scheme"`
 }
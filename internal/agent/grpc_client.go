@@ -3,15 +3,20 @@ package agent
 import (
 	"context"
 	"crypto/rsa"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	models "metrify/internal/model"
 	"metrify/internal/proto"
+	"metrify/internal/rpc"
+	"metrify/internal/service"
 )
 
 // generate:reset
@@ -19,6 +24,8 @@ type GRPCClient struct {
 	logger    *zap.SugaredLogger
 	host      string
 	hashKey   string
+	keyID     string
+	token     string
 	maxRetry  int
 	publicKey *rsa.PublicKey
 
@@ -26,11 +33,8 @@ type GRPCClient struct {
 	client proto.MetricsClient
 }
 
-func NewGRPCClient(host string, logger *zap.SugaredLogger, hashKey string, publicKey *rsa.PublicKey) *GRPCClient {
-	conn, err := grpc.NewClient(
-		host,
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+func NewGRPCClient(host string, logger *zap.SugaredLogger, hashKey, keyID, token string, publicKey *rsa.PublicKey, tlsConfig *tls.Config) *GRPCClient {
+	conn, err := grpc.NewClient(host, grpcDialOptions(hashKey, keyID, token, publicKey, tlsConfig)...)
 	if err != nil {
 		logger.Fatalw("failed to dial grpc server", "host", host, "error", err)
 	}
@@ -39,6 +43,8 @@ func NewGRPCClient(host string, logger *zap.SugaredLogger, hashKey string, publi
 		logger:    logger,
 		host:      host,
 		hashKey:   hashKey,
+		keyID:     keyID,
+		token:     token,
 		maxRetry:  3,
 		publicKey: publicKey,
 		conn:      conn,
@@ -46,6 +52,34 @@ func NewGRPCClient(host string, logger *zap.SugaredLogger, hashKey string, publi
 	}
 }
 
+func grpcDialOptions(hashKey, keyID, token string, publicKey *rsa.PublicKey, tlsConfig *tls.Config) []grpc.DialOption {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
+	}
+
+	if token != "" {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(rpc.NewTokenClientInterceptor(token)))
+	}
+
+	if hashKey != "" {
+		opts = append(opts,
+			grpc.WithChainUnaryInterceptor(rpc.NewSigningClientInterceptor(keyID, hashKey)),
+			grpc.WithChainStreamInterceptor(rpc.NewSigningStreamClientInterceptor(keyID, hashKey)),
+		)
+	}
+
+	if publicKey != nil {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.ForceCodec(rpc.NewEncryptionCodec(service.EncryptionHybrid, publicKey, nil))))
+	}
+
+	return opts
+}
+
 func (client *GRPCClient) Close() error {
 	if client.conn == nil {
 		return nil
@@ -62,7 +96,7 @@ func (client *GRPCClient) UpdateMetrics(metrics []models.Metrics) error {
 	protoMetrics := make([]*proto.Metric, 0, len(metrics))
 
 	for _, metric := range metrics {
-		protoMetric, err := transformMetricToProto(metric)
+		protoMetric, err := proto.FromModel(metric)
 		if err != nil {
 			return err
 		}
@@ -78,11 +112,21 @@ func (client *GRPCClient) UpdateMetrics(metrics []models.Metrics) error {
 
 	ctx = client.withRealIP(ctx)
 
-	_, err := client.client.UpdateMetrics(ctx, req)
+	resp, err := client.client.UpdateMetrics(ctx, req)
 	if err != nil {
 		return fmt.Errorf("grpc update metrics failed: %w", err)
 	}
 
+	var errs []error
+	for _, result := range resp.GetResults() {
+		if !result.GetOk() {
+			errs = append(errs, fmt.Errorf("metric %d %q: %s", result.GetIndex(), result.GetId(), result.GetError()))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("grpc update metrics rejected %d of %d metrics: %w", len(errs), len(metrics), errors.Join(errs...))
+	}
+
 	return nil
 }
 
@@ -99,33 +143,3 @@ func (client *GRPCClient) withRealIP(ctx context.Context) context.Context {
 
 	return metadata.AppendToOutgoingContext(ctx, "x-real-ip", ip)
 }
-
-func transformMetricToProto(metric models.Metrics) (*proto.Metric, error) {
-	m := &proto.Metric{}
-	m.SetId(metric.ID)
-
-	switch metric.MType {
-	case models.Gauge:
-		if metric.Value == nil {
-			return nil, fmt.Errorf("gauge metric %q has nil value", metric.ID)
-		}
-
-		m.SetType(proto.Metric_GAUGE)
-		m.SetValue(*metric.Value)
-
-		return m, nil
-
-	case models.Counter:
-		if metric.Delta == nil {
-			return nil, fmt.Errorf("counter metric %q has nil delta", metric.ID)
-		}
-
-		m.SetType(proto.Metric_COUNTER)
-		m.SetDelta(*metric.Delta)
-
-		return m, nil
-
-	default:
-		return nil, fmt.Errorf("unknown metric type %q", metric.MType)
-	}
-}
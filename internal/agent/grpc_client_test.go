@@ -2,21 +2,32 @@ package agent
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
 	"errors"
 	"net"
+	"strings"
 	"testing"
+	"time"
 
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"metrify/internal/auth"
 	models "metrify/internal/model"
 	"metrify/internal/proto"
+	"metrify/internal/rpc"
+	"metrify/internal/service"
 )
 
 type metricsClientMock struct {
 	lastReq *proto.UpdateMetricsRequest
 	lastMD  metadata.MD
 	err     error
+	results []*proto.MetricResult
 }
 
 func (m *metricsClientMock) UpdateMetrics(
@@ -35,7 +46,34 @@ func (m *metricsClientMock) UpdateMetrics(
 		return nil, m.err
 	}
 
-	return &proto.UpdateMetricsResponse{}, nil
+	resp := &proto.UpdateMetricsResponse{}
+	resp.SetResults(m.results)
+
+	return resp, nil
+}
+
+func (m *metricsClientMock) WatchMetrics(
+	context.Context,
+	*proto.WatchMetricsRequest,
+	...grpc.CallOption,
+) (grpc.ServerStreamingClient[proto.Metric], error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *metricsClientMock) Query(
+	context.Context,
+	*proto.QueryRequest,
+	...grpc.CallOption,
+) (*proto.QueryResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *metricsClientMock) ListAlerts(
+	context.Context,
+	*proto.ListAlertsRequest,
+	...grpc.CallOption,
+) (*proto.ListAlertsResponse, error) {
+	return nil, errors.New("not implemented")
 }
 
 func newTestGRPCClient(mock proto.MetricsClient) *GRPCClient {
@@ -53,97 +91,6 @@ func TestGRPCClient_Close_NilConn(t *testing.T) {
 	}
 }
 
-func TestTransformMetricToProto_Gauge(t *testing.T) {
-	v := 12.5
-
-	got, err := transformMetricToProto(models.Metrics{
-		ID:    "Alloc",
-		MType: models.Gauge,
-		Value: &v,
-	})
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	if got.GetId() != "Alloc" {
-		t.Fatalf("got id %q, want Alloc", got.GetId())
-	}
-	if got.GetType() != proto.Metric_GAUGE {
-		t.Fatalf("got type %v, want %v", got.GetType(), proto.Metric_GAUGE)
-	}
-	if got.GetValue() != 12.5 {
-		t.Fatalf("got value %v, want 12.5", got.GetValue())
-	}
-}
-
-func TestTransformMetricToProto_Counter(t *testing.T) {
-	d := int64(7)
-
-	got, err := transformMetricToProto(models.Metrics{
-		ID:    "PollCount",
-		MType: models.Counter,
-		Delta: &d,
-	})
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	if got.GetId() != "PollCount" {
-		t.Fatalf("got id %q, want PollCount", got.GetId())
-	}
-	if got.GetType() != proto.Metric_COUNTER {
-		t.Fatalf("got type %v, want %v", got.GetType(), proto.Metric_COUNTER)
-	}
-	if got.GetDelta() != 7 {
-		t.Fatalf("got delta %v, want 7", got.GetDelta())
-	}
-}
-
-func TestTransformMetricToProto_GaugeNilValue(t *testing.T) {
-	_, err := transformMetricToProto(models.Metrics{
-		ID:    "Alloc",
-		MType: models.Gauge,
-	})
-	if err == nil {
-		t.Fatal("expected error")
-	}
-
-	want := `gauge metric "Alloc" has nil value`
-	if err.Error() != want {
-		t.Fatalf("got error %q, want %q", err.Error(), want)
-	}
-}
-
-func TestTransformMetricToProto_CounterNilDelta(t *testing.T) {
-	_, err := transformMetricToProto(models.Metrics{
-		ID:    "PollCount",
-		MType: models.Counter,
-	})
-	if err == nil {
-		t.Fatal("expected error")
-	}
-
-	want := `counter metric "PollCount" has nil delta`
-	if err.Error() != want {
-		t.Fatalf("got error %q, want %q", err.Error(), want)
-	}
-}
-
-func TestTransformMetricToProto_UnknownType(t *testing.T) {
-	_, err := transformMetricToProto(models.Metrics{
-		ID:    "Broken",
-		MType: "unknown",
-	})
-	if err == nil {
-		t.Fatal("expected error")
-	}
-
-	want := `unknown metric type "unknown"`
-	if err.Error() != want {
-		t.Fatalf("got error %q, want %q", err.Error(), want)
-	}
-}
-
 func TestGRPCClient_UpdateMetric(t *testing.T) {
 	mock := &metricsClientMock{}
 	client := newTestGRPCClient(mock)
@@ -263,6 +210,22 @@ func TestGRPCClient_UpdateMetrics_ClientError(t *testing.T) {
 	}
 }
 
+func TestGRPCClient_UpdateMetrics_RejectedMetric(t *testing.T) {
+	rejected := &proto.MetricResult{}
+	rejected.SetIndex(0)
+	rejected.SetId("Alloc")
+	rejected.SetError("metric quota exceeded")
+
+	mock := &metricsClientMock{results: []*proto.MetricResult{rejected}}
+	client := &GRPCClient{client: mock, logger: zap.NewNop().Sugar()}
+
+	v := 1.0
+	err := client.UpdateMetrics([]models.Metrics{{ID: "Alloc", MType: models.Gauge, Value: &v}})
+	if err == nil || !strings.Contains(err.Error(), "metric quota exceeded") {
+		t.Fatalf("expected rejected metric error, got %v", err)
+	}
+}
+
 func TestGRPCClient_WithRealIP_ContextValid(t *testing.T) {
 	mock := &metricsClientMock{}
 	client := newTestGRPCClient(mock)
@@ -301,3 +264,105 @@ func TestGRPCClient_UpdateMetrics_AddsRealIPMetadata_WhenAvailable(t *testing.T)
 		}
 	}
 }
+
+// TestGRPCDialOptions проверяет опции клиента на настоящем сервере: подпись
+// проверяет NewHashInterceptor в строгом режиме, тело расшифровывает
+// зарегистрированный кодек, токен читает NewAuthInterceptor.
+func TestGRPCDialOptions(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	rpc.RegisterEncryptionCodec(privKey)
+
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "agent", Hash: auth.HashToken("token"), Scopes: []string{auth.ScopeWrite}},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticatorFromTokens() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		hashKey   string
+		serverKey string
+		token     string
+		withAuth  bool
+		publicKey *rsa.PublicKey
+		wantCode  codes.Code
+	}{
+		{name: "plain"},
+		{name: "signed", hashKey: "key", serverKey: "key"},
+		{name: "wrong key", hashKey: "other", serverKey: "key", wantCode: codes.Unauthenticated},
+		{name: "unsigned in strict mode", serverKey: "key", wantCode: codes.Unauthenticated},
+		{name: "encrypted", publicKey: &privKey.PublicKey},
+		{name: "signed and encrypted", hashKey: "key", serverKey: "key", publicKey: &privKey.PublicKey},
+		{name: "with token", token: "token", withAuth: true},
+		{name: "without token", withAuth: true, wantCode: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a *auth.Authenticator
+			if tt.withAuth {
+				a = authenticator
+			}
+			keys := service.NewStaticKeyRing(tt.serverKey)
+			storage := service.NewMemStorage("", nil)
+
+			var contentType string
+			record := func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				md, _ := metadata.FromIncomingContext(ctx)
+				if v := md.Get("content-type"); len(v) > 0 {
+					contentType = v[0]
+				}
+				return handler(ctx, req)
+			}
+
+			lis := bufconn.Listen(1 << 20)
+			srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+				record,
+				rpc.NewAuthInterceptor(a),
+				rpc.NewHashInterceptor(keys, service.NewReplayGuard(time.Minute, true)),
+				rpc.NewResponseSigningInterceptor(keys),
+			))
+			proto.RegisterMetricsServer(srv, rpc.NewMetricsService(storage))
+			go func() { _ = srv.Serve(lis) }()
+			t.Cleanup(srv.Stop)
+
+			opts := append(grpcDialOptions(tt.hashKey, "", tt.token, tt.publicKey, nil),
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}))
+			conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+
+			client := &GRPCClient{logger: zap.NewNop().Sugar(), conn: conn, client: proto.NewMetricsClient(conn)}
+			v := 12.5
+			err = client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v})
+
+			if tt.wantCode != codes.OK {
+				if status.Code(errors.Unwrap(err)) != tt.wantCode {
+					t.Fatalf("got %v, want %v", err, tt.wantCode)
+				}
+				if _, ok := storage.GetGauge("Alloc"); ok {
+					t.Fatal("rejected metric was stored")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got, ok := storage.GetGauge("Alloc"); !ok || got != v {
+				t.Fatalf("gauge = %v, %v; want %v", got, ok, v)
+			}
+			if encrypted := strings.HasSuffix(contentType, "+"+strings.ToLower(service.EncryptionHybrid)); encrypted != (tt.publicKey != nil) {
+				t.Fatalf("content-type = %q, encrypted = %v", contentType, tt.publicKey != nil)
+			}
+		})
+	}
+}
@@ -1,14 +1,17 @@
 package agent
 
 import (
-	"crypto/rand"
+	"crypto/hmac"
 	"crypto/rsa"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"go.uber.org/zap"
+	gproto "google.golang.org/protobuf/proto"
 	"gopkg.in/resty.v1"
 	models "metrify/internal/model"
+	"metrify/internal/proto"
 	"metrify/internal/service"
 	"net/http"
 	"time"
@@ -20,19 +23,44 @@ type HTTPClient struct {
 	resty     *resty.Client
 	host      string
 	hashKey   string
+	keyID     string
+	token     string
 	maxRetry  int
 	publicKey *rsa.PublicKey
+	scheme    string
+	// Compression — Content-Encoding тела запроса (gzip, deflate или zstd).
+	// Тела короче service.DefaultMinCompressSize не сжимаются. Тело сжимается до шифрования.
+	Compression string
+	// Encryption — схема Content-Encryption при заданном publicKey. По умолчанию
+	// service.EncryptionHybrid; service.EncryptionRSAPKCS1v15 — для серверов без
+	// гибридной схемы: тело передаётся в base64, не сжимается и должно
+	// помещаться в один блок RSA.
+	Encryption string
+	// Format — формат тела батча: models.ContentTypeJSON или models.ContentTypeProtobuf.
+	Format string
 }
 
-func NewHTTPClient(host string, logger *zap.SugaredLogger, hashKey string, publicKey *rsa.PublicKey) *HTTPClient {
-	return &HTTPClient{
-		logger:    logger,
-		resty:     resty.New().SetTimeout(8),
-		host:      host,
-		hashKey:   hashKey,
-		maxRetry:  3,
-		publicKey: publicKey,
+func NewHTTPClient(host string, logger *zap.SugaredLogger, hashKey, keyID, token string, publicKey *rsa.PublicKey, tlsConfig *tls.Config) *HTTPClient {
+	client := &HTTPClient{
+		logger:     logger,
+		resty:      resty.New().SetTimeout(8 * time.Second),
+		host:       host,
+		hashKey:    hashKey,
+		keyID:      keyID,
+		token:      token,
+		maxRetry:   3,
+		publicKey:  publicKey,
+		scheme:     "http",
+		Format:     models.ContentTypeJSON,
+		Encryption: service.EncryptionHybrid,
 	}
+
+	if tlsConfig != nil {
+		client.resty.SetTLSClientConfig(tlsConfig)
+		client.scheme = "https"
+	}
+
+	return client
 }
 
 func (client *HTTPClient) Close() error {
@@ -46,30 +74,69 @@ func (client *HTTPClient) UpdateMetric(metric models.Metrics) error {
 		return err
 	}
 
-	return client.sendRequest(path, body, client.maxRetry)
+	return client.sendRequest(path, body, models.ContentTypeJSON, client.maxRetry)
 }
 
 func (client *HTTPClient) UpdateMetrics(metrics []models.Metrics) error {
 	path := "/updates"
-	body, err := json.Marshal(metrics)
+
+	var body []byte
+	var err error
+	if client.Format == models.ContentTypeProtobuf {
+		body, err = marshalProtoBatch(metrics)
+	} else {
+		body, err = json.Marshal(metrics)
+	}
 	if err != nil {
 		client.logger.Errorw("failed to marshal metrics", "error", err)
 		return err
 	}
 
-	return client.sendRequest(path, body, client.maxRetry)
+	return client.sendRequest(path, body, client.Format, client.maxRetry)
 }
 
-func (client *HTTPClient) sendRequest(path string, body []byte, maxRetry int) error {
-	client.resty.SetHostURL(fmt.Sprintf("http://%s", client.host))
+func marshalProtoBatch(metrics []models.Metrics) ([]byte, error) {
+	grpcMetrics := make([]*proto.Metric, 0, len(metrics))
+	for _, metric := range metrics {
+		m, err := proto.FromModel(metric)
+		if err != nil {
+			return nil, err
+		}
+		grpcMetrics = append(grpcMetrics, m)
+	}
+
+	req := &proto.UpdateMetricsRequest{}
+	req.SetMetrics(grpcMetrics)
+
+	return gproto.Marshal(req)
+}
+
+func (client *HTTPClient) sendRequest(path string, body []byte, contentType string, maxRetry int) error {
+	client.resty.SetHostURL(fmt.Sprintf("%s://%s", client.scheme, client.host))
 
 	req := client.resty.R().
-		SetHeader("Content-Type", "application/json")
+		SetHeader("Content-Type", contentType).
+		SetHeader("Accept", contentType)
 
-	if client.hashKey != "" {
-		req.SetHeader("HashSHA256", service.SignData(body, client.hashKey))
+	// Подписывается тело до сжатия и шифрования: сервер проверяет подпись
+	// после расшифровки и распаковки.
+	signed := body
+
+	// Старые серверы распаковывают тело до расшифровки, поэтому с устаревшей
+	// схемой тело не сжимается.
+	legacy := client.publicKey != nil && client.encryption() == service.EncryptionRSAPKCS1v15
+
+	if client.Compression != "" && !legacy && len(body) >= service.DefaultMinCompressSize {
+		compressed, err := service.Compress(client.Compression, body)
+		if err != nil {
+			return err
+		}
+
+		body = compressed
+		req.SetHeader("Content-Encoding", client.Compression)
 	}
 
+	// Content-Type остаётся типом исходного тела: шифрование отмечает только Content-Encryption.
 	if client.publicKey != nil {
 		encBody, err := client.encryptBody(body)
 		if err != nil {
@@ -77,16 +144,26 @@ func (client *HTTPClient) sendRequest(path string, body []byte, maxRetry int) er
 		}
 
 		body = encBody
-		req.SetHeader("Content-Type", "application/octet-stream")
-		req.SetHeader("Content-Encryption", "RSA-PKCS1v15")
+		req.SetHeader("Content-Encryption", client.encryption())
+	}
+
+	if client.token != "" {
+		req.SetAuthToken(client.token)
 	}
 
 	ip, err := getOutboundIP()
 	if err == nil {
-		req.SetHeader("X-Forwarded-For", ip)
+		req.SetHeader("X-Real-IP", ip)
 	}
 
+	var nonce string
+
 	resp, err := service.Retry(maxRetry, 1*time.Second, 2*time.Second, func() (*resty.Response, error) {
+		// Сервер запоминает nonce, поэтому каждая попытка подписывается заново.
+		if client.hashKey != "" {
+			nonce = client.signRequest(req, path, signed)
+		}
+
 		return req.SetBody(body).Post(path)
 	})
 	if err != nil {
@@ -97,25 +174,47 @@ func (client *HTTPClient) sendRequest(path string, body []byte, maxRetry int) er
 		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode(), resp.Body())
 	}
 
+	if client.hashKey != "" {
+		expected := service.SignResponse(resp.StatusCode(), nonce, resp.Body(), client.hashKey)
+		if !hmac.Equal([]byte(expected), []byte(resp.Header().Get("HashSHA256"))) {
+			return service.ErrInvalidResponseSignature
+		}
+	}
+
 	return nil
 }
 
+func (client *HTTPClient) signRequest(req *resty.Request, path string, body []byte) string {
+	timestamp := service.FormatTimestamp(time.Now())
+	nonce := service.NewNonce()
+
+	req.SetHeader(service.TimestampHeader, timestamp)
+	req.SetHeader(service.NonceHeader, nonce)
+	if client.keyID != "" {
+		req.SetHeader(service.KeyIDHeader, client.keyID)
+	}
+	req.SetHeader("HashSHA256", service.SignRequest(http.MethodPost, path, timestamp, nonce, body, client.hashKey))
+
+	return nonce
+}
+
 func (client *HTTPClient) encryptBody(plain []byte) ([]byte, error) {
 	if client.publicKey == nil {
 		return plain, nil
 	}
 
-	ciphertext, err := rsa.EncryptPKCS1v15(
-		rand.Reader,
-		client.publicKey,
-		plain,
-	)
-	if err != nil {
-		return nil, err
+	data, err := service.Encrypt(client.encryption(), client.publicKey, plain)
+	if err != nil || client.encryption() != service.EncryptionRSAPKCS1v15 {
+		return data, err
 	}
 
-	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(ciphertext)))
-	base64.StdEncoding.Encode(encoded, ciphertext)
+	return []byte(base64.StdEncoding.EncodeToString(data)), nil
+}
+
+func (client *HTTPClient) encryption() string {
+	if client.Encryption == "" {
+		return service.EncryptionHybrid
+	}
 
-	return encoded, nil
+	return client.Encryption
 }
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"crypto/rand"
 	"crypto/rsa"
-	"encoding/base64"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -15,10 +18,11 @@ import (
 
 	"go.uber.org/zap"
 	models "metrify/internal/model"
+	"metrify/internal/service"
 )
 
 func newTestHTTPClient(host string) *HTTPClient {
-	client := NewHTTPClient(host, zap.NewNop().Sugar(), "", nil)
+	client := NewHTTPClient(host, zap.NewNop().Sugar(), "", "", "", nil, nil)
 	client.maxRetry = 1
 	client.resty.SetTimeout(2 * time.Second)
 
@@ -26,7 +30,7 @@ func newTestHTTPClient(host string) *HTTPClient {
 }
 
 func TestNewHTTPClient(t *testing.T) {
-	client := NewHTTPClient("localhost:8080", zap.NewNop().Sugar(), "key", nil)
+	client := NewHTTPClient("localhost:8080", zap.NewNop().Sugar(), "key", "", "", nil, nil)
 
 	if client == nil {
 		t.Fatal("expected client, got nil")
@@ -166,6 +170,180 @@ func TestHTTPClient_UpdateMetrics(t *testing.T) {
 	}
 }
 
+func TestHTTPClient_UpdateMetric_TLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	host := strings.TrimPrefix(ts.URL, "https://")
+	client := NewHTTPClient(host, zap.NewNop().Sugar(), "", "", "", nil, &tls.Config{RootCAs: pool})
+	client.maxRetry = 1
+
+	if client.scheme != "https" {
+		t.Fatalf("got scheme %q, want https", client.scheme)
+	}
+
+	v := 1.0
+	if err := client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	untrusted := NewHTTPClient(host, zap.NewNop().Sugar(), "", "", "", nil, &tls.Config{RootCAs: x509.NewCertPool()})
+	untrusted.maxRetry = 1
+
+	if err := untrusted.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v}); err == nil {
+		t.Fatal("expected error for untrusted server certificate")
+	}
+}
+
+func TestHTTPClient_UpdateMetric_Signed(t *testing.T) {
+	var nonces []string
+	attempt := 0
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(service.TimestampHeader)
+		nonce := r.Header.Get(service.NonceHeader)
+		nonces = append(nonces, nonce)
+
+		if r.Header.Get("HashSHA256") != service.SignRequest(r.Method, r.URL.RequestURI(), timestamp, nonce, body, "secret") {
+			t.Errorf("invalid signature on attempt %d", attempt)
+		}
+
+		attempt++
+		if attempt == 1 {
+			// Обрыв соединения заставляет клиента повторить запрос.
+			hj, _ := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			_ = conn.Close()
+			return
+		}
+
+		w.Header().Set("HashSHA256", service.SignResponse(http.StatusOK, nonce, nil, "secret"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "secret", "", "", nil, nil)
+	client.maxRetry = 2
+
+	v := 1.0
+	if err := client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(nonces) != 2 || nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Fatalf("expected a fresh nonce per attempt, got %q", nonces)
+	}
+}
+
+func TestHTTPClient_UpdateMetric_KeyID(t *testing.T) {
+	var gotKeyID string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKeyID = r.Header.Get(service.KeyIDHeader)
+		w.Header().Set("HashSHA256", service.SignResponse(http.StatusOK, r.Header.Get(service.NonceHeader), nil, "secret"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "secret", "2026-02", "", nil, nil)
+	client.maxRetry = 1
+
+	v := 1.0
+	if err := client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotKeyID != "2026-02" {
+		t.Fatalf("got key id %q, want 2026-02", gotKeyID)
+	}
+}
+
+func TestHTTPClient_VerifiesResponseSignature(t *testing.T) {
+	tests := []struct {
+		name    string
+		sign    func(r *http.Request, body []byte) string
+		wantErr bool
+	}{
+		{
+			name: "valid signature",
+			sign: func(r *http.Request, body []byte) string {
+				return service.SignResponse(http.StatusOK, r.Header.Get(service.NonceHeader), body, "secret")
+			},
+		},
+		{
+			name:    "missing signature",
+			sign:    func(r *http.Request, body []byte) string { return "" },
+			wantErr: true,
+		},
+		{
+			name: "signature for another request",
+			sign: func(r *http.Request, body []byte) string {
+				return service.SignResponse(http.StatusOK, "other-nonce", body, "secret")
+			},
+			wantErr: true,
+		},
+		{
+			name: "forged body",
+			sign: func(r *http.Request, body []byte) string {
+				return service.SignResponse(http.StatusOK, r.Header.Get(service.NonceHeader), []byte("{}"), "secret")
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{"id":"Alloc","type":"gauge","value":1}`)
+
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("HashSHA256", tt.sign(r, body))
+				_, _ = w.Write(body)
+			}))
+			defer ts.Close()
+
+			client := NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "secret", "", "", nil, nil)
+			client.maxRetry = 1
+
+			v := 1.0
+			err := client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UpdateMetric() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, service.ErrInvalidResponseSignature) {
+				t.Fatalf("got %v, want ErrInvalidResponseSignature", err)
+			}
+		})
+	}
+}
+
+func TestHTTPClient_UpdateMetric_Token(t *testing.T) {
+	var gotAuth string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "", "", "agent-token", nil, nil)
+	client.maxRetry = 1
+
+	v := 1.0
+	if err := client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotAuth != "Bearer agent-token" {
+		t.Fatalf("got Authorization %q, want Bearer agent-token", gotAuth)
+	}
+}
+
 func TestHTTPClient_SendRequest_Non200(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "boom", http.StatusInternalServerError)
@@ -175,7 +353,7 @@ func TestHTTPClient_SendRequest_Non200(t *testing.T) {
 	host := strings.TrimPrefix(ts.URL, "http://")
 	client := newTestHTTPClient(host)
 
-	err := client.sendRequest("/update", []byte(`{}`), 1)
+	err := client.sendRequest("/update", []byte(`{}`), models.ContentTypeJSON, 1)
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -219,12 +397,107 @@ func TestHTTPClient_EncryptBody_WithPublicKey(t *testing.T) {
 		t.Fatal("encrypted data must differ from plain data")
 	}
 
-	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(got)))
-	n, err := base64.StdEncoding.Decode(decoded, got)
+	decrypted, err := service.DecryptHybrid(privateKey, got)
 	if err != nil {
-		t.Fatalf("encrypted body is not valid base64: %v", err)
+		t.Fatalf("failed to decrypt body: %v", err)
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Fatalf("got %q, want %q", decrypted, plain)
+	}
+}
+
+func TestHTTPClient_UpdateMetrics_EncryptsLargeBatch(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+
+	var gotEncryption string
+	var gotBody []byte
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncryption = r.Header.Get("Content-Encryption")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := newTestHTTPClient(strings.TrimPrefix(ts.URL, "http://"))
+	client.publicKey = &privateKey.PublicKey
+
+	metrics := make([]models.Metrics, 0, 100)
+	for i := 0; i < 100; i++ {
+		v := float64(i)
+		metrics = append(metrics, models.Metrics{ID: fmt.Sprintf("Gauge%d", i), MType: models.Gauge, Value: &v})
+	}
+
+	if err := client.UpdateMetrics(metrics); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotEncryption != service.EncryptionHybrid {
+		t.Fatalf("got Content-Encryption %q, want %q", gotEncryption, service.EncryptionHybrid)
+	}
+
+	plain, err := service.DecryptHybrid(privateKey, gotBody)
+	if err != nil {
+		t.Fatalf("failed to decrypt body: %v", err)
+	}
+
+	var got []models.Metrics
+	if err := json.Unmarshal(plain, &got); err != nil {
+		t.Fatalf("failed to unmarshal body: %v", err)
+	}
+	if len(got) != 100 {
+		t.Fatalf("got %d metrics, want 100", len(got))
+	}
+}
+
+func TestHTTPClient_Compression(t *testing.T) {
+	var gotEncoding string
+	var gotBody []models.Metrics
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotEncoding = r.Header.Get("Content-Encoding")
+
+		body := io.Reader(r.Body)
+		if gotEncoding != "" {
+			cr, err := service.NewDecodingReader(r.Body, gotEncoding)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			defer cr.Close()
+			body = cr
+		}
+
+		_ = json.NewDecoder(body).Decode(&gotBody)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	client := NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "", "", "", nil, nil)
+	client.maxRetry = 1
+	client.Compression = service.EncodingZstd
+
+	metrics := make([]models.Metrics, 0, 50)
+	for i := range 50 {
+		d := int64(i)
+		metrics = append(metrics, models.Metrics{ID: fmt.Sprintf("Counter%d", i), MType: models.Counter, Delta: &d})
+	}
+
+	if err := client.UpdateMetrics(metrics); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotEncoding != service.EncodingZstd || len(gotBody) != 50 {
+		t.Fatalf("got encoding %q and %d metrics, want zstd and 50", gotEncoding, len(gotBody))
+	}
+
+	v := 1.0
+	if err := client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if n == 0 {
-		t.Fatal("decoded encrypted body is empty")
+	if gotEncoding != "" {
+		t.Fatalf("small body must not be compressed, got %q", gotEncoding)
 	}
 }
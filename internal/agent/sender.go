@@ -2,6 +2,7 @@ package agent
 
 import (
 	"crypto/rsa"
+	"crypto/tls"
 	"fmt"
 	"go.uber.org/zap"
 	models "metrify/internal/model"
@@ -14,12 +15,24 @@ type Sender interface {
 	Close() error
 }
 
-func NewSender(protocol, host string, logger *zap.SugaredLogger, hashKey string, publicKey *rsa.PublicKey) (Sender, error) {
+// NewSender создаёт клиента для протокола. compression — Content-Encoding тела
+// HTTP-запросов, пустая строка отключает сжатие; format — формат батча HTTP
+// (models.ContentTypeJSON или models.ContentTypeProtobuf), пустой означает JSON;
+// encryption — схема шифрования тела HTTP, пустая означает гибридную.
+func NewSender(protocol, host string, logger *zap.SugaredLogger, hashKey, keyID, token string, publicKey *rsa.PublicKey, tlsConfig *tls.Config, compression, format, encryption string) (Sender, error) {
 	switch protocol {
 	case "", "http":
-		return NewHTTPClient(host, logger, hashKey, publicKey), nil
+		client := NewHTTPClient(host, logger, hashKey, keyID, token, publicKey, tlsConfig)
+		client.Compression = compression
+		if format != "" {
+			client.Format = format
+		}
+		if encryption != "" {
+			client.Encryption = encryption
+		}
+		return client, nil
 	case "grpc":
-		return NewGRPCClient(host, logger, hashKey, publicKey), nil
+		return NewGRPCClient(host, logger, hashKey, keyID, token, publicKey, tlsConfig), nil
 	default:
 		return nil, fmt.Errorf("unknown protocol %q", protocol)
 	}
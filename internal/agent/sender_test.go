@@ -8,7 +8,7 @@ import (
 func TestNewSender_HTTP_Default(t *testing.T) {
 	logger := zap.NewNop().Sugar()
 
-	s, err := NewSender("", "localhost:8080", logger, "", nil)
+	s, err := NewSender("", "localhost:8080", logger, "", "", "", nil, nil, "", "", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -25,7 +25,7 @@ func TestNewSender_HTTP_Default(t *testing.T) {
 func TestNewSender_HTTP(t *testing.T) {
 	logger := zap.NewNop().Sugar()
 
-	s, err := NewSender("http", "localhost:8080", logger, "", nil)
+	s, err := NewSender("http", "localhost:8080", logger, "", "", "", nil, nil, "", "", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -38,7 +38,7 @@ func TestNewSender_HTTP(t *testing.T) {
 func TestNewSender_GRPC(t *testing.T) {
 	logger := zap.NewNop().Sugar()
 
-	s, err := NewSender("grpc", "localhost:9090", logger, "", nil)
+	s, err := NewSender("grpc", "localhost:9090", logger, "", "", "", nil, nil, "", "", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -51,7 +51,7 @@ func TestNewSender_GRPC(t *testing.T) {
 func TestNewSender_UnknownProtocol(t *testing.T) {
 	logger := zap.NewNop().Sugar()
 
-	_, err := NewSender("ws", "localhost:8080", logger, "", nil)
+	_, err := NewSender("ws", "localhost:8080", logger, "", "", "", nil, nil, "", "", "")
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -0,0 +1,222 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	models "metrify/internal/model"
+	"metrify/internal/query"
+	"metrify/internal/service"
+)
+
+// DefaultRetention — сколько resolved-алерты остаются в списке.
+const DefaultRetention = 15 * time.Minute
+
+// Engine периодически вычисляет правила и хранит состояние алертов.
+// Нулевой *Engine не содержит правил и алертов.
+type Engine struct {
+	storage service.Storage
+	rules   []Rule
+	logger  *zap.SugaredLogger
+	// Retention — сколько resolved-алерты остаются в списке.
+	Retention time.Duration
+
+	mu sync.RWMutex
+	// alerts[i] — алерты правила rules[i] по ключу меток.
+	alerts []map[string]*models.Alert
+}
+
+// NewEngine проверяет правила; ошибка в любом из них не даёт запустить сервер.
+func NewEngine(storage service.Storage, rules []Rule, logger *zap.SugaredLogger) (*Engine, error) {
+	names := make(map[string]bool, len(rules))
+	compiled := make([]Rule, len(rules))
+	alerts := make([]map[string]*models.Alert, len(rules))
+
+	for i, rule := range rules {
+		if err := rule.compile(); err != nil {
+			return nil, err
+		}
+		if names[rule.Tenant+"/"+rule.Name] {
+			return nil, fmt.Errorf("%w %q: duplicate name", ErrInvalidRule, rule.Name)
+		}
+		names[rule.Tenant+"/"+rule.Name] = true
+		compiled[i] = rule
+		alerts[i] = make(map[string]*models.Alert)
+	}
+
+	return &Engine{
+		storage:   storage,
+		rules:     compiled,
+		logger:    logger,
+		Retention: DefaultRetention,
+		alerts:    alerts,
+	}, nil
+}
+
+// Run вычисляет правила раз в interval до отмены ctx.
+func (e *Engine) Run(ctx context.Context, interval time.Duration) error {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Evaluate(time.Now()); err != nil {
+			e.logger.Warnw("alert rules evaluation failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Evaluate вычисляет все правила на момент now. Ошибка одного правила
+// не мешает остальным.
+func (e *Engine) Evaluate(now time.Time) error {
+	var errs []error
+
+	for i := range e.rules {
+		if err := e.evaluate(&e.rules[i], e.alerts[i], now); err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", e.rules[i].Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (e *Engine) evaluate(rule *Rule, alerts map[string]*models.Alert, now time.Time) error {
+	samples, err := query.Eval(e.storage.ForTenant(rule.Tenant), rule.expr, now)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	active := make(map[string]bool, len(samples))
+
+	for _, sample := range samples {
+		if !rule.Matches(sample.Value) {
+			continue
+		}
+
+		key := LabelsKey(sample.Labels)
+		active[key] = true
+
+		a, ok := alerts[key]
+		if !ok || a.State == models.AlertResolved {
+			a = &models.Alert{
+				Rule:        rule.Name,
+				State:       models.AlertPending,
+				Severity:    rule.Severity,
+				Tenant:      rule.Tenant,
+				Labels:      sample.Labels,
+				Annotations: rule.Annotations,
+				ActiveAt:    now,
+			}
+			alerts[key] = a
+		}
+		a.Value = sample.Value
+
+		if a.State == models.AlertPending && now.Sub(a.ActiveAt) >= time.Duration(rule.For) {
+			fired := now
+			a.State, a.FiredAt = models.AlertFiring, &fired
+		}
+	}
+
+	for key, a := range alerts {
+		if active[key] {
+			continue
+		}
+
+		switch a.State {
+		case models.AlertPending:
+			delete(alerts, key)
+		case models.AlertFiring:
+			resolved := now
+			a.State, a.ResolvedAt = models.AlertResolved, &resolved
+		case models.AlertResolved:
+			if now.Sub(*a.ResolvedAt) > e.Retention {
+				delete(alerts, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Alerts возвращает алерты арендатора, отсортированные по правилу и меткам.
+// Пустой tenant означает всех арендаторов, пустой state — все состояния.
+func (e *Engine) Alerts(tenant, state string) []models.Alert {
+	if e == nil {
+		return nil
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var result []models.Alert
+	for i, alerts := range e.alerts {
+		if tenant != "" && e.rules[i].Tenant != tenant {
+			continue
+		}
+
+		for _, a := range alerts {
+			if state != "" && a.State != state {
+				continue
+			}
+
+			alert := *a
+			alert.Labels = maps.Clone(a.Labels)
+			alert.Annotations = maps.Clone(a.Annotations)
+			result = append(result, alert)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Rule != result[j].Rule {
+			return result[i].Rule < result[j].Rule
+		}
+		return LabelsKey(result[i].Labels) < LabelsKey(result[j].Labels)
+	})
+
+	return result
+}
+
+// ValidState сообщает, можно ли фильтровать алерты по state.
+func ValidState(state string) bool {
+	switch state {
+	case "", models.AlertPending, models.AlertFiring, models.AlertResolved:
+		return true
+	}
+
+	return false
+}
+
+// LabelsKey однозначно задаёт набор меток: одинаковые наборы дают одинаковый ключ.
+func LabelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + labels[k] + "\xff")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package alert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	models "metrify/internal/model"
+	"metrify/internal/service"
+)
+
+func newTestEngine(t *testing.T, ms *service.MemStorage, rules ...Rule) *Engine {
+	t.Helper()
+
+	e, err := NewEngine(ms, rules, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("NewEngine() error = %v", err)
+	}
+
+	return e
+}
+
+func states(alerts []models.Alert) map[string]string {
+	got := make(map[string]string, len(alerts))
+	for _, a := range alerts {
+		got[a.Labels["__name__"]] = a.State
+	}
+
+	return got
+}
+
+func TestEngine_Lifecycle(t *testing.T) {
+	ms := service.NewMemStorage("", nil)
+	e := newTestEngine(t, ms, Rule{Name: "HighHeap", Expr: "HeapAlloc*", Op: ">", Threshold: 100, For: Duration(time.Minute), Severity: "critical"})
+	start := time.Now()
+
+	if err := ms.UpdateGauge("HeapAlloc:web-1", 150); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.UpdateGauge("HeapAlloc:web-2", 50); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Evaluate(start); err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	alerts := e.Alerts(service.DefaultTenant, "")
+	if len(alerts) != 1 || alerts[0].State != models.AlertPending || alerts[0].Severity != "critical" || alerts[0].Value != 150 {
+		t.Fatalf("after first evaluation: %+v", alerts)
+	}
+
+	_ = e.Evaluate(start.Add(30 * time.Second))
+	if got := states(e.Alerts(service.DefaultTenant, "")); got["HeapAlloc:web-1"] != models.AlertPending {
+		t.Fatalf("alert must stay pending before for elapses: %v", got)
+	}
+
+	_ = e.Evaluate(start.Add(time.Minute))
+	alerts = e.Alerts(service.DefaultTenant, models.AlertFiring)
+	if len(alerts) != 1 || alerts[0].FiredAt == nil || !alerts[0].ActiveAt.Equal(start) {
+		t.Fatalf("alert must fire after for: %+v", alerts)
+	}
+
+	_ = ms.UpdateGauge("HeapAlloc:web-1", 10)
+	_ = e.Evaluate(start.Add(2 * time.Minute))
+	alerts = e.Alerts(service.DefaultTenant, "")
+	if len(alerts) != 1 || alerts[0].State != models.AlertResolved || alerts[0].ResolvedAt == nil {
+		t.Fatalf("alert must resolve: %+v", alerts)
+	}
+
+	_ = ms.UpdateGauge("HeapAlloc:web-1", 200)
+	_ = e.Evaluate(start.Add(3 * time.Minute))
+	alerts = e.Alerts(service.DefaultTenant, "")
+	if len(alerts) != 1 || alerts[0].State != models.AlertPending || alerts[0].FiredAt != nil {
+		t.Fatalf("resolved alert must start over as pending: %+v", alerts)
+	}
+
+	_ = ms.UpdateGauge("HeapAlloc:web-1", 10)
+	_ = e.Evaluate(start.Add(4 * time.Minute))
+	if alerts := e.Alerts(service.DefaultTenant, ""); len(alerts) != 0 {
+		t.Fatalf("pending alert must be dropped when the condition clears: %+v", alerts)
+	}
+}
+
+func TestEngine_RetentionAndTenants(t *testing.T) {
+	ms := service.NewMemStorage("", nil)
+	e := newTestEngine(t, ms,
+		Rule{Name: "NoPolls", Expr: "sum(PollCount*)", Op: "<", Threshold: 1},
+		Rule{Name: "TeamHeap", Expr: "HeapAlloc", Op: ">=", Threshold: 5, Tenant: "team"},
+	)
+	e.Retention = time.Minute
+	now := time.Now()
+
+	_ = ms.UpdateCounter("PollCount", 0)
+	_ = ms.ForTenant("team").UpdateGauge("HeapAlloc", 5)
+	_ = ms.UpdateGauge("HeapAlloc", 50)
+	_ = e.Evaluate(now)
+
+	if alerts := e.Alerts(service.DefaultTenant, models.AlertFiring); len(alerts) != 1 || alerts[0].Rule != "NoPolls" || len(alerts[0].Labels) != 0 {
+		t.Fatalf("default tenant alerts: %+v", alerts)
+	}
+	if alerts := e.Alerts("team", ""); len(alerts) != 1 || alerts[0].Rule != "TeamHeap" || alerts[0].Tenant != "team" {
+		t.Fatalf("team alerts: %+v", alerts)
+	}
+	if alerts := e.Alerts("", ""); len(alerts) != 2 {
+		t.Fatalf("all tenants alerts: %+v", alerts)
+	}
+
+	_ = ms.UpdateCounter("PollCount", 5)
+	_ = e.Evaluate(now.Add(time.Second))
+	if alerts := e.Alerts(service.DefaultTenant, models.AlertResolved); len(alerts) != 1 {
+		t.Fatalf("alert must be resolved: %+v", alerts)
+	}
+
+	_ = e.Evaluate(now.Add(2 * time.Minute))
+	if alerts := e.Alerts(service.DefaultTenant, ""); len(alerts) != 0 {
+		t.Fatalf("resolved alert must expire after retention: %+v", alerts)
+	}
+
+	var nilEngine *Engine
+	if alerts := nilEngine.Alerts(service.DefaultTenant, ""); alerts != nil {
+		t.Fatalf("nil engine returned %v", alerts)
+	}
+}
+
+func TestEngine_Run(t *testing.T) {
+	ms := service.NewMemStorage("", nil)
+	_ = ms.UpdateGauge("Alloc", 10)
+	e := newTestEngine(t, ms, Rule{Name: "Alloc", Expr: "Alloc", Op: ">", Threshold: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- e.Run(ctx, time.Hour)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(e.Alerts(service.DefaultTenant, models.AlertFiring)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Run() must evaluate rules immediately")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("Run() error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,127 @@
+// Package alert вычисляет пороговые правила по метрикам хранилища.
+//
+// Правило задаёт запрос на языке /query (селектор или агрегацию), сравнение
+// с порогом и время for, в течение которого условие должно выполняться.
+// Для каждого значения результата, удовлетворяющего условию, заводится алерт:
+// pending, пока не прошло for, затем firing; когда условие перестаёт
+// выполняться, firing-алерт становится resolved.
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"metrify/internal/query"
+	"metrify/internal/service"
+)
+
+// DefaultInterval — период вычисления правил.
+const DefaultInterval = 15 * time.Second
+
+// DefaultSeverity — важность правил, где она не указана.
+const DefaultSeverity = "warning"
+
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// Duration — длительность в JSON в виде строки time.ParseDuration ("30s", "5m").
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"1m\": %w", err)
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rule — пороговое правило.
+type Rule struct {
+	Name string `json:"name"`
+	// Expr — запрос на языке /query, например "max(HeapAlloc*)".
+	Expr string `json:"expr"`
+	// Op — сравнение значения с Threshold: >, >=, <, <=, == или !=.
+	Op          string            `json:"op"`
+	Threshold   float64           `json:"threshold"`
+	For         Duration          `json:"for,omitempty"`
+	Severity    string            `json:"severity,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Tenant — арендатор, по метрикам которого вычисляется правило.
+	Tenant string `json:"tenant,omitempty"`
+
+	expr query.Expr
+}
+
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// LoadRules читает правила из JSON-файла вида {"rules": [...]}.
+func LoadRules(path string) ([]Rule, error) {
+	file, err := service.FromFile[rulesFile](path)
+	if err != nil {
+		return nil, fmt.Errorf("load alert rules %s: %w", path, err)
+	}
+
+	return file.Rules, nil
+}
+
+// compile проверяет правило и заполняет значения по умолчанию.
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+
+	if _, ok := compare[r.Op]; !ok {
+		return fmt.Errorf("%w %q: unknown op %q (expect > >= < <= == !=)", ErrInvalidRule, r.Name, r.Op)
+	}
+
+	if r.For < 0 {
+		return fmt.Errorf("%w %q: for must not be negative", ErrInvalidRule, r.Name)
+	}
+
+	if r.Tenant == "" {
+		r.Tenant = service.DefaultTenant
+	}
+	if err := service.ValidateTenant(r.Tenant); err != nil {
+		return fmt.Errorf("%w %q: %w", ErrInvalidRule, r.Name, err)
+	}
+
+	if r.Severity == "" {
+		r.Severity = DefaultSeverity
+	}
+
+	expr, err := query.Parse(r.Expr)
+	if err != nil {
+		return fmt.Errorf("%w %q: %w", ErrInvalidRule, r.Name, err)
+	}
+	r.expr = expr
+
+	return nil
+}
+
+var compare = map[string]func(value, threshold float64) bool{
+	">":  func(v, t float64) bool { return v > t },
+	">=": func(v, t float64) bool { return v >= t },
+	"<":  func(v, t float64) bool { return v < t },
+	"<=": func(v, t float64) bool { return v <= t },
+	"==": func(v, t float64) bool { return v == t },
+	"!=": func(v, t float64) bool { return v != t },
+}
+
+// Matches сообщает, выполняется ли условие правила для значения.
+func (r *Rule) Matches(value float64) bool {
+	return compare[r.Op](value, r.Threshold)
+}
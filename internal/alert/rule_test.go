@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"metrify/internal/service"
+)
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	data := `{"rules": [{"name": "HighHeap", "expr": "max(HeapAlloc*)", "op": ">", "threshold": 100, "for": "1m", "annotations": {"summary": "heap is large"}}]}`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatalf("LoadRules() error = %v", err)
+	}
+	if len(rules) != 1 || rules[0].Name != "HighHeap" || time.Duration(rules[0].For) != time.Minute || rules[0].Annotations["summary"] != "heap is large" {
+		t.Fatalf("LoadRules() = %+v", rules)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"rules": [{"name": "x", "for": 60}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadRules(path); err == nil {
+		t.Fatal("expected error for numeric duration")
+	}
+
+	if _, err := LoadRules(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestNewEngine_InvalidRules(t *testing.T) {
+	valid := Rule{Name: "r", Expr: "Alloc", Op: ">", Threshold: 1}
+
+	tests := map[string]func(r *Rule){
+		"no name":          func(r *Rule) { r.Name = "" },
+		"unknown op":       func(r *Rule) { r.Op = "=>" },
+		"bad expr":         func(r *Rule) { r.Expr = "sum(Alloc" },
+		"negative for":     func(r *Rule) { r.For = Duration(-time.Second) },
+		"invalid tenant":   func(r *Rule) { r.Tenant = "bad tenant" },
+		"empty selector":   func(r *Rule) { r.Expr = "" },
+		"unknown function": func(r *Rule) { r.Expr = "median(Alloc)" },
+	}
+
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			rule := valid
+			mutate(&rule)
+
+			if _, err := NewEngine(service.NewMemStorage("", nil), []Rule{rule}, zap.NewNop().Sugar()); !errors.Is(err, ErrInvalidRule) {
+				t.Fatalf("NewEngine() error = %v, want ErrInvalidRule", err)
+			}
+		})
+	}
+
+	if _, err := NewEngine(service.NewMemStorage("", nil), []Rule{valid, valid}, zap.NewNop().Sugar()); !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("duplicate names: error = %v, want ErrInvalidRule", err)
+	}
+
+	other := valid
+	other.Tenant = "team"
+	if _, err := NewEngine(service.NewMemStorage("", nil), []Rule{valid, other}, zap.NewNop().Sugar()); err != nil {
+		t.Fatalf("same name in another tenant: error = %v", err)
+	}
+}
@@ -7,11 +7,25 @@ import (
 	"time"
 )
 
+// Действия в Event.Action: массовые операции и окна обслуживания.
+const (
+	ActionExport        = "export"
+	ActionImport        = "import"
+	ActionSilenceCreate = "silence_create"
+	ActionSilenceExpire = "silence_expire"
+)
+
 // generate:reset
 type Event struct {
 	TS        int64    `json:"ts"`
 	Metrics   []string `json:"metrics"`
 	IPAddress string   `json:"ip_address"`
+	Identity  string   `json:"identity,omitempty"`
+	Tenant    string   `json:"tenant,omitempty"`
+	// Action пустое для обновлений метрик.
+	Action string `json:"action,omitempty"`
+	// Silence — ID окна обслуживания; Metrics тогда содержит его селектор.
+	Silence string `json:"silence,omitempty"`
 }
 
 // generate:reset
@@ -0,0 +1,178 @@
+// Package auth проверяет токены доступа к API и хранит права клиента.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"metrify/internal/service"
+)
+
+// Права токена. ScopeAdmin включает все остальные.
+const (
+	ScopeRead  = "metrics:read"
+	ScopeWrite = "metrics:write"
+	ScopeAdmin = "admin"
+)
+
+var (
+	ErrNoToken      = errors.New("authentication token is required")
+	ErrInvalidToken = errors.New("invalid authentication token")
+	ErrForbidden    = errors.New("token does not have the required scope")
+	ErrWrongTenant  = errors.New("token is not allowed to access this tenant")
+)
+
+// Identity — клиент, предъявивший токен. Непустой Tenant привязывает
+// клиента к арендатору.
+type Identity struct {
+	Name   string
+	Scopes []string
+	Tenant string
+}
+
+// Has сообщает, есть ли у клиента право scope.
+func (id *Identity) Has(scope string) bool {
+	if id == nil {
+		return false
+	}
+
+	return slices.Contains(id.Scopes, scope) || slices.Contains(id.Scopes, ScopeAdmin)
+}
+
+// Token — запись файла токенов. В файле хранится только SHA-256 от токена
+// в hex, сам токен сервер не знает: `echo -n "$TOKEN" | sha256sum`.
+type Token struct {
+	Name   string   `json:"name"`
+	Hash   string   `json:"hash"`
+	Scopes []string `json:"scopes"`
+	Tenant string   `json:"tenant,omitempty"`
+}
+
+type tokensFile struct {
+	Tokens []Token `json:"tokens"`
+}
+
+// Authenticator ищет клиента по токену. Нулевой *Authenticator означает,
+// что аутентификация выключена и все запросы разрешены.
+type Authenticator struct {
+	tokens map[string]*Identity
+}
+
+// NewAuthenticator загружает токены из JSON-файла вида {"tokens": [...]}.
+func NewAuthenticator(path string) (*Authenticator, error) {
+	file, err := service.FromFile[tokensFile](path)
+	if err != nil {
+		return nil, fmt.Errorf("load tokens %s: %w", path, err)
+	}
+
+	return NewAuthenticatorFromTokens(file.Tokens)
+}
+
+func NewAuthenticatorFromTokens(tokens []Token) (*Authenticator, error) {
+	a := &Authenticator{tokens: make(map[string]*Identity, len(tokens))}
+
+	for _, t := range tokens {
+		hash := strings.ToLower(t.Hash)
+		if t.Name == "" || len(hash) != sha256.Size*2 {
+			return nil, fmt.Errorf("token %q: name and sha256 hash are required", t.Name)
+		}
+
+		for _, scope := range t.Scopes {
+			if scope != ScopeRead && scope != ScopeWrite && scope != ScopeAdmin {
+				return nil, fmt.Errorf("token %q: unknown scope %q", t.Name, scope)
+			}
+		}
+
+		if t.Tenant != "" {
+			if err := service.ValidateTenant(t.Tenant); err != nil {
+				return nil, fmt.Errorf("token %q: %w", t.Name, err)
+			}
+		}
+
+		a.tokens[hash] = &Identity{Name: t.Name, Scopes: t.Scopes, Tenant: t.Tenant}
+	}
+
+	return a, nil
+}
+
+// HashToken возвращает значение поля hash для токена.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// Enabled сообщает, включена ли аутентификация.
+func (a *Authenticator) Enabled() bool {
+	return a != nil
+}
+
+// Authenticate находит клиента по токену и проверяет, что у него есть право scope.
+// Токены ищутся по хешу, поэтому время ответа не зависит от совпадающего префикса.
+func (a *Authenticator) Authenticate(token, scope string) (*Identity, error) {
+	if token == "" {
+		return nil, ErrNoToken
+	}
+
+	id, ok := a.tokens[HashToken(token)]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	if !id.Has(scope) {
+		return id, ErrForbidden
+	}
+
+	return id, nil
+}
+
+// ResolveTenant определяет арендатора запроса. Арендатор токена важнее
+// запрошенного: клиент с привязанным токеном не может обратиться к чужому
+// арендатору. Без привязки используется requested (X-Tenant-ID), а без него — DefaultTenant.
+func ResolveTenant(id *Identity, requested string) (string, error) {
+	if id != nil && id.Tenant != "" {
+		if requested != "" && requested != id.Tenant {
+			return "", fmt.Errorf("%w %q", ErrWrongTenant, requested)
+		}
+
+		return id.Tenant, nil
+	}
+
+	if requested == "" {
+		return service.DefaultTenant, nil
+	}
+
+	if err := service.ValidateTenant(requested); err != nil {
+		return "", err
+	}
+
+	return requested, nil
+}
+
+// BearerToken извлекает токен из значения заголовка Authorization.
+func BearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
+
+type identityKey struct{}
+
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext возвращает клиента, прошедшего аутентификацию.
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+
+	return id, ok && id != nil
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"metrify/internal/service"
+)
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	a, err := NewAuthenticatorFromTokens([]Token{
+		{Name: "agent", Hash: HashToken("agent-token"), Scopes: []string{ScopeWrite}},
+		{Name: "grafana", Hash: HashToken("read-token"), Scopes: []string{ScopeRead}},
+		{Name: "ops", Hash: HashToken("admin-token"), Scopes: []string{ScopeAdmin}},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticatorFromTokens() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		scope    string
+		wantName string
+		wantErr  error
+	}{
+		{name: "write token writes", token: "agent-token", scope: ScopeWrite, wantName: "agent"},
+		{name: "write token cannot read", token: "agent-token", scope: ScopeRead, wantName: "agent", wantErr: ErrForbidden},
+		{name: "read token reads", token: "read-token", scope: ScopeRead, wantName: "grafana"},
+		{name: "read token cannot write", token: "read-token", scope: ScopeWrite, wantName: "grafana", wantErr: ErrForbidden},
+		{name: "admin can do anything", token: "admin-token", scope: ScopeWrite, wantName: "ops"},
+		{name: "unknown token", token: "guess", scope: ScopeRead, wantErr: ErrInvalidToken},
+		{name: "no token", token: "", scope: ScopeRead, wantErr: ErrNoToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := a.Authenticate(tt.token, tt.scope)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() error = %v, want %v", err, tt.wantErr)
+			}
+
+			var name string
+			if id != nil {
+				name = id.Name
+			}
+			if name != tt.wantName {
+				t.Fatalf("identity = %q, want %q", name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	data := `{"tokens":[{"name":"agent","hash":"` + HashToken("secret") + `","scopes":["metrics:write"]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewAuthenticator(path)
+	if err != nil {
+		t.Fatalf("NewAuthenticator() error = %v", err)
+	}
+	if _, err := a.Authenticate("secret", ScopeWrite); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	if _, err := NewAuthenticator(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := NewAuthenticatorFromTokens([]Token{{Name: "plain", Hash: "secret"}}); err == nil {
+		t.Error("expected error for token stored without hash")
+	}
+	if _, err := NewAuthenticatorFromTokens([]Token{{Name: "x", Hash: HashToken("x"), Scopes: []string{"root"}}}); err == nil {
+		t.Error("expected error for unknown scope")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := map[string]string{
+		"Bearer abc":  "abc",
+		"bearer abc ": "abc",
+		"Basic abc":   "",
+		"abc":         "",
+		"":            "",
+	}
+
+	for header, want := range tests {
+		if got := BearerToken(header); got != want {
+			t.Errorf("BearerToken(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestIdentityContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Fatal("expected no identity in empty context")
+	}
+
+	ctx := WithIdentity(context.Background(), &Identity{Name: "agent"})
+	if id, ok := FromContext(ctx); !ok || id.Name != "agent" {
+		t.Fatalf("FromContext() = %v, %v", id, ok)
+	}
+
+	var disabled *Authenticator
+	if disabled.Enabled() {
+		t.Fatal("nil authenticator must be disabled")
+	}
+}
+
+func TestResolveTenant(t *testing.T) {
+	bound := &Identity{Name: "team-a-agent", Tenant: "team-a"}
+	unbound := &Identity{Name: "ops"}
+
+	tests := []struct {
+		name      string
+		id        *Identity
+		requested string
+		want      string
+		wantErr   error
+	}{
+		{name: "anonymous without header", want: service.DefaultTenant},
+		{name: "anonymous with header", requested: "team-b", want: "team-b"},
+		{name: "invalid header", requested: "team b", wantErr: service.ErrInvalidTenant},
+		{name: "bound token", id: bound, want: "team-a"},
+		{name: "bound token with same header", id: bound, requested: "team-a", want: "team-a"},
+		{name: "bound token with other header", id: bound, requested: "team-b", wantErr: ErrWrongTenant},
+		{name: "unbound token with header", id: unbound, requested: "team-b", want: "team-b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveTenant(tt.id, tt.requested)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ResolveTenant() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ResolveTenant() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
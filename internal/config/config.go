@@ -1,10 +1,33 @@
 package config
 
 type Config struct {
-	Address       string `json:"address"`
-	Restore       bool   `json:"restore"`
-	StoreInterval string `json:"store_interval"`
-	StoreFile     string `json:"store_file"`
-	DatabaseDsn   string `json:"database_dsn"`
-	CryptoKey     string `json:"crypto_key"`
+	Address          string  `json:"address"`
+	GRPCAddress      string  `json:"grpc_address"`
+	Protocol         string  `json:"protocol"`
+	Restore          bool    `json:"restore"`
+	StoreInterval    string  `json:"store_interval"`
+	StoreFile        string  `json:"store_file"`
+	DatabaseDsn      string  `json:"database_dsn"`
+	CryptoKey        string  `json:"crypto_key"`
+	TrustedSubnet    string  `json:"trusted_subnet"`
+	TrustedProxies   string  `json:"trusted_proxies"`
+	KeyRingFile      string  `json:"key_ring_file"`
+	AuthTokensFile   string  `json:"auth_tokens_file"`
+	TLSCert          string  `json:"tls_cert"`
+	TLSKey           string  `json:"tls_key"`
+	TLSClientCA      string  `json:"tls_client_ca"`
+	ReplayWindow     string  `json:"replay_window"`
+	StrictSignature  bool    `json:"strict_signature"`
+	TenantQuota      int     `json:"tenant_quota"`
+	TenantQuotas     string  `json:"tenant_quotas"`
+	MaxBodySize      int64   `json:"max_body_size"`
+	MaxBatchSize     int     `json:"max_batch_size"`
+	MaxImportSize    int64   `json:"max_import_size"`
+	ClientRateLimit  float64 `json:"client_rate_limit"`
+	ClientRateBurst  int     `json:"client_rate_burst"`
+	ReservedPrefixes string  `json:"reserved_prefixes"`
+	HistorySize      int     `json:"history_size"`
+	AlertRulesFile   string  `json:"alert_rules_file"`
+	AlertInterval    string  `json:"alert_interval"`
+	NotifyConfig     string  `json:"notify_config"`
 }
@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"metrify/internal/alert"
+	models "metrify/internal/model"
+	"metrify/internal/service"
+)
+
+// ListAlerts godoc
+// @Summary      List alerts
+// @Description  Alerts of the tenant produced by the alert rules, sorted by rule and labels.
+// @Tags         alerts
+// @Produce      json
+// @Param        state query string false "Filter by state" Enums(pending, firing, resolved)
+// @Success      200 {object} models.Envelope{data=[]models.Alert}
+// @Failure      400 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /alerts [get]
+func (handler *Handler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	if !alert.ValidState(state) {
+		writeProblem(w, r, models.Problem{
+			Type:   models.ProblemInvalidFilter,
+			Title:  "Invalid filter",
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("unknown alert state %q (expect pending|firing|resolved)", state),
+		})
+		return
+	}
+
+	alerts := handler.Alerts.Alerts(service.TenantFromContext(r.Context()), state)
+	if alerts == nil {
+		alerts = []models.Alert{}
+	}
+
+	handler.writeData(w, http.StatusOK, alerts)
+}
@@ -0,0 +1,345 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"metrify/internal/audit"
+	models "metrify/internal/model"
+)
+
+// Форматы массового экспорта и импорта.
+const (
+	ContentTypeCSV    = "text/csv"
+	ContentTypeNDJSON = "application/x-ndjson"
+)
+
+// ImportPath — маршрут импорта. Для него вместо MaxBodySize действует MaxImportSize,
+// если тело не подписано и не зашифровано.
+const ImportPath = "/api/v2/import"
+
+// maxImportLine — предельная длина строки NDJSON при импорте.
+const maxImportLine = 64 << 10
+
+// importChunkSize — сколько строк импорта записывается и попадает в аудит за раз.
+const importChunkSize = 1000
+
+var csvHeader = []string{"id", "type", "value"}
+
+// ImportEvent — строка ответа импорта в формате NDJSON.
+type ImportEvent struct {
+	// Event — error, progress или summary.
+	Event     string `json:"event"`
+	Line      int    `json:"line,omitempty"`
+	ID        string `json:"id,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+	Processed int    `json:"processed"`
+	Applied   int    `json:"applied"`
+	Failed    int    `json:"failed"`
+}
+
+// ExportMetrics godoc
+// @Summary      Export metrics
+// @Description  Streams all current metrics as CSV (id,type,value) or NDJSON (one metric per line).
+// @Description  The format is taken from the format query parameter, then from Accept; NDJSON by default.
+// @Tags         v2
+// @Produce      text/csv,application/x-ndjson
+// @Param        format query string false "Export format" Enums(csv, ndjson)
+// @Success      200 {string} string
+// @Failure      400 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/export [get]
+func (handler *Handler) ExportMetrics(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(r)
+	if !ok {
+		httpError(w, r, "unknown export format (expect csv|ndjson)", http.StatusBadRequest)
+		return
+	}
+
+	metrics := handler.storage(r).List()
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", format)
+	ext := "ndjson"
+	if format == ContentTypeCSV {
+		ext = "csv"
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="metrics.%s"`, ext))
+	w.WriteHeader(http.StatusOK)
+
+	var err error
+	names := make([]string, 0, len(metrics))
+
+	if format == ContentTypeCSV {
+		cw := csv.NewWriter(w)
+		err = cw.Write(csvHeader)
+		for i := 0; err == nil && i < len(metrics); i++ {
+			err = cw.Write([]string{metrics[i].ID, metrics[i].MType, formatValue(metrics[i])})
+			names = append(names, metrics[i].ID)
+			if (i+1)%importChunkSize == 0 {
+				cw.Flush()
+				_ = rc.Flush()
+			}
+		}
+		cw.Flush()
+		if err == nil {
+			err = cw.Error()
+		}
+	} else {
+		enc := json.NewEncoder(w)
+		for i := 0; err == nil && i < len(metrics); i++ {
+			err = enc.Encode(metrics[i])
+			names = append(names, metrics[i].ID)
+			if (i+1)%importChunkSize == 0 {
+				_ = rc.Flush()
+			}
+		}
+	}
+
+	if err != nil {
+		handler.logger.Error("Error writing export", zap.Error(err))
+	}
+
+	handler.auditAction(r, audit.ActionExport, names)
+}
+
+// ImportMetrics godoc
+// @Summary      Import metrics
+// @Description  Streams CSV (id,type,value, header optional) or NDJSON metrics into storage in chunks.
+// @Description  Gauges are set, counters are added. The response is NDJSON: an error event per rejected line,
+// @Description  a progress event after every chunk and a final summary. NDJSON lines are limited to 64 KiB.
+// @Description  Signed or encrypted imports are read into memory and limited to the regular body size.
+// @Tags         v2
+// @Accept       text/csv,application/x-ndjson
+// @Produce      application/x-ndjson
+// @Success      200 {object} ImportEvent
+// @Failure      413 {object} models.Problem
+// @Failure      415 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/import [post]
+func (handler *Handler) ImportMetrics(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var next func() (models.Metrics, error)
+	switch mediaType {
+	case ContentTypeCSV:
+		next = csvRows(r.Body)
+	case ContentTypeNDJSON:
+		next = ndjsonRows(r.Body)
+	default:
+		httpError(w, r, "unsupported import format (expect text/csv|application/x-ndjson)", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	w.Header().Set("Content-Type", ContentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+
+	var total ImportEvent
+	chunk := make([]models.Metrics, 0, importChunkSize)
+	lines := make([]int, 0, importChunkSize)
+	line := 0
+
+	report := func(ev ImportEvent) {
+		ev.Processed, ev.Applied, ev.Failed = total.Processed, total.Applied, total.Failed
+		if err := enc.Encode(ev); err != nil {
+			handler.logger.Error("Error writing import progress", zap.Error(err))
+		}
+	}
+
+	apply := func() {
+		if len(chunk) == 0 {
+			return
+		}
+
+		result := handler.applyMetrics(r, chunk, audit.ActionImport)
+		total.Processed += len(chunk)
+		total.Failed += len(result.failed)
+		total.Applied += len(chunk) - len(result.failed)
+
+		for _, f := range result.failed {
+			report(ImportEvent{Event: "error", Line: lines[f.Index], ID: f.ID, Reason: f.Reason})
+		}
+		report(ImportEvent{Event: "progress"})
+		_ = rc.Flush()
+
+		chunk, lines = chunk[:0], lines[:0]
+	}
+
+	for {
+		metric, err := next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+
+		var rowErr *rowError
+		switch {
+		case errors.As(err, &rowErr):
+			total.Processed++
+			total.Failed++
+			report(ImportEvent{Event: "error", Line: line, Reason: rowErr.Error()})
+			continue
+		case errors.Is(err, errSkipRow):
+			continue
+		case err != nil:
+			// Тело больше нельзя читать: записываем накопленное и завершаем.
+			apply()
+			report(ImportEvent{Event: "error", Line: line, Reason: err.Error()})
+			report(ImportEvent{Event: "summary"})
+			return
+		}
+
+		chunk = append(chunk, metric)
+		lines = append(lines, line)
+		if len(chunk) == importChunkSize {
+			apply()
+		}
+	}
+
+	apply()
+	handler.flush(r)
+	report(ImportEvent{Event: "summary"})
+}
+
+// rowError — ошибка одной строки импорта, после которой чтение продолжается.
+type rowError struct {
+	err error
+}
+
+func (e *rowError) Error() string {
+	return e.err.Error()
+}
+
+// errSkipRow пропускает строку без ошибки: заголовок CSV или пустую строку NDJSON.
+var errSkipRow = errors.New("skip row")
+
+func csvRows(r io.Reader) func() (models.Metrics, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	first := true
+
+	return func() (models.Metrics, error) {
+		record, err := reader.Read()
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return models.Metrics{}, &rowError{err}
+		}
+		if err != nil {
+			return models.Metrics{}, err
+		}
+
+		if first {
+			first = false
+			if len(record) == len(csvHeader) && strings.EqualFold(strings.TrimSpace(record[0]), csvHeader[0]) {
+				return models.Metrics{}, errSkipRow
+			}
+		}
+
+		if len(record) != len(csvHeader) {
+			return models.Metrics{}, &rowError{fmt.Errorf("expected %d fields (id,type,value), got %d", len(csvHeader), len(record))}
+		}
+
+		metric, err := parseRow(strings.TrimSpace(record[0]), strings.TrimSpace(record[1]), strings.TrimSpace(record[2]))
+		if err != nil {
+			return metric, &rowError{err}
+		}
+
+		return metric, nil
+	}
+}
+
+func ndjsonRows(r io.Reader) func() (models.Metrics, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLine)
+
+	return func() (models.Metrics, error) {
+		if !scanner.Scan() {
+			err := scanner.Err()
+			switch {
+			case errors.Is(err, bufio.ErrTooLong):
+				return models.Metrics{}, fmt.Errorf("line exceeds %d bytes", maxImportLine)
+			case err != nil:
+				return models.Metrics{}, err
+			}
+			return models.Metrics{}, io.EOF
+		}
+		data := scanner.Bytes()
+
+		if strings.TrimSpace(string(data)) == "" {
+			return models.Metrics{}, errSkipRow
+		}
+
+		var metric models.Metrics
+		if err := json.Unmarshal(data, &metric); err != nil {
+			return metric, &rowError{err}
+		}
+
+		return metric, nil
+	}
+}
+
+func parseRow(id, mType, value string) (models.Metrics, error) {
+	metric := models.Metrics{ID: id, MType: mType}
+
+	switch mType {
+	case models.Gauge:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return metric, fmt.Errorf("invalid gauge value %q", value)
+		}
+		metric.Value = &v
+	case models.Counter:
+		d, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return metric, fmt.Errorf("invalid counter value %q", value)
+		}
+		metric.Delta = &d
+	}
+
+	// Неизвестный тип отклоняет валидатор вместе с остальными ошибками.
+	return metric, nil
+}
+
+func formatValue(metric models.Metrics) string {
+	if metric.MType == models.Gauge && metric.Value != nil {
+		return strconv.FormatFloat(*metric.Value, 'f', -1, 64)
+	}
+	if metric.Delta != nil {
+		return strconv.FormatInt(*metric.Delta, 10)
+	}
+
+	return ""
+}
+
+func exportFormat(r *http.Request) (string, bool) {
+	switch r.URL.Query().Get("format") {
+	case "csv":
+		return ContentTypeCSV, true
+	case "ndjson":
+		return ContentTypeNDJSON, true
+	case "":
+	default:
+		return "", false
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), ContentTypeCSV) {
+		return ContentTypeCSV, true
+	}
+
+	return ContentTypeNDJSON, true
+}
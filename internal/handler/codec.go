@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	gproto "google.golang.org/protobuf/proto"
+	models "metrify/internal/model"
+	"metrify/internal/proto"
+)
+
+// codec кодирует тела /updates/ и /value/. Формат запроса выбирается по
+// Content-Type, формат ответа — по Accept, а без него совпадает с запросом.
+type codec struct {
+	contentType  string
+	decodeBatch  func(io.Reader) ([]models.Metrics, error)
+	decodeMetric func(io.Reader) (models.Metrics, error)
+	encodeMetric func(models.Metrics) ([]byte, error)
+	encodeBatch  func([]models.Metrics, []models.MetricError) ([]byte, error)
+
+	// perItem — ответ на батч перечисляет результат каждой метрики, поэтому
+	// частичная ошибка кодируется им же, а не problem+json.
+	perItem bool
+	// invalidType — тип problem для тела, которое не удалось разобрать.
+	invalidType string
+}
+
+var jsonCodec = codec{
+	contentType: models.ContentTypeJSON,
+	invalidType: models.ProblemInvalidJSON,
+	decodeBatch: func(r io.Reader) ([]models.Metrics, error) {
+		var metrics []models.Metrics
+		err := json.NewDecoder(r).Decode(&metrics)
+		return metrics, err
+	},
+	decodeMetric: func(r io.Reader) (models.Metrics, error) {
+		var metric models.Metrics
+		err := json.NewDecoder(r).Decode(&metric)
+		return metric, err
+	},
+	encodeMetric: func(metric models.Metrics) ([]byte, error) {
+		data, err := json.Marshal(metric)
+		return append(data, '\n'), err
+	},
+	encodeBatch: func([]models.Metrics, []models.MetricError) ([]byte, error) {
+		return []byte(`{"status": "ok"}`), nil
+	},
+}
+
+// msgpack использует те же имена полей, что и JSON.
+var msgpackCodec = codec{
+	contentType: models.ContentTypeMsgpack,
+	invalidType: models.ProblemInvalidBody,
+	decodeBatch: func(r io.Reader) ([]models.Metrics, error) {
+		var metrics []models.Metrics
+		err := newMsgpackDecoder(r).Decode(&metrics)
+		return metrics, err
+	},
+	decodeMetric: func(r io.Reader) (models.Metrics, error) {
+		var metric models.Metrics
+		err := newMsgpackDecoder(r).Decode(&metric)
+		return metric, err
+	},
+	encodeMetric: func(metric models.Metrics) ([]byte, error) {
+		return marshalMsgpack(metric)
+	},
+	encodeBatch: func([]models.Metrics, []models.MetricError) ([]byte, error) {
+		return marshalMsgpack(map[string]string{"status": "ok"})
+	},
+}
+
+var protobufCodec = codec{
+	contentType: models.ContentTypeProtobuf,
+	invalidType: models.ProblemInvalidBody,
+	perItem:     true,
+	decodeBatch: func(r io.Reader) ([]models.Metrics, error) {
+		req := &proto.UpdateMetricsRequest{}
+		if err := unmarshalProto(r, req); err != nil {
+			return nil, err
+		}
+
+		metrics := make([]models.Metrics, 0, len(req.GetMetrics()))
+		for _, m := range req.GetMetrics() {
+			metrics = append(metrics, proto.ToModel(m))
+		}
+
+		return metrics, nil
+	},
+	decodeMetric: func(r io.Reader) (models.Metrics, error) {
+		m := &proto.Metric{}
+		if err := unmarshalProto(r, m); err != nil {
+			return models.Metrics{}, err
+		}
+
+		return proto.ToModel(m), nil
+	},
+	encodeMetric: func(metric models.Metrics) ([]byte, error) {
+		m, err := proto.FromModel(metric)
+		if err != nil {
+			return nil, err
+		}
+
+		return gproto.Marshal(m)
+	},
+	encodeBatch: func(metrics []models.Metrics, failed []models.MetricError) ([]byte, error) {
+		reasons := make(map[int]string, len(failed))
+		for _, f := range failed {
+			reasons[f.Index] = f.Reason
+		}
+
+		results := make([]*proto.MetricResult, 0, len(metrics))
+		for i, metric := range metrics {
+			result := &proto.MetricResult{}
+			result.SetIndex(int32(i))
+			result.SetId(metric.ID)
+			if reason, ok := reasons[i]; ok {
+				result.SetError(reason)
+			} else {
+				result.SetOk(true)
+			}
+			results = append(results, result)
+		}
+
+		resp := &proto.UpdateMetricsResponse{}
+		resp.SetResults(results)
+
+		return gproto.Marshal(resp)
+	},
+}
+
+var codecs = map[string]codec{
+	models.ContentTypeJSON:     jsonCodec,
+	models.ContentTypeProtobuf: protobufCodec,
+	"application/protobuf":     protobufCodec,
+	models.ContentTypeMsgpack:  msgpackCodec,
+	"application/x-msgpack":    msgpackCodec,
+	"application/vnd.msgpack":  msgpackCodec,
+}
+
+var bodyContentTypes = []string{
+	models.ContentTypeJSON,
+	models.ContentTypeProtobuf,
+	"application/protobuf",
+	models.ContentTypeMsgpack,
+	"application/x-msgpack",
+	"application/vnd.msgpack",
+}
+
+// requestCodec выбирает формат тела по Content-Type. Без Content-Type тело
+// считается JSON; для неизвестного типа ok == false, а c — JSON для ответа.
+func requestCodec(r *http.Request) (c codec, ok bool) {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return jsonCodec, true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return jsonCodec, false
+	}
+
+	if c, ok := codecs[mediaType]; ok {
+		return c, true
+	}
+
+	return jsonCodec, false
+}
+
+// unsupportedType отвечает 415 на тело, для которого нет кодека.
+func unsupportedType(w http.ResponseWriter, r *http.Request) {
+	httpError(w, r, fmt.Sprintf("unsupported content type %q", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+}
+
+// responseCodec выбирает первый поддерживаемый тип из Accept. Веса не
+// учитываются: клиенты перечисляют форматы в порядке предпочтения.
+func responseCodec(r *http.Request) codec {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+		if c, ok := codecs[mediaType]; ok {
+			return c
+		}
+	}
+
+	c, _ := requestCodec(r)
+
+	return c
+}
+
+// writeEncoded отвечает телом в формате c со статусом status.
+func (handler *Handler) writeEncoded(w http.ResponseWriter, c codec, status int, data []byte, err error) {
+	if err != nil {
+		handler.logger.Errorw("Error encoding response", "content_type", c.contentType, "error", err)
+		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", c.contentType)
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func newMsgpackDecoder(r io.Reader) *msgpack.Decoder {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+
+	return dec
+}
+
+func marshalMsgpack(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func unmarshalProto(r io.Reader, m gproto.Message) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return gproto.Unmarshal(data, m)
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"metrify/internal/service"
+)
+
+// etag — слабый ETag версии: сжатие меняет байты ответа, но не его смысл.
+func etag(v service.Version) string {
+	return `W/"` + strconv.FormatUint(v.Seq, 36) + `"`
+}
+
+// notModified выставляет ETag и Last-Modified версии v и отвечает 304, если
+// клиент уже получил эту версию. If-None-Match важнее If-Modified-Since (RFC 9110).
+// Возвращает true, если ответ записан.
+func notModified(w http.ResponseWriter, r *http.Request, v service.Version) bool {
+	tag := etag(v)
+	modified := v.Modified.UTC().Truncate(time.Second)
+
+	h := w.Header()
+	h.Set("ETag", tag)
+	h.Set("Last-Modified", modified.Format(http.TimeFormat))
+	h.Set("Cache-Control", "no-cache")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if !etagMatch(match, tag) {
+			return false
+		}
+	} else {
+		since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || modified.After(since) {
+			return false
+		}
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// etagMatch сравнивает список из If-None-Match со слабым тегом.
+func etagMatch(header, tag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	_ "embed"
+	"net/http"
+
+	models "metrify/internal/model"
+)
+
+// Дашборд — одна страница без внешних зависимостей: данные она берёт из /api/v2/history.
+//
+//go:embed dashboard/index.html
+var dashboardHTML []byte
+
+// GetInfo godoc
+// @Summary      Dashboard
+// @Description  Embedded HTML dashboard with metrics grouped by type and prefix. The page itself is public; it asks for a token and loads data from /api/v2/history.
+// @Tags         system
+// @Produce      html
+// @Success      200 {string} string
+// @Router       / [get]
+func (handler *Handler) GetInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(dashboardHTML)
+}
+
+// HistoryV2 godoc
+// @Summary      Metrics with recent history
+// @Description  Current values of all metrics with the last recorded values, oldest first. Counters report running totals.
+// @Tags         v2
+// @Produce      json
+// @Success      200 {object} models.Envelope{data=[]models.Series}
+// @Success      304 {string} string "Not modified"
+// @Security     BearerAuth
+// @Router       /api/v2/history [get]
+func (handler *Handler) HistoryV2(w http.ResponseWriter, r *http.Request) {
+	storage := handler.storage(r)
+	if notModified(w, r, handler.withSilences(r, storage.ListVersion())) {
+		return
+	}
+
+	metrics := storage.List()
+	handler.markSilenced(r, metrics)
+
+	series := make([]models.Series, 0, len(metrics))
+	for _, metric := range metrics {
+		history := storage.History(metric.MType, metric.ID)
+		if history == nil {
+			history = []models.Point{}
+		}
+		series = append(series, models.Series{Metrics: metric, History: history})
+	}
+
+	handler.writeData(w, http.StatusOK, series)
+}
@@ -5,12 +5,17 @@ import (
 	"crypto/rsa"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
+	"metrify/internal/alert"
 	"metrify/internal/audit"
+	"metrify/internal/auth"
 	models "metrify/internal/model"
 	"metrify/internal/service"
-	"net"
+	"metrify/internal/silence"
+	"metrify/internal/validation"
 	"net/http"
 	"strconv"
 	"strings"
@@ -24,12 +29,32 @@ type Handler struct {
 	audit              *audit.Publisher
 	dumpToFile         bool
 	AllowedContentType string
-	Key                string
+	Keys               *service.KeyRing
 	privKey            *rsa.PrivateKey
-	TrustedSubnet      string
+	TrustedSubnet      *service.TrustedSubnet
+	Replay             *service.ReplayGuard
+	Auth               *auth.Authenticator
+	// MaxBodySize и MaxBatchSize ограничивают размер тела (после распаковки)
+	// и число метрик в /updates/. Ноль снимает ограничение.
+	MaxBodySize  int64
+	MaxBatchSize int
+	// MaxImportSize заменяет MaxBodySize для /api/v2/import.
+	MaxImportSize int64
+	Limiter       *service.RateLimiter
+	Validator     *validation.Validator
+	// MinCompressSize — ответы короче отдаются без сжатия.
+	MinCompressSize int
+	// BodyContentTypes — типы тела, которые принимают /updates/ и /value/.
+	BodyContentTypes []string
+	// Health — проверки /readyz. Без них сервер считается готовым.
+	Health *service.Health
+	// Alerts — движок правил для /alerts; nil, если правила не заданы.
+	Alerts *alert.Engine
+	// Silences — окна обслуживания /api/v2/silences.
+	Silences *silence.Store
 }
 
-func NewHandler(ms service.Storage, logger *zap.SugaredLogger, db *sql.DB, audit *audit.Publisher, dump bool, key string, privKey *rsa.PrivateKey, trustedSubnet string) *Handler {
+func NewHandler(ms service.Storage, logger *zap.SugaredLogger, db *sql.DB, audit *audit.Publisher, dump bool, key string, privKey *rsa.PrivateKey, trustedSubnet *service.TrustedSubnet) *Handler {
 	return &Handler{
 		ms:                 ms,
 		logger:             logger,
@@ -37,9 +62,17 @@ func NewHandler(ms service.Storage, logger *zap.SugaredLogger, db *sql.DB, audit
 		audit:              audit,
 		dumpToFile:         dump,
 		AllowedContentType: "text/plain",
-		Key:                key,
+		BodyContentTypes:   bodyContentTypes,
+		Keys:               service.NewStaticKeyRing(key),
 		privKey:            privKey,
 		TrustedSubnet:      trustedSubnet,
+		Replay:             service.NewReplayGuard(service.DefaultReplayWindow, false),
+		MaxBodySize:        service.DefaultMaxBodySize,
+		MaxBatchSize:       service.DefaultMaxBatchSize,
+		MaxImportSize:      service.DefaultMaxImportSize,
+		Validator:          validation.New(),
+		MinCompressSize:    service.DefaultMinCompressSize,
+		Silences:           silence.NewStore(),
 	}
 }
 
@@ -49,12 +82,26 @@ func NewHandler(ms service.Storage, logger *zap.SugaredLogger, db *sql.DB, audit
 // @Produce      plain
 // @Param        name path string true "Metric name"
 // @Success      200 {string} string
+// @Header       200 {string} X-Silenced "ID of the active silence matching the metric"
+// @Success      304 {string} string "Not modified"
+// @Failure      400 {string} string
 // @Failure      404 {string} string
+// @Security     BearerAuth
 // @Router       /value/gauge/{name} [get]
 func (handler *Handler) GetGauge(w http.ResponseWriter, r *http.Request) {
 	metricName := chi.URLParam(r, "name")
 
-	val, ok := handler.ms.GetGauge(metricName)
+	if err := handler.Validator.Name(metricName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storage := handler.storage(r)
+	if v, ok := storage.Version(models.Gauge, metricName); ok && notModified(w, r, handler.withSilences(r, v)) {
+		return
+	}
+
+	val, ok := storage.GetGauge(metricName)
 
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
@@ -63,6 +110,9 @@ func (handler *Handler) GetGauge(w http.ResponseWriter, r *http.Request) {
 
 	data := strconv.FormatFloat(val, 'f', -1, 64)
 
+	if id := handler.silencedBy(r, models.Metrics{ID: metricName, MType: models.Gauge}); id != "" {
+		w.Header().Set(SilencedHeader, id)
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(data))
 }
@@ -73,12 +123,26 @@ func (handler *Handler) GetGauge(w http.ResponseWriter, r *http.Request) {
 // @Produce      plain
 // @Param        name path string true "Metric name"
 // @Success      200 {string} string
+// @Header       200 {string} X-Silenced "ID of the active silence matching the metric"
+// @Success      304 {string} string "Not modified"
+// @Failure      400 {string} string
 // @Failure      404 {string} string
+// @Security     BearerAuth
 // @Router       /value/counter/{name} [get]
 func (handler *Handler) GetCounter(w http.ResponseWriter, r *http.Request) {
 	metricName := chi.URLParam(r, "name")
 
-	val, ok := handler.ms.GetCounter(metricName)
+	if err := handler.Validator.Name(metricName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storage := handler.storage(r)
+	if v, ok := storage.Version(models.Counter, metricName); ok && notModified(w, r, handler.withSilences(r, v)) {
+		return
+	}
+
+	val, ok := storage.GetCounter(metricName)
 
 	if !ok {
 		w.WriteHeader(http.StatusNotFound)
@@ -87,53 +151,57 @@ func (handler *Handler) GetCounter(w http.ResponseWriter, r *http.Request) {
 
 	data := strconv.FormatInt(val, 10)
 
+	if id := handler.silencedBy(r, models.Metrics{ID: metricName, MType: models.Counter}); id != "" {
+		w.Header().Set(SilencedHeader, id)
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(data))
 }
 
 // GetMetrics godoc
-// @Summary      Get metric by JSON body
+// @Summary      Get metric by body
+// @Description  Accepts and returns JSON, protobuf (proto.Metric) or MessagePack; the response format follows Accept, defaulting to the request format.
 // @Tags         metrics
-// @Accept       json
-// @Produce      json
+// @Accept       json,application/x-protobuf,application/msgpack
+// @Produce      json,application/x-protobuf,application/msgpack
 // @Param        metric body models.Metrics true "Metric request"
 // @Success      200 {object} models.Metrics
+// @Failure      400 {string} string
 // @Failure      404 {string} string
+// @Failure      415 {string} string
+// @Security     BearerAuth
 // @Router       /value/ [post]
 func (handler *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
-	dec := json.NewDecoder(r.Body)
-	metric := models.Metrics{}
 	defer r.Body.Close()
 
-	if err := dec.Decode(&metric); err != nil {
-		handler.logger.Debug("Error decoding JSON", zap.Error(err))
+	c, ok := requestCodec(r)
+	if !ok {
+		unsupportedType(w, r)
+		return
 	}
 
-	if metric.MType == models.Gauge {
-		val, ok := handler.ms.GetGauge(metric.ID)
-
-		if !ok {
-			w.WriteHeader(http.StatusNotFound)
-			return
-		}
-
-		metric.Value = &val
-	} else {
-		val, ok := handler.ms.GetCounter(metric.ID)
-
-		if !ok {
-			w.WriteHeader(http.StatusNotFound)
-			return
+	metric, err := c.decodeMetric(r.Body)
+	if err != nil {
+		if !tooLarge(w, r, err) {
+			http.Error(w, "Error request body format", http.StatusBadRequest)
 		}
+		return
+	}
 
-		metric.Delta = &val
+	if err := handler.Validator.Query(metric); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(metric); err != nil {
-		handler.logger.Error("Error encoding JSON", zap.Error(err))
+	metric.Value, metric.Delta = nil, nil
+	if !handler.current(r, &metric) {
+		w.WriteHeader(http.StatusNotFound)
+		return
 	}
+
+	out := responseCodec(r)
+	data, err := out.encodeMetric(metric)
+	handler.writeEncoded(w, out, http.StatusOK, data, err)
 }
 
 // UpdateMetrics godoc
@@ -144,6 +212,7 @@ func (handler *Handler) GetMetrics(w http.ResponseWriter, r *http.Request) {
 // @Param        metric body models.Metrics true "Metric payload"
 // @Success      200 {object} map[string]string
 // @Failure      400 {string} string
+// @Security     BearerAuth
 // @Router       /update/ [post]
 func (handler *Handler) UpdateMetrics(w http.ResponseWriter, r *http.Request) {
 	dec := json.NewDecoder(r.Body)
@@ -151,28 +220,30 @@ func (handler *Handler) UpdateMetrics(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err := dec.Decode(&metric); err != nil {
-		handler.logger.Debug("Error decoding JSON", zap.Error(err))
+		if !tooLarge(w, r, err) {
+			http.Error(w, "Error JSON format", http.StatusBadRequest)
+		}
+		return
 	}
 
-	if metric.Value == nil && metric.Delta == nil {
-		http.Error(w, "Error JSON format", http.StatusBadRequest)
+	if err := handler.Validator.Metric(metric); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	var err error
 	if metric.MType == models.Gauge {
-		handler.ms.UpdateGauge(metric.ID, *metric.Value)
+		err = handler.storage(r).UpdateGauge(metric.ID, *metric.Value)
 	} else {
-		handler.ms.UpdateCounter(metric.ID, *metric.Delta)
+		err = handler.storage(r).UpdateCounter(metric.ID, *metric.Delta)
 	}
 
-	if handler.dumpToFile {
-		err := handler.ms.FlushToFile()
-
-		if err != nil {
-			handler.logger.Error("Error flushing to file", zap.Error(err))
-		}
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
 
+	handler.flush(r)
 	handler.auditMetrics(r, []string{metric.ID})
 
 	w.Header().Set("Content-Type", "application/json")
@@ -182,58 +253,208 @@ func (handler *Handler) UpdateMetrics(w http.ResponseWriter, r *http.Request) {
 
 // UpdateMetricsBatch godoc
 // @Summary      Batch update metrics
+// @Description  Correct metrics are applied even if some of the batch fails; failed ones are listed in the problem errors.
+// @Description  The body is JSON, protobuf (proto.UpdateMetricsRequest) or MessagePack by Content-Type.
+// @Description  A protobuf response is proto.UpdateMetricsResponse with a result per metric, also on partial failure (with the problem status).
+// @Description  Bodies that fail to decode are reported as /problems/invalid-json for JSON and /problems/invalid-body otherwise.
 // @Tags         metrics
-// @Accept       json
-// @Produce      json
+// @Accept       json,application/x-protobuf,application/msgpack
+// @Produce      json,application/x-protobuf,application/msgpack
 // @Param        metrics body []models.Metrics true "Metrics array"
 // @Success      200 {object} map[string]string
-// @Failure      400 {object} map[string]any
+// @Failure      400 {object} models.Problem
+// @Failure      403 {object} models.Problem
+// @Failure      413 {object} models.Problem
+// @Failure      415 {string} string
+// @Failure      429 {string} string
+// @Failure      500 {object} models.Problem
+// @Security     BearerAuth
 // @Router       /updates/ [post]
 func (handler *Handler) UpdateMetricsBatch(w http.ResponseWriter, r *http.Request) {
-	dec := json.NewDecoder(r.Body)
-	var metrics []models.Metrics
-	var err error = nil
-	var errs []error
+	metrics, ok := handler.decodeBatch(w, r)
+	if !ok {
+		return
+	}
+
+	c := responseCodec(r)
+	status, failed := http.StatusOK, []models.MetricError(nil)
+
+	if problem := handler.applyBatch(r, metrics); problem != nil {
+		if !c.perItem {
+			writeProblem(w, r, *problem)
+			return
+		}
+		status, failed = problem.Status, problem.Errors
+	}
+
+	data, err := c.encodeBatch(metrics, failed)
+	handler.writeEncoded(w, c, status, data, err)
+}
+
+// decodeBatch читает массив метрик из тела в формате Content-Type и проверяет
+// MaxBatchSize. При ошибке ответ уже записан.
+func (handler *Handler) decodeBatch(w http.ResponseWriter, r *http.Request) ([]models.Metrics, bool) {
 	defer r.Body.Close()
-	names := make([]string, 0, len(metrics))
 
-	if err = dec.Decode(&metrics); err != nil {
-		handler.logger.Debug("Error decoding JSON", zap.Error(err))
+	c, ok := requestCodec(r)
+	if !ok {
+		unsupportedType(w, r)
+		return nil, false
 	}
 
-	for _, metric := range metrics {
-		names = append(names, metric.ID)
+	metrics, err := c.decodeBatch(r.Body)
+	if err != nil {
+		if tooLarge(w, r, err) {
+			return nil, false
+		}
+		writeProblem(w, r, models.Problem{
+			Type:   c.invalidType,
+			Title:  "Request body is not an array of metrics",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+		})
+		return nil, false
+	}
 
-		if metric.MType == models.Gauge {
-			err = handler.ms.UpdateGauge(metric.ID, *metric.Value)
+	if handler.MaxBatchSize > 0 && len(metrics) > handler.MaxBatchSize {
+		writeProblem(w, r, models.Problem{
+			Type:   models.ProblemBatchTooLarge,
+			Title:  "Batch is too large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("batch of %d metrics exceeds the limit of %d", len(metrics), handler.MaxBatchSize),
+		})
+		return nil, false
+	}
 
-			if err != nil {
-				errs = append(errs, err)
-			}
+	return metrics, true
+}
+
+// applyBatch записывает корректные метрики батча и возвращает описание
+// отклонённых или nil, если записаны все.
+func (handler *Handler) applyBatch(r *http.Request, metrics []models.Metrics) *models.Problem {
+	result := handler.applyMetrics(r, metrics, "")
+	if len(result.failed) == 0 {
+		return nil
+	}
+
+	problem := &models.Problem{
+		Type:   models.ProblemInvalidMetrics,
+		Title:  "Some metrics were not updated",
+		Status: http.StatusBadRequest,
+		Detail: fmt.Sprintf("%d of %d metrics failed", len(result.failed), len(metrics)),
+		Errors: result.failed,
+	}
+
+	switch {
+	case result.internal > 0:
+		problem.Type, problem.Status = "about:blank", http.StatusInternalServerError
+	case result.invalid == 0 && result.quota > 0:
+		problem.Type, problem.Status = models.ProblemQuotaExceeded, http.StatusForbidden
+	}
+
+	return problem
+}
+
+// batchResult — итог записи батча: отклонённые метрики и число ошибок по видам.
+type batchResult struct {
+	failed                   []models.MetricError
+	invalid, quota, internal int
+}
+
+// applyMetrics проверяет и записывает метрики по одной. Записанные попадают
+// в аудит с действием action.
+func (handler *Handler) applyMetrics(r *http.Request, metrics []models.Metrics, action string) batchResult {
+	names := make([]string, 0, len(metrics))
+	var result batchResult
+
+	for i, metric := range metrics {
+		err := handler.Validator.Metric(metric)
+		if err != nil {
+			result.invalid++
 		} else {
-			err = handler.ms.UpdateCounter(metric.ID, *metric.Delta)
+			if metric.MType == models.Gauge {
+				err = handler.storage(r).UpdateGauge(metric.ID, *metric.Value)
+			} else {
+				err = handler.storage(r).UpdateCounter(metric.ID, *metric.Delta)
+			}
 
-			if err != nil {
-				errs = append(errs, err)
+			switch {
+			case err == nil:
+				names = append(names, metric.ID)
+			case errors.Is(err, service.ErrQuotaExceeded):
+				result.quota++
+			default:
+				result.internal++
 			}
 		}
+
+		if err != nil {
+			result.failed = append(result.failed, models.MetricError{Index: i, ID: metric.ID, Reason: err.Error()})
+		}
 	}
 
-	if len(errs) > 0 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
+	handler.auditAction(r, action, names)
 
-		json.NewEncoder(w).Encode(map[string]any{
-			"errors": errs,
-		})
+	return result
+}
+
+// flush сохраняет метрики в файл, если включена синхронная запись.
+func (handler *Handler) flush(r *http.Request) {
+	if !handler.dumpToFile {
 		return
 	}
 
-	handler.auditMetrics(r, names)
+	if err := handler.storage(r).FlushToFile(); err != nil {
+		handler.logger.Error("Error flushing to file", zap.Error(err))
+	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status": "ok"}`))
+// V2Prefix — префикс API v2: все его ошибки, включая ошибки middleware, — problem+json.
+const V2Prefix = "/api/v2"
+
+// statusProblems — типы ошибок middleware и маршрутизации по статусу ответа.
+var statusProblems = map[int]string{
+	http.StatusBadRequest:            models.ProblemBadRequest,
+	http.StatusUnauthorized:          models.ProblemUnauthorized,
+	http.StatusForbidden:             models.ProblemForbidden,
+	http.StatusNotFound:              models.ProblemRouteNotFound,
+	http.StatusMethodNotAllowed:      models.ProblemMethodNotAllowed,
+	http.StatusRequestEntityTooLarge: models.ProblemBodyTooLarge,
+	http.StatusUnsupportedMediaType:  models.ProblemUnsupportedMediaType,
+	http.StatusTooManyRequests:       models.ProblemRateLimited,
+	http.StatusInternalServerError:   models.ProblemInternal,
+}
+
+// httpError отвечает ошибкой middleware: под V2Prefix — problem+json,
+// на остальных маршрутах — text/plain, как http.Error.
+func httpError(w http.ResponseWriter, r *http.Request, detail string, status int) {
+	if r.URL.Path != V2Prefix && !strings.HasPrefix(r.URL.Path, V2Prefix+"/") {
+		http.Error(w, detail, status)
+		return
+	}
+
+	problemType, ok := statusProblems[status]
+	if !ok {
+		problemType = "about:blank"
+	}
+
+	writeProblem(w, r, models.Problem{
+		Type:   problemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// writeProblem отвечает ошибкой в формате application/problem+json.
+func writeProblem(w http.ResponseWriter, r *http.Request, problem models.Problem) {
+	if problem.Instance == "" {
+		problem.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", models.ProblemContentType)
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
 }
 
 // UpdateGauge godoc
@@ -245,6 +466,7 @@ func (handler *Handler) UpdateMetricsBatch(w http.ResponseWriter, r *http.Reques
 // @Param        value path number  true "Gauge value"
 // @Success      200 {string} string
 // @Failure      400 {string} string
+// @Security     BearerAuth
 // @Router       /update/gauge/{name}/{value} [post]
 func (handler *Handler) UpdateGauge(w http.ResponseWriter, r *http.Request) {
 	metricName := chi.URLParam(r, "name")
@@ -255,7 +477,14 @@ func (handler *Handler) UpdateGauge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handler.ms.UpdateGauge(metricName, metricValue)
+	if err := errors.Join(handler.Validator.Name(metricName), handler.Validator.Gauge(metricValue)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := handler.storage(r).UpdateGauge(metricName, metricValue); errors.Is(err, service.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
 }
 
 // UpdateCounter godoc
@@ -267,6 +496,7 @@ func (handler *Handler) UpdateGauge(w http.ResponseWriter, r *http.Request) {
 // @Param        value path int64  true "Delta value"
 // @Success      200 {string} string
 // @Failure      400 {string} string
+// @Security     BearerAuth
 // @Router       /update/counter/{name}/{value} [post]
 func (handler *Handler) UpdateCounter(w http.ResponseWriter, r *http.Request) {
 	metricName := chi.URLParam(r, "name")
@@ -277,7 +507,15 @@ func (handler *Handler) UpdateCounter(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	handler.ms.UpdateCounter(metricName, metricValue)
+	if err := handler.Validator.Name(metricName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := handler.storage(r).UpdateCounter(metricName, metricValue); errors.Is(err, service.ErrQuotaExceeded) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -286,24 +524,13 @@ func (handler *Handler) UpdateCounter(w http.ResponseWriter, r *http.Request) {
 // @Summary      Invalid metric type
 // @Tags         metrics
 // @Failure      400 {string} string
+// @Security     BearerAuth
 // @Router       /update/{type}/{name}/{value} [post]
 // @Router       /value/{type}/{name} [get]
 func (handler *Handler) InvalidMetricHandler(w http.ResponseWriter, r *http.Request) {
 	http.Error(w, "invalid metric type (expect counter|gauge)", http.StatusBadRequest)
 }
 
-// GetInfo godoc
-// @Summary      Service info
-// @Tags         system
-// @Produce      html
-// @Success      200 {string} string
-// @Router       / [get]
-func (handler *Handler) GetInfo(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("OK"))
-}
-
 // Ping godoc
 // @Summary      Database ping
 // @Tags         system
@@ -331,27 +558,88 @@ func (handler *Handler) Ping(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status": "ok"}`))
 }
 
+// Healthz godoc
+// @Summary      Liveness probe
+// @Description  Always returns ok while the process is serving requests.
+// @Tags         system
+// @Produce      json
+// @Success      200 {object} models.HealthReport
+// @Router       /healthz [get]
+func (handler *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, handler.logger, models.HealthReport{Status: models.HealthOK})
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  Reports every readiness check: storage restore, database, file store, gRPC listener.
+// @Tags         system
+// @Produce      json
+// @Success      200 {object} models.HealthReport
+// @Failure      503 {object} models.HealthReport
+// @Router       /readyz [get]
+func (handler *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+
+	writeHealth(w, handler.logger, handler.Health.Check(ctx))
+}
+
+func writeHealth(w http.ResponseWriter, logger *zap.SugaredLogger, report models.HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != models.HealthOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logger.Error("Error writing health report", zap.Error(err))
+	}
+}
+
 func (handler *Handler) auditMetrics(r *http.Request, metricNames []string) {
-	if handler.audit == nil || !handler.audit.Enabled() || len(metricNames) == 0 {
+	handler.auditAction(r, "", metricNames)
+}
+
+// auditAction публикует событие аудита. Пустой action означает обновление метрик.
+func (handler *Handler) auditAction(r *http.Request, action string, metricNames []string) {
+	if len(metricNames) == 0 {
 		return
 	}
 
-	ip := clientIP(r)
-	ev := audit.NewEvent(metricNames, ip)
+	ev := audit.NewEvent(metricNames, "")
+	ev.Action = action
+
+	handler.publishAudit(r, ev)
+}
+
+// publishAudit дополняет событие адресом, именем токена и арендатором запроса и публикует его.
+func (handler *Handler) publishAudit(r *http.Request, ev audit.Event) {
+	if handler.audit == nil || !handler.audit.Enabled() {
+		return
+	}
+
+	ev.IPAddress = handler.clientIP(r)
+	if id, ok := auth.FromContext(r.Context()); ok {
+		ev.Identity = id.Name
+	}
+	ev.Tenant = service.TenantFromContext(r.Context())
 
 	if err := handler.audit.Publish(r.Context(), ev); err != nil {
 		handler.logger.Warn("audit publish error", zap.Error(err))
 	}
 }
 
-func clientIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		return strings.TrimSpace(parts[0])
-	}
-	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
-	if err == nil && host != "" {
-		return host
+// storage возвращает хранилище арендатора запроса.
+func (handler *Handler) storage(r *http.Request) service.Storage {
+	return handler.ms.ForTenant(service.TenantFromContext(r.Context()))
+}
+
+// clientIP возвращает адрес клиента для аудита по тем же правилам, что и WithTrustedSubnet.
+func (handler *Handler) clientIP(r *http.Request) string {
+	ip, err := handler.TrustedSubnet.ClientIP(r.RemoteAddr, r.Header.Get("X-Real-IP"), r.Header.Get("X-Forwarded-For"))
+	if err != nil {
+		return strings.TrimSpace(r.RemoteAddr)
 	}
-	return strings.TrimSpace(r.RemoteAddr)
+
+	return ip.String()
 }
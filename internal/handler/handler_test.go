@@ -3,19 +3,29 @@ package handler
 import (
 	"bytes"
 	"compress/gzip"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"io"
 	"metrify/internal/audit"
+	"metrify/internal/auth"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
+	gproto "google.golang.org/protobuf/proto"
 	models "metrify/internal/model"
+	"metrify/internal/proto"
 	"metrify/internal/service"
+	"metrify/internal/validation"
 )
 
 func newTestStorage() *service.MemStorage {
@@ -29,7 +39,7 @@ func newTestHandler() (*Handler, *service.MemStorage) {
 	ms := newTestStorage()
 	logger := zap.NewNop().Sugar()
 	p := audit.NewPublisher()
-	h := NewHandler(ms, logger, nil, p, false, "", nil, "")
+	h := NewHandler(ms, logger, nil, p, false, "", nil, nil)
 	return h, ms
 }
 
@@ -174,6 +184,166 @@ func TestHandler_UpdateMetricsBatch(t *testing.T) {
 	}
 }
 
+func TestHandler_UpdateMetricsBatch_Protobuf(t *testing.T) {
+	h, ms := newTestHandler()
+
+	gauge := &proto.Metric{}
+	gauge.SetId("temp")
+	gauge.SetValue(2.5)
+	unknown := &proto.Metric{}
+	unknown.SetId("x")
+	unknown.SetType(proto.Metric_MType(7))
+
+	batch := &proto.UpdateMetricsRequest{}
+	batch.SetMetrics([]*proto.Metric{gauge})
+	body, _ := gproto.Marshal(batch)
+
+	req := httptest.NewRequest("POST", "/updates/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", models.ContentTypeProtobuf)
+	rr := httptest.NewRecorder()
+	h.UpdateMetricsBatch(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != models.ContentTypeProtobuf {
+		t.Fatalf("status = %d, Content-Type = %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	resp := &proto.UpdateMetricsResponse{}
+	if err := gproto.Unmarshal(rr.Body.Bytes(), resp); err != nil || len(resp.GetResults()) != 1 || !resp.GetResults()[0].GetOk() {
+		t.Fatalf("unexpected response %v: %v", resp, err)
+	}
+	if v, ok := ms.GetGauge("temp"); !ok || v != 2.5 {
+		t.Fatalf("gauge = %v, %v; want 2.5", v, ok)
+	}
+
+	batch.SetMetrics([]*proto.Metric{gauge, unknown})
+	body, _ = gproto.Marshal(batch)
+
+	req = httptest.NewRequest("POST", "/updates/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", models.ContentTypeProtobuf)
+	rr = httptest.NewRecorder()
+	h.UpdateMetricsBatch(rr, req)
+
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != models.ContentTypeProtobuf {
+		t.Fatalf("status = %d, Content-Type = %q; want 400 with protobuf body", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	resp = &proto.UpdateMetricsResponse{}
+	if err := gproto.Unmarshal(rr.Body.Bytes(), resp); err != nil || len(resp.GetResults()) != 2 {
+		t.Fatalf("unexpected response %v: %v", resp, err)
+	}
+	if results := resp.GetResults(); !results[0].GetOk() || results[1].GetOk() || results[1].GetIndex() != 1 || results[1].GetError() == "" {
+		t.Fatalf("results = %v; want unknown type rejected per item", results)
+	}
+
+	req = httptest.NewRequest("POST", "/updates/", strings.NewReader("\xff"))
+	req.Header.Set("Content-Type", models.ContentTypeProtobuf)
+	rr = httptest.NewRecorder()
+	h.UpdateMetricsBatch(rr, req)
+
+	var problem models.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+	if rr.Code != http.StatusBadRequest || problem.Type != models.ProblemInvalidBody {
+		t.Fatalf("status = %d, type = %q; want 400 %s", rr.Code, problem.Type, models.ProblemInvalidBody)
+	}
+}
+
+func TestHandler_UpdateMetricsBatch_EncryptedProtobuf(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	h, ms := newTestHandler()
+	h.privKey = privKey
+	wrapped := h.WithDecrypt(http.HandlerFunc(h.UpdateMetricsBatch))
+
+	gauge := &proto.Metric{}
+	gauge.SetId("temp")
+	gauge.SetType(proto.Metric_GAUGE)
+	gauge.SetValue(2.5)
+	batch := &proto.UpdateMetricsRequest{}
+	batch.SetMetrics([]*proto.Metric{gauge})
+	plain, _ := gproto.Marshal(batch)
+	body, err := service.EncryptHybrid(&privKey.PublicKey, plain)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/updates/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", models.ContentTypeProtobuf)
+	req.Header.Set("Content-Encryption", service.EncryptionHybrid)
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != models.ContentTypeProtobuf {
+		t.Fatalf("status = %d, Content-Type = %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if v, ok := ms.GetGauge("temp"); !ok || v != 2.5 {
+		t.Fatalf("gauge = %v, %v; want 2.5", v, ok)
+	}
+}
+
+func TestHandler_UnsupportedBodyType(t *testing.T) {
+	h, _ := newTestHandler()
+
+	for name, serve := range map[string]http.HandlerFunc{
+		"/updates/": h.UpdateMetricsBatch,
+		"/value/":   h.GetMetrics,
+	} {
+		req := httptest.NewRequest("POST", name, strings.NewReader(`{"id":"Alloc","type":"gauge","value":1}`))
+		req.Header.Set("Content-Type", "application/octet-stream")
+		rr := httptest.NewRecorder()
+		serve(rr, req)
+
+		if rr.Code != http.StatusUnsupportedMediaType {
+			t.Fatalf("%s: status = %d, want 415", name, rr.Code)
+		}
+	}
+}
+
+func TestHandler_UpdateMetricsBatch_Problems(t *testing.T) {
+	h, ms := newTestHandler()
+
+	req := httptest.NewRequest("POST", "/updates/", strings.NewReader(`{"id":`))
+	rr := httptest.NewRecorder()
+	h.UpdateMetricsBatch(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 for undecodable body", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != models.ProblemContentType {
+		t.Fatalf("Content-Type = %q, want %q", ct, models.ProblemContentType)
+	}
+
+	body := `[{"id":"temp","type":"gauge","value":1},{"id":"hits","type":"counter"},{"id":"x","type":"histogram","value":1}]`
+	req = httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+	rr = httptest.NewRecorder()
+	h.UpdateMetricsBatch(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rr.Code)
+	}
+
+	var problem models.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("decode problem: %v", err)
+	}
+
+	want := []models.MetricError{
+		{Index: 1, ID: "hits", Reason: "invalid metric: counter delta is missing"},
+		{Index: 2, ID: "x", Reason: `invalid metric: unknown metric type "histogram" (expect counter|gauge)`},
+	}
+	if problem.Type != models.ProblemInvalidMetrics || problem.Status != http.StatusBadRequest || problem.Instance != "/updates/" {
+		t.Fatalf("unexpected problem: %+v", problem)
+	}
+	if len(problem.Errors) != len(want) || problem.Errors[0] != want[0] || problem.Errors[1] != want[1] {
+		t.Fatalf("errors = %+v, want %+v", problem.Errors, want)
+	}
+	if v, ok := ms.GetGauge("temp"); !ok || v != 1 {
+		t.Fatal("valid metric of the batch must be applied")
+	}
+}
+
 func TestHandler_UpdateGauge_InvalidValue(t *testing.T) {
 	h, _ := newTestHandler()
 
@@ -243,8 +413,9 @@ func TestHandler_WithRequestCompress(t *testing.T) {
 func TestHandler_WithResponseCompress(t *testing.T) {
 	h, _ := newTestHandler()
 
+	body := strings.Repeat("pong", 512)
 	base := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("pong"))
+		w.Write([]byte(body))
 	})
 
 	wrapped := h.WithResponseCompress(base)
@@ -262,8 +433,75 @@ func TestHandler_WithResponseCompress(t *testing.T) {
 	gzr, _ := gzip.NewReader(bytes.NewReader(rr.Body.Bytes()))
 	out, _ := io.ReadAll(gzr)
 
-	if string(out) != "pong" {
-		t.Fatalf("decoded=%q want pong", string(out))
+	if string(out) != body {
+		t.Fatalf("decoded=%q want %q", string(out), body)
+	}
+}
+
+func TestHandler_WithResponseCompress_Negotiation(t *testing.T) {
+	h, _ := newTestHandler()
+
+	wrapped := h.WithResponseCompress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("pong", 512)))
+	}))
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{accept: "gzip, zstd", want: "zstd"},
+		{accept: "gzip;q=1, deflate;q=0.5", want: "gzip"},
+		{accept: "br", want: ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", tt.accept)
+		rr := httptest.NewRecorder()
+
+		wrapped.ServeHTTP(rr, req)
+
+		if got := rr.Header().Get("Content-Encoding"); got != tt.want {
+			t.Fatalf("Accept-Encoding %q: Content-Encoding = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_WithRequestCompress_Encodings(t *testing.T) {
+	h, _ := newTestHandler()
+
+	var received string
+	wrapped := h.WithRequestCompress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = string(data)
+	}))
+
+	for _, encoding := range []string{service.EncodingZstd, service.EncodingDeflate} {
+		body, err := service.Compress(encoding, []byte("hello"))
+		if err != nil {
+			t.Fatalf("compress: %v", err)
+		}
+
+		req := httptest.NewRequest("POST", "/comp", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		rr := httptest.NewRecorder()
+		received = ""
+
+		wrapped.ServeHTTP(rr, req)
+
+		if received != "hello" {
+			t.Fatalf("%s: received=%q want hello", encoding, received)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/comp", strings.NewReader("hello"))
+	req.Header.Set("Content-Encoding", "br")
+	rr := httptest.NewRecorder()
+
+	wrapped.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("status = %d want 415", rr.Code)
 	}
 }
 
@@ -279,3 +517,555 @@ func TestHandler_Ping_DBNil(t *testing.T) {
 		t.Fatalf("status = %d want 500", rr.Code)
 	}
 }
+
+func TestHandler_WithDecrypt(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	h, _ := newTestHandler()
+	h.privKey = privKey
+
+	var received, receivedType string
+	wrapped := h.WithDecrypt(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received, receivedType = string(data), r.Header.Get("Content-Type")
+	}))
+
+	large := strings.Repeat("x", 64*1024)
+	hybrid, _ := service.EncryptHybrid(&privKey.PublicKey, []byte(large))
+	legacy, _ := service.EncryptPKCS1v15(&privKey.PublicKey, []byte("hello"))
+
+	tests := []struct {
+		name       string
+		encryption string
+		body       []byte
+		ct         string
+		wantStatus int
+		want       string
+		wantType   string
+	}{
+		{name: "plain", body: []byte("plain"), wantStatus: http.StatusOK, want: "plain"},
+		{name: "hybrid", encryption: service.EncryptionHybrid, body: hybrid, ct: models.ContentTypeProtobuf, wantStatus: http.StatusOK, want: large, wantType: models.ContentTypeProtobuf},
+		{name: "legacy", encryption: service.EncryptionRSAPKCS1v15, body: []byte(base64.StdEncoding.EncodeToString(legacy)), ct: "application/octet-stream", wantStatus: http.StatusOK, want: "hello", wantType: models.ContentTypeJSON},
+		{name: "unknown scheme", encryption: "ROT13", body: []byte("uryyb"), wantStatus: http.StatusBadRequest},
+		{name: "tampered", encryption: service.EncryptionHybrid, body: append(hybrid[:len(hybrid)-1:len(hybrid)-1], hybrid[len(hybrid)-1]^1), wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received, receivedType = "", ""
+
+			req := httptest.NewRequest("POST", "/updates", bytes.NewReader(tt.body))
+			if tt.encryption != "" {
+				req.Header.Set("Content-Encryption", tt.encryption)
+			}
+			if tt.ct != "" {
+				req.Header.Set("Content-Type", tt.ct)
+			}
+			rr := httptest.NewRecorder()
+
+			wrapped.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status = %d want %d", rr.Code, tt.wantStatus)
+			}
+			if received != tt.want {
+				t.Fatalf("received %d bytes, want %d", len(received), len(tt.want))
+			}
+			if receivedType != tt.wantType {
+				t.Fatalf("Content-Type = %q, want %q", receivedType, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestHandler_WithHashedRequest(t *testing.T) {
+	h, _ := newTestHandler()
+	h.Keys = service.NewStaticKeyRing("secret")
+
+	wrapped := h.WithHashedRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	body := []byte(`{"id":"PollCount","type":"counter","delta":1}`)
+	ts := service.FormatTimestamp(time.Now())
+
+	send := func(hash, timestamp, nonce string) int {
+		req := httptest.NewRequest("POST", "/update", bytes.NewReader(body))
+		req.Header.Set("HashSHA256", hash)
+		if timestamp != "" {
+			req.Header.Set(service.TimestampHeader, timestamp)
+		}
+		if nonce != "" {
+			req.Header.Set(service.NonceHeader, nonce)
+		}
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	signed := service.SignRequest("POST", "/update", ts, "n1", body, "secret")
+
+	if code := send(signed, ts, "n1"); code != http.StatusOK {
+		t.Fatalf("signed request: status = %d want 200", code)
+	}
+	if code := send(signed, ts, "n1"); code != http.StatusUnauthorized {
+		t.Fatalf("replayed request: status = %d want 401", code)
+	}
+
+	otherPath := service.SignRequest("POST", "/updates", ts, "n2", body, "secret")
+	if code := send(otherPath, ts, "n2"); code != http.StatusUnauthorized {
+		t.Fatalf("signature for other path: status = %d want 401", code)
+	}
+
+	query := service.SignRequest("GET", "/alerts?state=firing", ts, "n4", nil, "secret")
+	for target, want := range map[string]int{"/alerts?state=firing": http.StatusOK, "/alerts?state=resolved": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", target, nil)
+		req.Header.Set("HashSHA256", query)
+		req.Header.Set(service.TimestampHeader, ts)
+		req.Header.Set(service.NonceHeader, "n4")
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Fatalf("signed GET %s: status = %d want %d", target, rr.Code, want)
+		}
+	}
+
+	old := service.FormatTimestamp(time.Now().Add(-time.Hour))
+	stale := service.SignRequest("POST", "/update", old, "n3", body, "secret")
+	if code := send(stale, old, "n3"); code != http.StatusUnauthorized {
+		t.Fatalf("stale request: status = %d want 401", code)
+	}
+
+	legacy := service.SignData(body, "secret")
+	if code := send(legacy, "", ""); code != http.StatusOK {
+		t.Fatalf("legacy request: status = %d want 200", code)
+	}
+
+	if code := send("", "", ""); code != http.StatusOK {
+		t.Fatalf("unsigned request: status = %d want 200", code)
+	}
+
+	h.Replay = service.NewReplayGuard(time.Minute, true)
+	if code := send(legacy, "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("legacy request in strict mode: status = %d want 401", code)
+	}
+	// Иначе повтор перехваченного запроса без заголовков подписи обходит защиту.
+	if code := send("", "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request in strict mode: status = %d want 401", code)
+	}
+
+	req := httptest.NewRequest("GET", "/value/counter/PollCount", nil)
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unsigned read in strict mode: status = %d want 200", rr.Code)
+	}
+}
+
+func TestHandler_WithHashedRequest_KeyID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	_ = os.WriteFile(path, []byte(`{"keys":[{"id":"next","secret":"rotated"}]}`), 0o600)
+
+	keys, err := service.NewKeyRing(path, "secret")
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+
+	h, _ := newTestHandler()
+	h.Keys = keys
+
+	wrapped := h.WithHashedRequest(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	body := []byte(`{}`)
+
+	tests := []struct {
+		name  string
+		keyID string
+		key   string
+		want  int
+	}{
+		{name: "default key", keyID: "", key: "secret", want: http.StatusOK},
+		{name: "rotated key", keyID: "next", key: "rotated", want: http.StatusOK},
+		{name: "key does not match id", keyID: "next", key: "secret", want: http.StatusUnauthorized},
+		{name: "unknown key id", keyID: "gone", key: "secret", want: http.StatusUnauthorized},
+	}
+
+	noDefault, err := service.NewKeyRing(path, "")
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := service.FormatTimestamp(time.Now())
+			nonce := service.NewNonce()
+
+			req := httptest.NewRequest("POST", "/update", bytes.NewReader(body))
+			req.Header.Set("HashSHA256", service.SignRequest("POST", "/update", ts, nonce, body, tt.key))
+			req.Header.Set(service.TimestampHeader, ts)
+			req.Header.Set(service.NonceHeader, nonce)
+			if tt.keyID != "" {
+				req.Header.Set(service.KeyIDHeader, tt.keyID)
+			}
+			rr := httptest.NewRecorder()
+
+			wrapped.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d want %d", rr.Code, tt.want)
+			}
+		})
+	}
+
+	// Без KEY запрос без X-Key-ID, подписанный пустым ключом, не проходит.
+	h.Keys = noDefault
+	ts := service.FormatTimestamp(time.Now())
+	nonce := service.NewNonce()
+	req := httptest.NewRequest("POST", "/update", bytes.NewReader(body))
+	req.Header.Set("HashSHA256", service.SignRequest("POST", "/update", ts, nonce, body, ""))
+	req.Header.Set(service.TimestampHeader, ts)
+	req.Header.Set(service.NonceHeader, nonce)
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("empty key without default: status = %d want 401", rr.Code)
+	}
+}
+
+func TestHandler_WithSignedResponse(t *testing.T) {
+	h, _ := newTestHandler()
+
+	wrapped := h.WithSignedResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("pong"))
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(service.NonceHeader, "n1")
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+
+	if rr.Header().Get("HashSHA256") != "" {
+		t.Fatal("response must not be signed without a key")
+	}
+
+	h.Keys = service.NewStaticKeyRing("secret")
+	rr = httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated || rr.Body.String() != "pong" {
+		t.Fatalf("got %d %q, want 201 pong", rr.Code, rr.Body.String())
+	}
+	if got, want := rr.Header().Get("HashSHA256"), service.SignResponse(http.StatusCreated, "n1", []byte("pong"), "secret"); got != want {
+		t.Fatalf("HashSHA256 = %q, want %q", got, want)
+	}
+}
+
+func TestHandler_WithTrustedSubnet(t *testing.T) {
+	h, _ := newTestHandler()
+
+	subnet, err := service.NewTrustedSubnet("192.168.1.0/24, 10.1.0.0/16", "172.16.0.1")
+	if err != nil {
+		t.Fatalf("NewTrustedSubnet() error = %v", err)
+	}
+	h.TrustedSubnet = subnet
+
+	wrapped := h.WithTrustedSubnet(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		remote string
+		header map[string]string
+		want   int
+	}{
+		{name: "direct from subnet", remote: "192.168.1.5:1234", want: http.StatusOK},
+		{name: "direct from second subnet", remote: "10.1.2.3:1234", want: http.StatusOK},
+		{name: "direct from outside", remote: "8.8.8.8:1234", want: http.StatusForbidden},
+		{name: "spoofed forwarded for", remote: "8.8.8.8:1234", header: map[string]string{"X-Forwarded-For": "192.168.1.5"}, want: http.StatusForbidden},
+		{name: "spoofed real ip", remote: "8.8.8.8:1234", header: map[string]string{"X-Real-IP": "192.168.1.5"}, want: http.StatusForbidden},
+		{name: "proxy forwards trusted client", remote: "172.16.0.1:1234", header: map[string]string{"X-Forwarded-For": "8.8.8.8, 192.168.1.5"}, want: http.StatusOK},
+		{name: "proxy forwards outside client", remote: "172.16.0.1:1234", header: map[string]string{"X-Forwarded-For": "192.168.1.5, 8.8.8.8"}, want: http.StatusForbidden},
+		{name: "proxy real ip", remote: "172.16.0.1:1234", header: map[string]string{"X-Real-IP": "192.168.1.7"}, want: http.StatusOK},
+		{name: "proxy invalid forwarded for", remote: "172.16.0.1:1234", header: map[string]string{"X-Forwarded-For": "garbage"}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/update/", nil)
+			req.RemoteAddr = tt.remote
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rr := httptest.NewRecorder()
+			wrapped.ServeHTTP(rr, req)
+
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d (%s)", rr.Code, tt.want, rr.Body.String())
+			}
+		})
+	}
+
+	// Без доверенных прокси X-Real-IP не принимается ни от кого.
+	h.TrustedSubnet, _ = service.NewTrustedSubnet("192.168.1.0/24", "")
+	for remote, want := range map[string]int{"8.8.8.8:1234": http.StatusForbidden, "192.168.1.5:1234": http.StatusOK} {
+		req := httptest.NewRequest("POST", "/update/", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Real-IP", "192.168.1.5")
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, req)
+
+		if rr.Code != want {
+			t.Fatalf("X-Real-IP from %s without proxies: status = %d, want %d", remote, rr.Code, want)
+		}
+	}
+
+	h.TrustedSubnet = nil
+	req := httptest.NewRequest("POST", "/update/", nil)
+	req.RemoteAddr = "8.8.8.8:1234"
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 without trusted subnet", rr.Code)
+	}
+}
+
+func TestHandler_WithBodyLimit(t *testing.T) {
+	h, _ := newTestHandler()
+	h.MaxBodySize = 16
+
+	wrapped := h.WithBodyLimit(h.WithHashedRequest(http.HandlerFunc(h.UpdateMetricsBatch)))
+	h.Keys = service.NewStaticKeyRing("secret")
+
+	body := `[{"id":"Alloc","type":"gauge","value":1}]`
+
+	req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413 by Content-Length", rr.Code)
+	}
+
+	// Без Content-Length тело обрезается при чтении.
+	req = httptest.NewRequest("POST", "/updates/", io.NopCloser(strings.NewReader(body)))
+	req.ContentLength = -1
+	req.Header.Set("HashSHA256", "whatever")
+	rr = httptest.NewRecorder()
+	wrapped.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413 while reading", rr.Code)
+	}
+}
+
+func TestHandler_WithBodyLimit_Import(t *testing.T) {
+	h, _ := newTestHandler()
+	h.MaxBodySize = 16
+	h.MaxImportSize = 1024
+
+	wrapped := h.WithBodyLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	body := `{"id":"Alloc","type":"gauge","value":1}`
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{name: "streamed import", want: http.StatusOK},
+		{name: "signed import", header: "HashSHA256", want: http.StatusRequestEntityTooLarge},
+		{name: "encrypted import", header: "Content-Encryption", want: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", ImportPath, strings.NewReader(body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, "x")
+			}
+			rr := httptest.NewRecorder()
+			wrapped.ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rr.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_ImportMetrics_LongLine(t *testing.T) {
+	h, ms := newTestHandler()
+
+	body := `{"id":"Alloc","type":"gauge","value":1}` + "\n" +
+		`{"id":"` + strings.Repeat("x", maxImportLine) + `","type":"gauge","value":2}` + "\n" +
+		`{"id":"Frees","type":"gauge","value":3}` + "\n"
+
+	req := httptest.NewRequest("POST", ImportPath, strings.NewReader(body))
+	req.Header.Set("Content-Type", ContentTypeNDJSON)
+	rr := httptest.NewRecorder()
+	h.ImportMetrics(rr, req)
+
+	if !strings.Contains(rr.Body.String(), fmt.Sprintf("line exceeds %d bytes", maxImportLine)) {
+		t.Fatalf("expected long line error, got %s", rr.Body.String())
+	}
+	if _, ok := ms.GetGauge("Alloc"); !ok {
+		t.Fatal("lines before the long line must be applied")
+	}
+	if _, ok := ms.GetGauge("Frees"); ok {
+		t.Fatal("import must stop at the long line")
+	}
+}
+
+func TestHandler_UpdateMetricsBatch_TooLarge(t *testing.T) {
+	h, ms := newTestHandler()
+	h.MaxBatchSize = 1
+
+	body := `[{"id":"a","type":"gauge","value":1},{"id":"b","type":"gauge","value":2}]`
+	req := httptest.NewRequest("POST", "/updates/", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	h.UpdateMetricsBatch(rr, req)
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want 413", rr.Code)
+	}
+	if _, ok := ms.GetGauge("a"); ok {
+		t.Fatal("oversized batch must not be applied")
+	}
+}
+
+func TestHandler_WithPeerRateLimit(t *testing.T) {
+	h, _ := newTestHandler()
+	h.Limiter = service.NewRateLimiter(0.001, 1)
+
+	wrapped := h.WithPeerRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/update/", nil)
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := send("10.0.0.1:1000"); rr.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", rr.Code)
+	}
+
+	rr := send("10.0.0.1:1001")
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d, want 429", rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Fatal("Retry-After header is missing")
+	}
+
+	// X-Real-IP без доверенного прокси не меняет ключ лимита.
+	req := httptest.NewRequest("POST", "/update/", nil)
+	req.RemoteAddr = "10.0.0.1:1002"
+	req.Header.Set("X-Real-IP", "10.9.9.9")
+	spoofed := httptest.NewRecorder()
+	wrapped.ServeHTTP(spoofed, req)
+	if spoofed.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed X-Real-IP status = %d, want 429", spoofed.Code)
+	}
+
+	if rr := send("10.0.0.2:1000"); rr.Code != http.StatusOK {
+		t.Fatalf("other client status = %d, want 200", rr.Code)
+	}
+}
+
+func TestHandler_WithRateLimit(t *testing.T) {
+	h, _ := newTestHandler()
+	h.Limiter = service.NewRateLimiter(0.001, 1)
+
+	wrapped := h.WithRateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remote, token string) int {
+		req := httptest.NewRequest("POST", "/update/", nil)
+		req.RemoteAddr = remote
+		if token != "" {
+			req = req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{Name: token}))
+		}
+		rr := httptest.NewRecorder()
+		wrapped.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	// Без токена лимит задаёт только WithPeerRateLimit.
+	for i := 0; i < 3; i++ {
+		if code := send("10.0.0.1:1000", ""); code != http.StatusOK {
+			t.Fatalf("anonymous request status = %d, want 200", code)
+		}
+	}
+
+	if code := send("10.0.0.1:1000", "agent"); code != http.StatusOK {
+		t.Fatalf("first token request status = %d, want 200", code)
+	}
+	// Смена адреса не обходит лимит токена.
+	if code := send("10.0.0.2:1000", "agent"); code != http.StatusTooManyRequests {
+		t.Fatalf("same token from other address status = %d, want 429", code)
+	}
+	if code := send("10.0.0.2:1000", "other"); code != http.StatusOK {
+		t.Fatalf("other token status = %d, want 200", code)
+	}
+}
+
+func TestHandler_Validation(t *testing.T) {
+	h, ms := newTestHandler()
+	h.Validator = validation.New("__")
+
+	r := chi.NewRouter()
+	r.Post("/update/", h.UpdateMetrics)
+	r.Post("/value/", h.GetMetrics)
+	r.Post("/update/gauge/{name}/{value}", h.UpdateGauge)
+	r.Post("/update/counter/{name}/{value}", h.UpdateCounter)
+	r.Get("/value/gauge/{name}", h.GetGauge)
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		get  bool
+	}{
+		{name: "json gauge with delta only", path: "/update/", body: `{"id":"Alloc","type":"gauge","delta":1}`},
+		{name: "json unknown type", path: "/update/", body: `{"id":"Alloc","type":"histogram","delta":1}`},
+		{name: "json empty id", path: "/update/", body: `{"type":"counter","delta":1}`},
+		{name: "json broken", path: "/update/", body: `{"id":`},
+		{name: "value unknown type", path: "/value/", body: `{"id":"Alloc","type":"histogram"}`},
+		{name: "plain nan gauge", path: "/update/gauge/Alloc/NaN"},
+		{name: "plain inf gauge", path: "/update/gauge/Alloc/+Inf"},
+		{name: "plain reserved name", path: "/update/counter/__hits/1"},
+		{name: "get bad name", path: "/value/gauge/bad%20name", get: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := "POST"
+			if tt.get {
+				method = "GET"
+			}
+			req := httptest.NewRequest(method, tt.path, strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400 (%s)", rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if _, ok := ms.GetGauge("Alloc"); ok {
+		t.Fatal("invalid metric was stored")
+	}
+}
@@ -2,12 +2,17 @@ package handler
 
 import (
 	"bytes"
-	"crypto/rand"
-	"crypto/rsa"
+	"crypto/hmac"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"metrify/internal/auth"
+	models "metrify/internal/model"
 	"metrify/internal/service"
+	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -38,30 +43,39 @@ func (handler *Handler) WithLogging(h http.Handler) http.Handler {
 
 func (handler *Handler) WithRequestCompress(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		encoding := strings.TrimSpace(r.Header.Get("Content-Encoding"))
+		if encoding == "" || strings.EqualFold(encoding, "identity") {
 			h.ServeHTTP(w, r)
 			return
 		}
 
-		dr, err := service.NewCompressReader(r.Body)
-
+		dr, err := service.NewDecodingReader(r.Body, encoding)
+		if errors.Is(err, service.ErrUnsupportedEncoding) {
+			httpError(w, r, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
 		if err != nil {
-			http.Error(w, "invalid gzip", http.StatusBadRequest)
+			httpError(w, r, "invalid "+encoding, http.StatusBadRequest)
 			return
 		}
+		defer dr.Close()
+
 		r.Body = dr
+		r.Header.Del("Content-Encoding")
+		r.ContentLength = -1
 		h.ServeHTTP(w, r)
 	})
 }
 
 func (handler *Handler) WithResponseCompress(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		encoding := service.NegotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" {
 			h.ServeHTTP(w, r)
 			return
 		}
 
-		cw := service.NewCompressWriter(w)
+		cw := service.NewEncodingWriter(w, encoding, handler.MinCompressSize)
 		w = cw
 		defer cw.Close()
 
@@ -69,12 +83,123 @@ func (handler *Handler) WithResponseCompress(h http.Handler) http.Handler {
 	})
 }
 
+// WithBodyLimit ограничивает тело запроса MaxBodySize байтами (MaxImportSize для импорта).
+// Ставится после WithRequestCompress, чтобы ограничивать распакованное тело.
+// Подписанный импорт WithHashedRequest читает в память целиком, поэтому для
+// него действует MaxBodySize; зашифрованное тело ограничивает WithDecrypt.
+func (handler *Handler) WithBodyLimit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := handler.MaxBodySize
+		if r.URL.Path == ImportPath && r.Header.Get("HashSHA256") == "" && r.Header.Get("Content-Encryption") == "" {
+			limit = handler.MaxImportSize
+		}
+
+		if limit <= 0 {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		if r.ContentLength > limit {
+			httpError(w, r, fmt.Sprintf("request body exceeds %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// tooLarge отвечает 413, если err — превышение MaxBodySize.
+func tooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return false
+	}
+
+	httpError(w, r, fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+
+	return true
+}
+
+// AllowContentType отвечает 415 на непустые запросы с Content-Type не из списка,
+// как middleware.AllowContentType из chi, но с ошибкой в формате маршрута.
+func (handler *Handler) AllowContentType(contentTypes ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(contentTypes))
+	for _, ct := range contentTypes {
+		allowed[strings.ToLower(strings.TrimSpace(ct))] = true
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength == 0 {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			ct, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+			if ct = strings.ToLower(strings.TrimSpace(ct)); !allowed[ct] {
+				httpError(w, r, fmt.Sprintf("unsupported content type %q (expect %s)", ct, strings.Join(contentTypes, "|")), http.StatusUnsupportedMediaType)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WithPeerRateLimit ограничивает частоту запросов по адресу соединения
+// (см. TrustedSubnet.PeerIP). Ставится перед RequireScope, чтобы перебор
+// токенов с одного адреса тоже упирался в лимит.
+func (handler *Handler) WithPeerRateLimit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + handler.TrustedSubnet.PeerIP(r.RemoteAddr, r.Header.Get("X-Real-IP"), r.Header.Get("X-Forwarded-For"))
+		handler.limit(w, r, h, key)
+	})
+}
+
+// WithRateLimit ограничивает частоту запросов предъявленного токена, чтобы
+// клиент не обходил лимит сменой адреса. Ставится после RequireScope; запросы
+// без токена ограничивает только WithPeerRateLimit.
+func (handler *Handler) WithRateLimit(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := auth.FromContext(r.Context())
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		handler.limit(w, r, h, "token:"+id.Name)
+	})
+}
+
+func (handler *Handler) limit(w http.ResponseWriter, r *http.Request, h http.Handler, key string) {
+	if wait, ok := handler.Limiter.Allow(key); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(service.RetryAfterSeconds(wait)))
+		httpError(w, r, "rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	h.ServeHTTP(w, r)
+}
+
+// WithHashedRequest проверяет подпись HashSHA256, время и nonce запроса.
+// Подпись покрывает метод, путь со строкой запроса и тело.
+// Запросы без подписи пропускаются для совместимости со старыми агентами;
+// в строгом режиме при настроенных ключах они отклоняются, кроме GET и HEAD:
+// чтение не меняет метрики, а браузер дашборда не подписывает запросы.
+// Неподписанное чтение защищают только токен (RequireScope) и TRUSTED_SUBNET;
+// подписанный GET проверяется как и остальные запросы.
 func (handler *Handler) WithHashedRequest(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var body []byte
 		hash := r.Header.Get("HashSHA256")
 
 		if hash == "" {
+			if handler.Replay.Strict() && handler.Keys.Enabled() && r.Method != http.MethodGet && r.Method != http.MethodHead {
+				httpError(w, r, service.ErrUnsignedRequest.Error(), http.StatusUnauthorized)
+				return
+			}
 			h.ServeHTTP(w, r)
 			return
 		}
@@ -82,65 +207,200 @@ func (handler *Handler) WithHashedRequest(h http.Handler) http.Handler {
 		body, err := io.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, "failed to read body", http.StatusBadRequest)
+			if !tooLarge(w, r, err) {
+				httpError(w, r, "failed to read body", http.StatusBadRequest)
+			}
 			return
 		}
 
-		if service.SignData(body, handler.Key) != hash {
-			http.Error(w, "", http.StatusUnauthorized)
+		key, err := handler.Keys.Lookup(r.Header.Get(service.KeyIDHeader))
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
+		timestamp := r.Header.Get(service.TimestampHeader)
+		nonce := r.Header.Get(service.NonceHeader)
+
+		var expected string
+		if timestamp == "" && nonce == "" {
+			// Старые агенты подписывают только тело.
+			if handler.Replay.Strict() {
+				httpError(w, r, service.ErrUnsignedTimestamp.Error(), http.StatusUnauthorized)
+				return
+			}
+			expected = service.SignData(body, key)
+		} else {
+			expected = service.SignRequest(r.Method, r.URL.RequestURI(), timestamp, nonce, body, key)
+		}
+
+		if !hmac.Equal([]byte(expected), []byte(hash)) {
+			httpError(w, r, "invalid request signature", http.StatusUnauthorized)
+			return
+		}
+
+		if timestamp != "" || nonce != "" {
+			if err := handler.Replay.Check(timestamp, nonce); err != nil {
+				httpError(w, r, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
 		r.Body = io.NopCloser(bytes.NewReader(body))
 
 		h.ServeHTTP(w, r)
 	})
 }
 
+// WithSignedResponse подписывает ответ ключом, которым подписан запрос
+// (или ключом по умолчанию), и передаёт подпись в заголовке HashSHA256.
+// Ответ буферизуется целиком, поэтому потоковые эндпоинты им не оборачиваются.
+func (handler *Handler) WithSignedResponse(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !handler.Keys.Enabled() {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := handler.Keys.Lookup(r.Header.Get(service.KeyIDHeader))
+		if err != nil {
+			key, _ = handler.Keys.Lookup("")
+		}
+		if key == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		bw := service.NewBufferedResponseWriter(w)
+		h.ServeHTTP(bw, r)
+
+		nonce := r.Header.Get(service.NonceHeader)
+		w.Header().Set("HashSHA256", service.SignResponse(bw.Status, nonce, bw.Body.Bytes(), key))
+
+		if err := bw.Send(); err != nil {
+			handler.logger.Errorw("failed to write response", "error", err)
+		}
+	})
+}
+
+// WithDecrypt расшифровывает тело с Content-Encryption. Ставится до WithRequestCompress:
+// агент сжимает тело до шифрования, и Content-Encoding относится к расшифрованному телу.
+// Content-Type описывает расшифрованное тело; старые агенты присылали JSON
+// как application/octet-stream. Зашифрованное тело читается в память целиком,
+// поэтому ограничено MaxBodySize.
 func (handler *Handler) WithDecrypt(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		encryption := r.Header.Get("Content-Encryption")
-		if encryption != "RSA-PKCS1v15" {
+		if encryption == "" {
 			next.ServeHTTP(w, r)
 			return
 		}
 
+		if encryption != service.EncryptionRSAPKCS1v15 && encryption != service.EncryptionHybrid {
+			httpError(w, r, "Unsupported Content-Encryption", http.StatusBadRequest)
+			return
+		}
+
+		if handler.MaxBodySize > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, handler.MaxBodySize)
+		}
+
 		body, err := io.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, "Can't read request", http.StatusInternalServerError)
+			if !tooLarge(w, r, err) {
+				httpError(w, r, "Can't read request", http.StatusInternalServerError)
+			}
 			return
 		}
 
-		decoded, err := base64.StdEncoding.DecodeString(string(body))
-		if err != nil {
-			http.Error(w, "Can't base64 decode request", http.StatusBadRequest)
-			return
+		// Старые агенты передают RSA-PKCS1v15 в base64, гибридный конверт передаётся как есть.
+		if encryption == service.EncryptionRSAPKCS1v15 {
+			body, err = base64.StdEncoding.DecodeString(string(body))
+			if err != nil {
+				httpError(w, r, "Can't base64 decode request", http.StatusBadRequest)
+				return
+			}
 		}
 
-		decrypted, err := rsa.DecryptPKCS1v15(rand.Reader, handler.privKey, decoded)
+		decrypted, err := service.Decrypt(encryption, handler.privKey, body)
 		if err != nil {
-			http.Error(w, "Can't decrypt request", http.StatusBadRequest)
+			httpError(w, r, "Can't decrypt request", http.StatusBadRequest)
 			return
 		}
 
 		r.Body = io.NopCloser(bytes.NewReader(decrypted))
 		r.ContentLength = int64(len(decrypted))
 		r.Header.Del("Content-Encryption")
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct == "" || ct == "application/octet-stream" {
+			r.Header.Set("Content-Type", models.ContentTypeJSON)
+		}
 
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (handler *Handler) WithTrustedSubnet(h http.Handler) http.Handler {
+// RequireScope пропускает только запросы с токеном, у которого есть право scope.
+// Токен передаётся в Authorization: Bearer или в X-API-Key.
+func (handler *Handler) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !handler.Auth.Enabled() {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			token := auth.BearerToken(r.Header.Get("Authorization"))
+			if token == "" {
+				token = r.Header.Get("X-API-Key")
+			}
+
+			id, err := handler.Auth.Authenticate(token, scope)
+			switch {
+			case errors.Is(err, auth.ErrForbidden):
+				httpError(w, r, err.Error(), http.StatusForbidden)
+				return
+			case err != nil:
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrify"`)
+				httpError(w, r, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			h.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+// WithTenant определяет арендатора запроса по токену или заголовку X-Tenant-ID.
+// Ставится после RequireScope, чтобы арендатор токена уже был известен.
+func (handler *Handler) WithTenant(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if handler.TrustedSubnet == "" {
-			h.ServeHTTP(w, r)
+		id, _ := auth.FromContext(r.Context())
+
+		tenant, err := auth.ResolveTenant(id, r.Header.Get(service.TenantHeader))
+		switch {
+		case errors.Is(err, auth.ErrWrongTenant):
+			httpError(w, r, err.Error(), http.StatusForbidden)
+			return
+		case err != nil:
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
 		}
 
-		if handler.TrustedSubnet != r.Header.Get("X-Forwarded-For") {
-			http.Error(w, "Not authorized", http.StatusForbidden)
+		h.ServeHTTP(w, r.WithContext(service.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// WithTrustedSubnet пропускает только клиентов из TRUSTED_SUBNET, адрес
+// определяется так же, как в gRPC-перехватчике NewTrustedSubnetInterceptor.
+func (handler *Handler) WithTrustedSubnet(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := handler.TrustedSubnet.Check(r.RemoteAddr, r.Header.Get("X-Real-IP"), r.Header.Get("X-Forwarded-For"))
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusForbidden)
 			return
 		}
+
+		h.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	models "metrify/internal/model"
+	"metrify/internal/query"
+)
+
+// Query godoc
+// @Summary      Query metrics
+// @Description  Evaluates a query: name globs (CPU*) or regexes (/re/), label matchers ({host=~"web-.*"}),
+// @Description  sum/avg/min/max/count/topk with optional "by (label, ...)" and rate(counter[1m]) over recent history.
+// @Description  Labels: __name__, type, host (name suffix after the last ':').
+// @Tags         query
+// @Produce      json
+// @Param        q query string true "Query, e.g. sum(CPUutilization*)"
+// @Success      200 {object} models.Envelope{data=[]models.Sample}
+// @Failure      400 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /query [get]
+func (handler *Handler) Query(w http.ResponseWriter, r *http.Request) {
+	samples, err := query.Run(handler.storage(r), r.URL.Query().Get("q"), time.Now())
+	if err != nil {
+		writeProblem(w, r, models.Problem{
+			Type:   models.ProblemInvalidQuery,
+			Title:  "Invalid query",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+		})
+		return
+	}
+
+	handler.writeData(w, http.StatusOK, samples)
+}
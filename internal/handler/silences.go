@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"metrify/internal/audit"
+	"metrify/internal/auth"
+	models "metrify/internal/model"
+	"metrify/internal/query"
+	"metrify/internal/service"
+	"metrify/internal/silence"
+)
+
+// SilencedHeader — ID окна обслуживания в ответах GET /value/{type}/{name}.
+const SilencedHeader = "X-Silenced"
+
+// ListSilences godoc
+// @Summary      List silences
+// @Description  Maintenance windows of the tenant sorted by start. Expired windows are kept for a day.
+// @Tags         silences
+// @Produce      json
+// @Param        state query string false "Filter by state" Enums(pending, active, expired)
+// @Success      200 {object} models.Envelope{data=[]models.Silence}
+// @Failure      400 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/silences [get]
+func (handler *Handler) ListSilences(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	if !silence.ValidState(state) {
+		writeProblem(w, r, models.Problem{
+			Type:   models.ProblemInvalidFilter,
+			Title:  "Invalid filter",
+			Status: http.StatusBadRequest,
+			Detail: fmt.Sprintf("unknown silence state %q (expect pending|active|expired)", state),
+		})
+		return
+	}
+
+	silences := handler.Silences.List(service.TenantFromContext(r.Context()), state, time.Now())
+	if silences == nil {
+		silences = []models.Silence{}
+	}
+
+	handler.writeData(w, http.StatusOK, silences)
+}
+
+// CreateSilence godoc
+// @Summary      Create silence
+// @Description  Matchers is a /query selector, e.g. HeapAlloc*{host="web-1"}. starts_at defaults to now; created_by defaults to the token name.
+// @Tags         silences
+// @Accept       json
+// @Produce      json
+// @Param        silence body models.Silence true "matchers, starts_at, ends_at, created_by and comment"
+// @Success      201 {object} models.Envelope{data=models.Silence}
+// @Failure      400 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/silences [post]
+func (handler *Handler) CreateSilence(w http.ResponseWriter, r *http.Request) {
+	var s models.Silence
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+		if !tooLarge(w, r, err) {
+			writeProblem(w, r, models.Problem{
+				Type:   models.ProblemInvalidJSON,
+				Title:  "Request body is not a JSON silence",
+				Status: http.StatusBadRequest,
+				Detail: err.Error(),
+			})
+		}
+		return
+	}
+
+	if id, ok := auth.FromContext(r.Context()); ok && s.CreatedBy == "" {
+		s.CreatedBy = id.Name
+	}
+	s.Tenant = service.TenantFromContext(r.Context())
+
+	created, err := handler.Silences.Create(s, time.Now())
+	if err != nil {
+		writeProblem(w, r, models.Problem{
+			Type:   models.ProblemInvalidSilence,
+			Title:  "Invalid silence",
+			Status: http.StatusBadRequest,
+			Detail: err.Error(),
+		})
+		return
+	}
+
+	handler.flush(r)
+	handler.auditSilence(r, audit.ActionSilenceCreate, created)
+
+	handler.writeData(w, http.StatusCreated, created)
+}
+
+// ExpireSilence godoc
+// @Summary      Expire silence
+// @Description  Ends the maintenance window now. Expiring an expired window is a no-op.
+// @Tags         silences
+// @Produce      json
+// @Param        id path string true "Silence ID"
+// @Success      200 {object} models.Envelope{data=models.Silence}
+// @Failure      404 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/silences/{id} [delete]
+func (handler *Handler) ExpireSilence(w http.ResponseWriter, r *http.Request) {
+	expired, err := handler.Silences.Expire(service.TenantFromContext(r.Context()), chi.URLParam(r, "id"), time.Now())
+	if err != nil {
+		writeProblem(w, r, models.Problem{
+			Type:   models.ProblemSilenceNotFound,
+			Title:  "Silence not found",
+			Status: http.StatusNotFound,
+			Detail: err.Error(),
+		})
+		return
+	}
+
+	handler.flush(r)
+	handler.auditSilence(r, audit.ActionSilenceExpire, expired)
+
+	handler.writeData(w, http.StatusOK, expired)
+}
+
+// silencedBy возвращает ID активного окна, под которое попадает метрика.
+func (handler *Handler) silencedBy(r *http.Request, metric models.Metrics) string {
+	return handler.Silences.Silenced(service.TenantFromContext(r.Context()), query.Labels(metric), time.Now())
+}
+
+// markSilenced выставляет Silenced метрикам под активными окнами.
+func (handler *Handler) markSilenced(r *http.Request, metrics []models.Metrics) {
+	for i := range metrics {
+		metrics[i].Silenced = handler.silencedBy(r, metrics[i]) != ""
+	}
+}
+
+// withSilences добавляет к версии метрик состояние окон арендатора: флаг
+// silenced в ответе меняется при начале и конце окна без изменения метрик.
+// Без активных окон ETag совпадает с ETag самих метрик.
+func (handler *Handler) withSilences(r *http.Request, v service.Version) service.Version {
+	sv := handler.Silences.Version(service.TenantFromContext(r.Context()), time.Now())
+
+	v.Seq ^= sv.Seq
+	if sv.Modified.After(v.Modified) {
+		v.Modified = sv.Modified
+	}
+
+	return v
+}
+
+func (handler *Handler) auditSilence(r *http.Request, action string, s models.Silence) {
+	ev := audit.NewEvent([]string{s.Matchers}, "")
+	ev.Action = action
+	ev.Silence = s.ID
+
+	handler.publishAudit(r, ev)
+}
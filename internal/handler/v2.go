@@ -0,0 +1,294 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+	models "metrify/internal/model"
+	"metrify/internal/service"
+)
+
+// NotFound отвечает на неизвестные маршруты API v2.
+func (handler *Handler) NotFound(w http.ResponseWriter, r *http.Request) {
+	httpError(w, r, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path), http.StatusNotFound)
+}
+
+// MethodNotAllowed отвечает на известные маршруты API v2 с неподдерживаемым
+// методом; допустимые методы ищутся в routes и перечисляются в Allow.
+func (handler *Handler) MethodNotAllowed(routes chi.Routes) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+			path = rctx.RoutePath
+		}
+
+		for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			if routes.Match(chi.NewRouteContext(), method, path) {
+				w.Header().Add("Allow", method)
+			}
+		}
+
+		httpError(w, r, fmt.Sprintf("method %s is not allowed", r.Method), http.StatusMethodNotAllowed)
+	}
+}
+
+// ListMetricsV2 godoc
+// @Summary      List metrics
+// @Tags         v2
+// @Produce      json
+// @Success      200 {object} models.Envelope{data=[]models.Metrics}
+// @Success      304 {string} string "Not modified"
+// @Security     BearerAuth
+// @Router       /api/v2/metrics [get]
+func (handler *Handler) ListMetricsV2(w http.ResponseWriter, r *http.Request) {
+	storage := handler.storage(r)
+	if notModified(w, r, handler.withSilences(r, storage.ListVersion())) {
+		return
+	}
+
+	metrics := storage.List()
+	if metrics == nil {
+		metrics = []models.Metrics{}
+	}
+	handler.markSilenced(r, metrics)
+
+	handler.writeData(w, http.StatusOK, metrics)
+}
+
+// GetMetricV2 godoc
+// @Summary      Get metric
+// @Tags         v2
+// @Produce      json
+// @Param        type path string true "Metric type" Enums(gauge, counter)
+// @Param        name path string true "Metric name"
+// @Success      200 {object} models.Envelope{data=models.Metrics}
+// @Success      304 {string} string "Not modified"
+// @Failure      400 {object} models.Problem
+// @Failure      404 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/metrics/{type}/{name} [get]
+func (handler *Handler) GetMetricV2(w http.ResponseWriter, r *http.Request) {
+	metric, ok := handler.pathMetric(w, r)
+	if !ok {
+		return
+	}
+
+	if v, ok := handler.storage(r).Version(metric.MType, metric.ID); ok && notModified(w, r, handler.withSilences(r, v)) {
+		return
+	}
+
+	if !handler.current(r, &metric) {
+		writeNotFound(w, r, metric)
+		return
+	}
+
+	handler.writeData(w, http.StatusOK, metric)
+}
+
+// PutMetricV2 godoc
+// @Summary      Update metric
+// @Description  Sets a gauge to value or adds delta to a counter. Returns the current value.
+// @Tags         v2
+// @Accept       json
+// @Produce      json
+// @Param        type   path string true "Metric type" Enums(gauge, counter)
+// @Param        name   path string true "Metric name"
+// @Param        metric body models.Metrics true "Payload with value (gauge) or delta (counter)"
+// @Success      200 {object} models.Envelope{data=models.Metrics}
+// @Failure      400 {object} models.Problem
+// @Failure      403 {object} models.Problem
+// @Failure      500 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/metrics/{type}/{name} [put]
+func (handler *Handler) PutMetricV2(w http.ResponseWriter, r *http.Request) {
+	target, ok := handler.pathMetric(w, r)
+	if !ok {
+		return
+	}
+
+	metric := models.Metrics{}
+	defer r.Body.Close()
+
+	if err := json.NewDecoder(r.Body).Decode(&metric); err != nil {
+		if !tooLarge(w, r, err) {
+			writeProblem(w, r, models.Problem{
+				Type:   models.ProblemInvalidJSON,
+				Title:  "Request body is not a JSON metric",
+				Status: http.StatusBadRequest,
+				Detail: err.Error(),
+			})
+		}
+		return
+	}
+
+	// id и type в теле необязательны, но не должны противоречить пути.
+	if (metric.ID != "" && metric.ID != target.ID) || (metric.MType != "" && metric.MType != target.MType) {
+		writeInvalid(w, r, errors.New("id and type in the body must match the path"))
+		return
+	}
+	metric.ID, metric.MType = target.ID, target.MType
+
+	if err := handler.Validator.Metric(metric); err != nil {
+		writeInvalid(w, r, err)
+		return
+	}
+
+	var err error
+	if metric.MType == models.Gauge {
+		err = handler.storage(r).UpdateGauge(metric.ID, *metric.Value)
+	} else {
+		err = handler.storage(r).UpdateCounter(metric.ID, *metric.Delta)
+	}
+
+	switch {
+	case errors.Is(err, service.ErrQuotaExceeded):
+		writeProblem(w, r, models.Problem{
+			Type:   models.ProblemQuotaExceeded,
+			Title:  "Metric quota exceeded",
+			Status: http.StatusForbidden,
+			Detail: err.Error(),
+		})
+		return
+	case err != nil:
+		handler.logger.Error("Error updating metric", zap.Error(err))
+		writeProblem(w, r, models.Problem{
+			Type:   "about:blank",
+			Title:  "Metric was not updated",
+			Status: http.StatusInternalServerError,
+		})
+		return
+	}
+
+	handler.flush(r)
+	handler.auditMetrics(r, []string{metric.ID})
+
+	handler.current(r, &target)
+	handler.writeData(w, http.StatusOK, target)
+}
+
+// DeleteMetricV2 godoc
+// @Summary      Delete metric
+// @Tags         v2
+// @Param        type path string true "Metric type" Enums(gauge, counter)
+// @Param        name path string true "Metric name"
+// @Success      204
+// @Failure      400 {object} models.Problem
+// @Failure      404 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/metrics/{type}/{name} [delete]
+func (handler *Handler) DeleteMetricV2(w http.ResponseWriter, r *http.Request) {
+	metric, ok := handler.pathMetric(w, r)
+	if !ok {
+		return
+	}
+
+	if !handler.storage(r).Delete(metric.MType, metric.ID) {
+		writeNotFound(w, r, metric)
+		return
+	}
+
+	handler.flush(r)
+	handler.auditMetrics(r, []string{metric.ID})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BatchMetricsV2 godoc
+// @Summary      Batch update metrics
+// @Description  Correct metrics are applied even if some of the batch fails. On success returns current values.
+// @Tags         v2
+// @Accept       json
+// @Produce      json
+// @Param        metrics body []models.Metrics true "Metrics array"
+// @Success      200 {object} models.Envelope{data=[]models.Metrics}
+// @Failure      400 {object} models.Problem
+// @Failure      403 {object} models.Problem
+// @Failure      413 {object} models.Problem
+// @Failure      500 {object} models.Problem
+// @Security     BearerAuth
+// @Router       /api/v2/metrics:batch [post]
+func (handler *Handler) BatchMetricsV2(w http.ResponseWriter, r *http.Request) {
+	metrics, ok := handler.decodeBatch(w, r)
+	if !ok {
+		return
+	}
+
+	if problem := handler.applyBatch(r, metrics); problem != nil {
+		writeProblem(w, r, *problem)
+		return
+	}
+
+	result := make([]models.Metrics, 0, len(metrics))
+	for _, metric := range metrics {
+		current := models.Metrics{ID: metric.ID, MType: metric.MType}
+		handler.current(r, &current)
+		result = append(result, current)
+	}
+
+	handler.writeData(w, http.StatusOK, result)
+}
+
+// pathMetric проверяет {type} и {name} из пути. При ошибке ответ уже записан.
+func (handler *Handler) pathMetric(w http.ResponseWriter, r *http.Request) (models.Metrics, bool) {
+	metric := models.Metrics{ID: chi.URLParam(r, "name"), MType: chi.URLParam(r, "type")}
+
+	if err := handler.Validator.Query(metric); err != nil {
+		writeInvalid(w, r, err)
+		return metric, false
+	}
+
+	return metric, true
+}
+
+// current заполняет Value или Delta текущим значением метрики и Silenced.
+func (handler *Handler) current(r *http.Request, metric *models.Metrics) bool {
+	var ok bool
+	if metric.MType == models.Gauge {
+		var val float64
+		if val, ok = handler.storage(r).GetGauge(metric.ID); ok {
+			metric.Value = &val
+		}
+	} else {
+		var val int64
+		if val, ok = handler.storage(r).GetCounter(metric.ID); ok {
+			metric.Delta = &val
+		}
+	}
+
+	if ok {
+		metric.Silenced = handler.silencedBy(r, *metric) != ""
+	}
+
+	return ok
+}
+
+func (handler *Handler) writeData(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(models.Envelope{Data: data}); err != nil {
+		handler.logger.Error("Error encoding JSON", zap.Error(err))
+	}
+}
+
+func writeInvalid(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, models.Problem{
+		Type:   models.ProblemInvalidMetrics,
+		Title:  "Invalid metric",
+		Status: http.StatusBadRequest,
+		Detail: err.Error(),
+	})
+}
+
+func writeNotFound(w http.ResponseWriter, r *http.Request, metric models.Metrics) {
+	writeProblem(w, r, models.Problem{
+		Type:   models.ProblemNotFound,
+		Title:  "Metric not found",
+		Status: http.StatusNotFound,
+		Detail: fmt.Sprintf("%s %q does not exist", metric.MType, metric.ID),
+	})
+}
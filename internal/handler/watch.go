@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"metrify/internal/service"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const watchKeepAlive = 15 * time.Second
+
+// WatchMetrics godoc
+// @Summary      Stream metric updates (Server-Sent Events)
+// @Tags         metrics
+// @Produce      text/event-stream
+// @Param        pattern query string   false "Glob pattern for metric name, e.g. CPUutilization*"
+// @Param        type    query []string false "Metric types (gauge|counter)" collectionFormat(multi)
+// @Success      200 {string} string
+// @Failure      400 {string} string
+// @Security     BearerAuth
+// @Router       /watch [get]
+func (handler *Handler) WatchMetrics(w http.ResponseWriter, r *http.Request) {
+	filter := service.WatchFilter{Pattern: r.URL.Query().Get("pattern")}
+
+	for _, v := range r.URL.Query()["type"] {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				filter.Types = append(filter.Types, t)
+			}
+		}
+	}
+
+	sub, err := handler.storage(r).Watch(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		handler.logger.Warn("streaming is not supported", zap.Error(err))
+		return
+	}
+
+	keepAlive := time.NewTicker(watchKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, err = fmt.Fprint(w, ": keep-alive\n\n")
+		case metric, ok := <-sub.Updates():
+			if !ok {
+				if err := sub.Err(); err != nil {
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", err.Error())
+					_ = rc.Flush()
+				}
+				return
+			}
+
+			data, mErr := json.Marshal(metric)
+			if mErr != nil {
+				handler.logger.Error("Error encoding JSON", zap.Error(mErr))
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "event: metric\ndata: %s\n\n", data)
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Состояния алерта.
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert — срабатывание правила для одной метрики или группы из результата запроса.
+type Alert struct {
+	Rule        string            `json:"rule"`
+	State       string            `json:"state"`
+	Severity    string            `json:"severity"`
+	Tenant      string            `json:"tenant"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Value       float64           `json:"value"`
+	ActiveAt    time.Time         `json:"active_at"`
+	FiredAt     *time.Time        `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time        `json:"resolved_at,omitempty"`
+}
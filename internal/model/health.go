@@ -0,0 +1,19 @@
+package models
+
+// Статусы проверок /healthz и /readyz.
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// HealthReport — ответ /healthz и /readyz. Status равен ok, только если ok все проверки.
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck — результат одной проверки готовности.
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
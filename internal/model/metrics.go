@@ -17,4 +17,32 @@ type Metrics struct {
 	Delta *int64   `json:"delta,omitempty"`
 	Value *float64 `json:"value,omitempty"`
 	Hash  string   `json:"hash,omitempty"`
+	// Silenced — метрика попадает под активное окно обслуживания (только в ответах).
+	Silenced bool `json:"silenced,omitempty"`
+}
+
+// Point — значение метрики в момент T (unix-время в миллисекундах).
+// Для счётчика V — накопленное значение.
+type Point struct {
+	T int64   `json:"t"`
+	V float64 `json:"v"`
+}
+
+// Series — метрика с историей последних значений.
+type Series struct {
+	Metrics
+	History []Point `json:"history"`
+}
+
+// Форматы тела для /updates/ и /value/.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgpack  = "application/msgpack"
+)
+
+// Sample — значение из результата запроса /query с метками метрики или группы.
+type Sample struct {
+	Labels map[string]string `json:"labels"`
+	Value  float64           `json:"value"`
 }
@@ -0,0 +1,51 @@
+package models
+
+// ProblemContentType — тип ответа с ошибкой по RFC 7807.
+const ProblemContentType = "application/problem+json"
+
+// Типы ошибок в поле Problem.Type.
+const (
+	ProblemInvalidJSON     = "/problems/invalid-json"
+	ProblemInvalidBody     = "/problems/invalid-body"
+	ProblemInvalidMetrics  = "/problems/invalid-metrics"
+	ProblemQuotaExceeded   = "/problems/quota-exceeded"
+	ProblemBatchTooLarge   = "/problems/batch-too-large"
+	ProblemNotFound        = "/problems/metric-not-found"
+	ProblemInvalidQuery    = "/problems/invalid-query"
+	ProblemInvalidFilter   = "/problems/invalid-filter"
+	ProblemInvalidSilence  = "/problems/invalid-silence"
+	ProblemSilenceNotFound = "/problems/silence-not-found"
+
+	// Ошибки middleware и маршрутизации API v2.
+	ProblemBadRequest           = "/problems/bad-request"
+	ProblemUnauthorized         = "/problems/unauthorized"
+	ProblemForbidden            = "/problems/forbidden"
+	ProblemRouteNotFound        = "/problems/route-not-found"
+	ProblemMethodNotAllowed     = "/problems/method-not-allowed"
+	ProblemBodyTooLarge         = "/problems/body-too-large"
+	ProblemUnsupportedMediaType = "/problems/unsupported-media-type"
+	ProblemRateLimited          = "/problems/rate-limited"
+	ProblemInternal             = "/problems/internal"
+)
+
+// Problem — описание ошибки запроса (RFC 7807, application/problem+json).
+type Problem struct {
+	Type     string        `json:"type"`
+	Title    string        `json:"title"`
+	Status   int           `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Instance string        `json:"instance,omitempty"`
+	Errors   []MetricError `json:"errors,omitempty"`
+}
+
+// MetricError — ошибка одной метрики батча. Index — позиция метрики в запросе.
+type MetricError struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// Envelope — успешный ответ API v2: полезная нагрузка всегда лежит в data.
+type Envelope struct {
+	Data any `json:"data"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Состояния окна обслуживания.
+const (
+	SilencePending = "pending"
+	SilenceActive  = "active"
+	SilenceExpired = "expired"
+)
+
+// Silence — окно обслуживания: пока оно активно, подходящие метрики
+// помечаются silenced, а уведомления по ним не отправляются.
+type Silence struct {
+	ID string `json:"id"`
+	// Matchers — селектор языка /query, например HeapAlloc*{host="web-1"}.
+	Matchers  string    `json:"matchers"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+	CreatedBy string    `json:"created_by"`
+	Comment   string    `json:"comment"`
+	Tenant    string    `json:"tenant"`
+	// UpdatedAt — время создания или досрочного завершения.
+	UpdatedAt time.Time `json:"updated_at"`
+	// Status вычисляется при чтении.
+	Status string `json:"status"`
+}
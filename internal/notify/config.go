@@ -0,0 +1,169 @@
+// Package notify доставляет алерты движка alert во внешние системы.
+//
+// Алерты проходят по дереву маршрутов: маршрут выбирается по меткам алерта,
+// к которым добавлены alertname (имя правила), severity и tenant. Внутри
+// маршрута алерты группируются по group_by и отправляются одним уведомлением:
+// первое — через group_wait после появления группы, следующие — не чаще
+// group_interval и только при изменении состава группы, а без изменений
+// повторяются раз в repeat_interval. Получатель — вебхук с JSON-телом
+// по шаблону и подписью HMAC-SHA256 в заголовке HashSHA256.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"metrify/internal/alert"
+	"metrify/internal/service"
+)
+
+// Значения маршрута по умолчанию.
+const (
+	DefaultGroupWait      = 30 * time.Second
+	DefaultGroupInterval  = 5 * time.Minute
+	DefaultRepeatInterval = 4 * time.Hour
+)
+
+// Метки, которые notify добавляет к меткам алерта для маршрутизации.
+const (
+	LabelAlertName = "alertname"
+	LabelSeverity  = "severity"
+	LabelTenant    = "tenant"
+)
+
+// StateSilenced — состояние алерта в уведомлении, если получатель видел его
+// firing, а затем на алерт наложили окно обслуживания.
+const StateSilenced = "silenced"
+
+var ErrInvalidConfig = errors.New("invalid notify config")
+
+// Config — дерево маршрутов и получатели уведомлений.
+type Config struct {
+	Route     *Route     `json:"route"`
+	Receivers []Receiver `json:"receivers"`
+}
+
+// Route — узел дерева маршрутов. Пустые поля наследуются от родителя.
+type Route struct {
+	Receiver string `json:"receiver,omitempty"`
+	// Match и MatchRe — точные значения и регулярные выражения для меток.
+	Match          map[string]string `json:"match,omitempty"`
+	MatchRe        map[string]string `json:"match_re,omitempty"`
+	GroupBy        []string          `json:"group_by,omitempty"`
+	GroupWait      *alert.Duration   `json:"group_wait,omitempty"`
+	GroupInterval  *alert.Duration   `json:"group_interval,omitempty"`
+	RepeatInterval *alert.Duration   `json:"repeat_interval,omitempty"`
+	// Continue — после совпадения продолжить проверку соседних маршрутов.
+	Continue bool     `json:"continue,omitempty"`
+	Routes   []*Route `json:"routes,omitempty"`
+
+	id      string
+	matchRe map[string]*regexp.Regexp
+}
+
+// Receiver — вебхук, на который отправляются уведомления.
+type Receiver struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Secret — ключ подписи тела; без него заголовок HashSHA256 не ставится.
+	Secret string `json:"secret,omitempty"`
+	// Template — шаблон text/template тела запроса; данные — Notification,
+	// функция json сериализует значение. Без шаблона отправляется Notification.
+	Template string          `json:"template,omitempty"`
+	Retries  *int            `json:"retries,omitempty"`
+	Backoff  *alert.Duration `json:"backoff,omitempty"`
+	Timeout  *alert.Duration `json:"timeout,omitempty"`
+}
+
+// LoadConfig читает конфигурацию из JSON-файла.
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := service.FromFile[Config](path)
+	if err != nil {
+		return nil, fmt.Errorf("load notify config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// compile проверяет дерево, заполняет унаследованные значения и
+// компилирует регулярные выражения.
+func (r *Route) compile(parent *Route, id string, receivers map[string]bool) error {
+	r.id = id
+
+	if parent == nil {
+		if len(r.Match) > 0 || len(r.MatchRe) > 0 {
+			return fmt.Errorf("%w: root route must match all alerts", ErrInvalidConfig)
+		}
+		r.GroupWait = orDefault(r.GroupWait, DefaultGroupWait)
+		r.GroupInterval = orDefault(r.GroupInterval, DefaultGroupInterval)
+		r.RepeatInterval = orDefault(r.RepeatInterval, DefaultRepeatInterval)
+	} else {
+		if r.Receiver == "" {
+			r.Receiver = parent.Receiver
+		}
+		if r.GroupBy == nil {
+			r.GroupBy = parent.GroupBy
+		}
+		if r.GroupWait == nil {
+			r.GroupWait = parent.GroupWait
+		}
+		if r.GroupInterval == nil {
+			r.GroupInterval = parent.GroupInterval
+		}
+		if r.RepeatInterval == nil {
+			r.RepeatInterval = parent.RepeatInterval
+		}
+	}
+
+	if !receivers[r.Receiver] {
+		return fmt.Errorf("%w: route %s: unknown receiver %q", ErrInvalidConfig, id, r.Receiver)
+	}
+	if *r.GroupInterval <= 0 || *r.RepeatInterval <= 0 || *r.GroupWait < 0 {
+		return fmt.Errorf("%w: route %s: group_wait must not be negative, group_interval and repeat_interval must be positive", ErrInvalidConfig, id)
+	}
+
+	r.matchRe = make(map[string]*regexp.Regexp, len(r.MatchRe))
+	for label, expr := range r.MatchRe {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return fmt.Errorf("%w: route %s: match_re %s: %w", ErrInvalidConfig, id, label, err)
+		}
+		r.matchRe[label] = re
+	}
+
+	for i, child := range r.Routes {
+		if child == nil {
+			return fmt.Errorf("%w: route %s: empty child route", ErrInvalidConfig, id)
+		}
+		if err := child.compile(r, fmt.Sprintf("%s.%d", id, i), receivers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func orDefault(d *alert.Duration, def time.Duration) *alert.Duration {
+	if d != nil {
+		return d
+	}
+	v := alert.Duration(def)
+
+	return &v
+}
+
+func (r *Receiver) validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: receiver name is required", ErrInvalidConfig)
+	}
+	if r.URL == "" {
+		return fmt.Errorf("%w: receiver %q: url is required", ErrInvalidConfig, r.Name)
+	}
+	if r.Retries != nil && *r.Retries < 0 {
+		return fmt.Errorf("%w: receiver %q: retries must not be negative", ErrInvalidConfig, r.Name)
+	}
+
+	return nil
+}
@@ -0,0 +1,235 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"metrify/internal/alert"
+	models "metrify/internal/model"
+	"metrify/internal/silence"
+)
+
+// DefaultTick — как часто Notifier проверяет группы.
+const DefaultTick = time.Second
+
+// Notifier группирует алерты по маршрутам и отправляет уведомления.
+type Notifier struct {
+	route     *Route
+	receivers map[string]Sender
+	logger    *zap.SugaredLogger
+	// Silences — окна обслуживания; алерты под активным окном не отправляются.
+	Silences *silence.Store
+
+	// groups меняется только в Flush.
+	groups map[string]*group
+}
+
+type group struct {
+	route  *Route
+	labels map[string]string
+	// next — раньше этого момента группа не отправляется (group_wait, group_interval).
+	next     time.Time
+	lastSent time.Time
+	// sent — firing-алерты из последнего доставленного уведомления.
+	sent map[string]models.Alert
+}
+
+// New проверяет конфигурацию и создаёт вебхуки получателей.
+func New(cfg *Config, logger *zap.SugaredLogger) (*Notifier, error) {
+	if cfg == nil || cfg.Route == nil {
+		return nil, fmt.Errorf("%w: route is required", ErrInvalidConfig)
+	}
+
+	receivers := make(map[string]Sender, len(cfg.Receivers))
+	names := make(map[string]bool, len(cfg.Receivers))
+	for _, r := range cfg.Receivers {
+		if names[r.Name] {
+			return nil, fmt.Errorf("%w: duplicate receiver %q", ErrInvalidConfig, r.Name)
+		}
+		w, err := NewWebhook(r, nil)
+		if err != nil {
+			return nil, err
+		}
+		receivers[r.Name] = w
+		names[r.Name] = true
+	}
+
+	if err := cfg.Route.compile(nil, "root", names); err != nil {
+		return nil, err
+	}
+
+	return &Notifier{
+		route:     cfg.Route,
+		receivers: receivers,
+		logger:    logger,
+		groups:    make(map[string]*group),
+	}, nil
+}
+
+// Run раз в tick передаёт в Flush текущие алерты из source до отмены ctx.
+func (n *Notifier) Run(ctx context.Context, source func() []models.Alert, tick time.Duration) error {
+	if tick <= 0 {
+		tick = DefaultTick
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			n.Flush(ctx, time.Now(), source())
+		}
+	}
+}
+
+// Flush раскладывает алерты по группам и отправляет уведомления группам,
+// которым пора. Pending-алерты и алерты под окнами обслуживания не отправляются,
+// resolved — только если получатель уже знает о них как о firing. Алерт,
+// отправленный как firing, о котором получатель иначе не узнал бы, что он
+// закончился, уходит со State silenced, если попал под окно обслуживания,
+// и resolved, если пропал из alerts. Flush не вызывается конкурентно.
+func (n *Notifier) Flush(ctx context.Context, now time.Time, alerts []models.Alert) {
+	current := make(map[string]map[string]models.Alert)
+
+	for _, a := range alerts {
+		if a.State == models.AlertPending {
+			continue
+		}
+
+		labels := Labels(a)
+		if n.Silences.Silenced(a.Tenant, labels, now) != "" {
+			a.State = StateSilenced
+		}
+		fp := alert.LabelsKey(labels)
+
+		for _, route := range n.route.Lookup(labels) {
+			groupLabels := route.groupLabels(labels)
+			key := route.id + "\xfe" + alert.LabelsKey(groupLabels)
+
+			if _, ok := n.groups[key]; !ok {
+				if a.State != models.AlertFiring {
+					continue
+				}
+				n.groups[key] = &group{
+					route:  route,
+					labels: groupLabels,
+					next:   now.Add(time.Duration(*route.GroupWait)),
+					sent:   make(map[string]models.Alert),
+				}
+			}
+
+			if current[key] == nil {
+				current[key] = make(map[string]models.Alert)
+			}
+			current[key][fp] = a
+		}
+	}
+
+	var wg sync.WaitGroup
+	for key, g := range n.groups {
+		if now.Before(g.next) {
+			continue
+		}
+
+		batch, firing, changed := g.collect(now, current[key])
+		if firing == 0 && !changed {
+			delete(n.groups, key)
+			continue
+		}
+		if !changed && now.Sub(g.lastSent) < time.Duration(*g.route.RepeatInterval) {
+			continue
+		}
+
+		g.next = now.Add(time.Duration(*g.route.GroupInterval))
+
+		wg.Add(1)
+		go func(g *group, batch map[string]models.Alert, status string) {
+			defer wg.Done()
+			n.send(ctx, now, g, batch, status)
+		}(g, batch, statusOf(firing))
+	}
+	wg.Wait()
+}
+
+// collect выбирает алерты для уведомления группы: firing-алерты и
+// resolved- и silenced-алерты, которые получатель видел firing. Алерты из
+// g.sent, которых нет в alerts, считаются resolved. changed — состав
+// отличается от последнего доставленного уведомления.
+func (g *group) collect(now time.Time, alerts map[string]models.Alert) (batch map[string]models.Alert, firing int, changed bool) {
+	batch = make(map[string]models.Alert, len(alerts))
+
+	for fp, a := range alerts {
+		sent, ok := g.sent[fp]
+		switch {
+		case a.State == models.AlertFiring:
+			firing++
+		case !ok:
+			continue
+		}
+		batch[fp] = a
+		if !ok || sent.State != a.State {
+			changed = true
+		}
+	}
+
+	for fp, a := range g.sent {
+		if _, ok := alerts[fp]; ok {
+			continue
+		}
+		a.State = models.AlertResolved
+		if a.ResolvedAt == nil {
+			a.ResolvedAt = &now
+		}
+		batch[fp] = a
+		changed = true
+	}
+
+	return batch, firing, changed
+}
+
+func statusOf(firing int) string {
+	if firing > 0 {
+		return models.AlertFiring
+	}
+
+	return models.AlertResolved
+}
+
+func (n *Notifier) send(ctx context.Context, now time.Time, g *group, batch map[string]models.Alert, status string) {
+	fps := make([]string, 0, len(batch))
+	for fp := range batch {
+		fps = append(fps, fp)
+	}
+	sort.Strings(fps)
+
+	notification := Notification{
+		Receiver:    g.route.Receiver,
+		Status:      status,
+		GroupLabels: maps.Clone(g.labels),
+		Alerts:      make([]models.Alert, len(fps)),
+	}
+	for i, fp := range fps {
+		notification.Alerts[i] = batch[fp]
+	}
+
+	if err := n.receivers[g.route.Receiver].Send(ctx, notification); err != nil {
+		n.logger.Warnw("alert notification failed", "receiver", g.route.Receiver, "group", g.labels, "error", err)
+		return
+	}
+
+	g.lastSent = now
+	g.sent = make(map[string]models.Alert, len(batch))
+	for fp, a := range batch {
+		if a.State == models.AlertFiring {
+			g.sent[fp] = a
+		}
+	}
+}
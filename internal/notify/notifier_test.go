@@ -0,0 +1,290 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	models "metrify/internal/model"
+	"metrify/internal/service"
+	"metrify/internal/silence"
+)
+
+// recorder — HTTP-заглушка получателя, запоминающая уведомления.
+type recorder struct {
+	mu            sync.Mutex
+	notifications []Notification
+	status        int
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var n Notification
+	_ = json.NewDecoder(r.Body).Decode(&n)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	if rec.status != 0 {
+		w.WriteHeader(rec.status)
+		return
+	}
+	rec.notifications = append(rec.notifications, n)
+}
+
+func (rec *recorder) take() []Notification {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	got := rec.notifications
+	rec.notifications = nil
+
+	return got
+}
+
+func newTestNotifier(t *testing.T, rec *recorder) *Notifier {
+	t.Helper()
+
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+
+	n, err := New(&Config{
+		Route: &Route{
+			Receiver:       "ops",
+			GroupBy:        []string{LabelAlertName},
+			GroupWait:      durationPtr(30 * time.Second),
+			GroupInterval:  durationPtr(time.Minute),
+			RepeatInterval: durationPtr(time.Hour),
+		},
+		Receivers: []Receiver{{Name: "ops", URL: srv.URL, Retries: intPtr(0)}},
+	}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return n
+}
+
+func testAlert(rule, host, state string) models.Alert {
+	return models.Alert{
+		Rule:     rule,
+		State:    state,
+		Severity: "warning",
+		Tenant:   "default",
+		Labels:   map[string]string{"__name__": "HeapAlloc:" + host, "host": host},
+	}
+}
+
+func TestNotifier_Lifecycle(t *testing.T) {
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	ctx := context.Background()
+	start := time.Now()
+
+	web1 := testAlert("HighHeap", "web-1", models.AlertFiring)
+	web2 := testAlert("HighHeap", "web-2", models.AlertFiring)
+	pending := testAlert("HighHeap", "web-3", models.AlertPending)
+
+	n.Flush(ctx, start, []models.Alert{web1, pending})
+	n.Flush(ctx, start.Add(10*time.Second), []models.Alert{web1, web2, pending})
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("nothing must be sent before group_wait: %+v", got)
+	}
+
+	n.Flush(ctx, start.Add(30*time.Second), []models.Alert{web1, web2, pending})
+	got := rec.take()
+	if len(got) != 1 || got[0].Status != models.AlertFiring || len(got[0].Alerts) != 2 || got[0].GroupLabels[LabelAlertName] != "HighHeap" {
+		t.Fatalf("expected one grouped notification with two alerts, got %+v", got)
+	}
+
+	// Без изменений — повтор только через repeat_interval.
+	n.Flush(ctx, start.Add(2*time.Minute), []models.Alert{web1, web2})
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("unchanged group must be deduplicated: %+v", got)
+	}
+	n.Flush(ctx, start.Add(time.Hour+30*time.Second), []models.Alert{web1, web2})
+	if got := rec.take(); len(got) != 1 || len(got[0].Alerts) != 2 {
+		t.Fatalf("expected repeat after repeat_interval, got %+v", got)
+	}
+
+	// Разрешение одного алерта отправляется не раньше group_interval.
+	web2.State = models.AlertResolved
+	n.Flush(ctx, start.Add(time.Hour+time.Minute), []models.Alert{web1, web2})
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("nothing must be sent before group_interval: %+v", got)
+	}
+	n.Flush(ctx, start.Add(time.Hour+90*time.Second), []models.Alert{web1, web2})
+	got = rec.take()
+	if len(got) != 1 || got[0].Status != models.AlertFiring || len(got[0].Alerts) != 2 || got[0].Alerts[1].State != models.AlertResolved {
+		t.Fatalf("expected notification with resolved web-2, got %+v", got)
+	}
+
+	// Resolved web-2 уже доставлен и больше не отправляется.
+	web1.State = models.AlertResolved
+	n.Flush(ctx, start.Add(time.Hour+3*time.Minute), []models.Alert{web1, web2})
+	got = rec.take()
+	if len(got) != 1 || got[0].Status != models.AlertResolved || len(got[0].Alerts) != 1 || got[0].Alerts[0].Labels["host"] != "web-1" {
+		t.Fatalf("expected resolved notification for web-1 only, got %+v", got)
+	}
+
+	n.Flush(ctx, start.Add(time.Hour+5*time.Minute), []models.Alert{web1, web2})
+	if got := rec.take(); len(got) != 0 || len(n.groups) != 0 {
+		t.Fatalf("resolved group must be dropped: sent %+v, groups %d", got, len(n.groups))
+	}
+}
+
+func TestNotifier_ResolvedBeforeGroupWait(t *testing.T) {
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	ctx := context.Background()
+	start := time.Now()
+
+	a := testAlert("HighHeap", "web-1", models.AlertFiring)
+	n.Flush(ctx, start, []models.Alert{a})
+
+	a.State = models.AlertResolved
+	n.Flush(ctx, start.Add(time.Minute), []models.Alert{a})
+
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("alert resolved before group_wait must not be sent: %+v", got)
+	}
+}
+
+func TestNotifier_FailedDeliveryIsRetried(t *testing.T) {
+	rec := &recorder{status: http.StatusServiceUnavailable}
+	n := newTestNotifier(t, rec)
+	ctx := context.Background()
+	start := time.Now()
+
+	a := testAlert("HighHeap", "web-1", models.AlertFiring)
+	n.Flush(ctx, start, []models.Alert{a})
+	n.Flush(ctx, start.Add(30*time.Second), []models.Alert{a})
+
+	rec.mu.Lock()
+	rec.status = 0
+	rec.mu.Unlock()
+
+	n.Flush(ctx, start.Add(time.Minute), []models.Alert{a})
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("retry must wait for group_interval: %+v", got)
+	}
+
+	n.Flush(ctx, start.Add(90*time.Second), []models.Alert{a})
+	if got := rec.take(); len(got) != 1 {
+		t.Fatalf("expected delivery after failure, got %+v", got)
+	}
+}
+
+func TestNotifier_SeparateGroups(t *testing.T) {
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	ctx := context.Background()
+	start := time.Now()
+
+	alerts := []models.Alert{
+		testAlert("HighHeap", "web-1", models.AlertFiring),
+		testAlert("LowMem", "web-1", models.AlertFiring),
+	}
+	n.Flush(ctx, start, alerts)
+	n.Flush(ctx, start.Add(30*time.Second), alerts)
+
+	if got := rec.take(); len(got) != 2 {
+		t.Fatalf("expected one notification per alertname, got %+v", got)
+	}
+}
+
+func TestNotifier_Silenced(t *testing.T) {
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	n.Silences = silence.NewStore()
+	ctx := context.Background()
+	start := time.Now()
+
+	web1 := testAlert("HighHeap", "web-1", models.AlertFiring)
+	web2 := testAlert("HighHeap", "web-2", models.AlertFiring)
+
+	s, err := n.Silences.Create(models.Silence{Matchers: `{host="web-1"}`, EndsAt: start.Add(time.Hour), CreatedBy: "ops", Comment: "deploy"}, start)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	n.Flush(ctx, start, []models.Alert{web1, web2})
+	n.Flush(ctx, start.Add(30*time.Second), []models.Alert{web1, web2})
+	got := rec.take()
+	if len(got) != 1 || len(got[0].Alerts) != 1 || got[0].Alerts[0].Labels["host"] != "web-2" {
+		t.Fatalf("silenced web-1 must not be sent, got %+v", got)
+	}
+
+	if _, err := n.Silences.Expire(service.DefaultTenant, s.ID, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Expire() error = %v", err)
+	}
+	n.Flush(ctx, start.Add(90*time.Second), []models.Alert{web1, web2})
+	got = rec.take()
+	if len(got) != 1 || len(got[0].Alerts) != 2 {
+		t.Fatalf("expected web-1 after the silence expired, got %+v", got)
+	}
+}
+
+func TestNotifier_SilencedAfterFiring(t *testing.T) {
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	n.Silences = silence.NewStore()
+	ctx := context.Background()
+	start := time.Now()
+
+	web1 := testAlert("HighHeap", "web-1", models.AlertFiring)
+	web2 := testAlert("HighHeap", "web-2", models.AlertFiring)
+
+	n.Flush(ctx, start, []models.Alert{web1, web2})
+	n.Flush(ctx, start.Add(30*time.Second), []models.Alert{web1, web2})
+	if got := rec.take(); len(got) != 1 || len(got[0].Alerts) != 2 {
+		t.Fatalf("expected firing notification with two alerts, got %+v", got)
+	}
+
+	if _, err := n.Silences.Create(models.Silence{Matchers: `{host="web-1"}`, EndsAt: start.Add(time.Hour), CreatedBy: "ops", Comment: "deploy"}, start.Add(time.Minute)); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	n.Flush(ctx, start.Add(90*time.Second), []models.Alert{web1, web2})
+	got := rec.take()
+	if len(got) != 1 || got[0].Status != models.AlertFiring || len(got[0].Alerts) != 2 || got[0].Alerts[0].State != StateSilenced {
+		t.Fatalf("expected web-1 reported as silenced, got %+v", got)
+	}
+
+	// Получатель уже знает о silenced web-1, повторно он не отправляется.
+	n.Flush(ctx, start.Add(3*time.Minute), []models.Alert{web1, web2})
+	if got := rec.take(); len(got) != 0 {
+		t.Fatalf("silenced alert must not be sent again: %+v", got)
+	}
+}
+
+func TestNotifier_DisappearedAlertIsResolved(t *testing.T) {
+	rec := &recorder{}
+	n := newTestNotifier(t, rec)
+	ctx := context.Background()
+	start := time.Now()
+
+	a := testAlert("HighHeap", "web-1", models.AlertFiring)
+	n.Flush(ctx, start, []models.Alert{a})
+	n.Flush(ctx, start.Add(30*time.Second), []models.Alert{a})
+	if got := rec.take(); len(got) != 1 {
+		t.Fatalf("expected firing notification, got %+v", got)
+	}
+
+	// Правило удалили: алерт больше не приходит от движка.
+	now := start.Add(90 * time.Second)
+	n.Flush(ctx, now, nil)
+	got := rec.take()
+	if len(got) != 1 || got[0].Status != models.AlertResolved || len(got[0].Alerts) != 1 ||
+		got[0].Alerts[0].State != models.AlertResolved || got[0].Alerts[0].ResolvedAt == nil || !got[0].Alerts[0].ResolvedAt.Equal(now) {
+		t.Fatalf("expected resolved notification for the missing alert, got %+v", got)
+	}
+
+	n.Flush(ctx, start.Add(3*time.Minute), nil)
+	if got := rec.take(); len(got) != 0 || len(n.groups) != 0 {
+		t.Fatalf("resolved group must be dropped: sent %+v, groups %d", got, len(n.groups))
+	}
+}
@@ -0,0 +1,69 @@
+package notify
+
+import models "metrify/internal/model"
+
+// Labels возвращает метки алерта для маршрутизации и группировки.
+func Labels(a models.Alert) map[string]string {
+	labels := make(map[string]string, len(a.Labels)+3)
+	for k, v := range a.Labels {
+		labels[k] = v
+	}
+	labels[LabelAlertName] = a.Rule
+	labels[LabelSeverity] = a.Severity
+	labels[LabelTenant] = a.Tenant
+
+	return labels
+}
+
+// Lookup возвращает маршруты, которым достаётся алерт с метками labels:
+// самые глубокие совпавшие узлы, в порядке обхода. Первый совпавший потомок
+// останавливает проверку соседей, если у него не выставлен Continue.
+// Если ни один потомок не совпал, алерт остаётся в самом узле.
+func (r *Route) Lookup(labels map[string]string) []*Route {
+	if !r.matches(labels) {
+		return nil
+	}
+
+	var routes []*Route
+	for _, child := range r.Routes {
+		matched := child.Lookup(labels)
+		if len(matched) == 0 {
+			continue
+		}
+		routes = append(routes, matched...)
+		if !child.Continue {
+			break
+		}
+	}
+
+	if len(routes) == 0 {
+		return []*Route{r}
+	}
+
+	return routes
+}
+
+func (r *Route) matches(labels map[string]string) bool {
+	for label, value := range r.Match {
+		if labels[label] != value {
+			return false
+		}
+	}
+	for label, re := range r.matchRe {
+		if !re.MatchString(labels[label]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// groupLabels — значения меток group_by маршрута.
+func (r *Route) groupLabels(labels map[string]string) map[string]string {
+	group := make(map[string]string, len(r.GroupBy))
+	for _, label := range r.GroupBy {
+		group[label] = labels[label]
+	}
+
+	return group
+}
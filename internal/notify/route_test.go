@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func testConfig() *Config {
+	return &Config{
+		Route: &Route{
+			Receiver:       "default",
+			GroupBy:        []string{LabelAlertName},
+			RepeatInterval: durationPtr(time.Hour),
+			Routes: []*Route{
+				{Match: map[string]string{LabelSeverity: "critical"}, Receiver: "pager", Continue: true},
+				{MatchRe: map[string]string{"host": "db-.*"}, Receiver: "dba", GroupBy: []string{"host"}},
+				{Match: map[string]string{LabelTenant: "acme"}, Receiver: "acme"},
+			},
+		},
+		Receivers: []Receiver{
+			{Name: "default", URL: "http://localhost/default"},
+			{Name: "pager", URL: "http://localhost/pager"},
+			{Name: "dba", URL: "http://localhost/dba"},
+			{Name: "acme", URL: "http://localhost/acme"},
+		},
+	}
+}
+
+func TestRoute_Lookup(t *testing.T) {
+	n, err := New(testConfig(), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   []string
+	}{
+		{name: "falls back to root", labels: map[string]string{LabelSeverity: "warning", "host": "web-1"}, want: []string{"default"}},
+		{name: "continue keeps matching", labels: map[string]string{LabelSeverity: "critical", "host": "db-1"}, want: []string{"pager", "dba"}},
+		{name: "first match stops", labels: map[string]string{"host": "db-1", LabelTenant: "acme"}, want: []string{"dba"}},
+		{name: "regex is anchored", labels: map[string]string{"host": "old-db-1", LabelTenant: "acme"}, want: []string{"acme"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range n.route.Lookup(tt.labels) {
+				got = append(got, r.Receiver)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Lookup() receivers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoute_Inheritance(t *testing.T) {
+	n, err := New(testConfig(), zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	pager, dba := n.route.Routes[0], n.route.Routes[1]
+
+	if !reflect.DeepEqual(pager.GroupBy, []string{LabelAlertName}) {
+		t.Fatalf("pager group_by = %v, want inherited", pager.GroupBy)
+	}
+	if !reflect.DeepEqual(dba.GroupBy, []string{"host"}) {
+		t.Fatalf("dba group_by = %v, want own", dba.GroupBy)
+	}
+	if time.Duration(*pager.RepeatInterval) != time.Hour || time.Duration(*pager.GroupWait) != DefaultGroupWait {
+		t.Fatalf("pager intervals not inherited: repeat=%v wait=%v", *pager.RepeatInterval, *pager.GroupWait)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(cfg *Config)
+	}{
+		{name: "no route", mutate: func(cfg *Config) { cfg.Route = nil }},
+		{name: "unknown receiver", mutate: func(cfg *Config) { cfg.Route.Routes[0].Receiver = "nobody" }},
+		{name: "duplicate receiver", mutate: func(cfg *Config) { cfg.Receivers = append(cfg.Receivers, cfg.Receivers[0]) }},
+		{name: "receiver without url", mutate: func(cfg *Config) { cfg.Receivers[0].URL = "" }},
+		{name: "bad template", mutate: func(cfg *Config) { cfg.Receivers[0].Template = "{{.Status" }},
+		{name: "bad regex", mutate: func(cfg *Config) { cfg.Route.Routes[1].MatchRe["host"] = "(" }},
+		{name: "matcher on root", mutate: func(cfg *Config) { cfg.Route.Match = map[string]string{"host": "a"} }},
+		{name: "zero repeat interval", mutate: func(cfg *Config) { cfg.Route.RepeatInterval = durationPtr(0) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.mutate(cfg)
+
+			if _, err := New(cfg, zap.NewNop().Sugar()); !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("New() error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.json")
+	data := `{
+		"route": {"receiver": "ops", "group_by": ["alertname"], "group_wait": "10s",
+			"routes": [{"match": {"severity": "critical"}, "repeat_interval": "15m"}]},
+		"receivers": [{"name": "ops", "url": "http://localhost/hook", "secret": "k", "retries": 5, "backoff": "2s"}]
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	n, err := New(cfg, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	critical := n.route.Routes[0]
+	if critical.Receiver != "ops" || time.Duration(*critical.GroupWait) != 10*time.Second || time.Duration(*critical.RepeatInterval) != 15*time.Minute {
+		t.Fatalf("unexpected critical route: receiver=%s wait=%v repeat=%v", critical.Receiver, *critical.GroupWait, *critical.RepeatInterval)
+	}
+	if w := n.receivers["ops"].(*Webhook); w.retries != 5 || w.backoff != 2*time.Second {
+		t.Fatalf("unexpected webhook settings: retries=%d backoff=%v", w.retries, w.backoff)
+	}
+
+	if _, err := LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	models "metrify/internal/model"
+	"metrify/internal/service"
+)
+
+// Значения вебхука по умолчанию.
+const (
+	DefaultRetries = 3
+	DefaultBackoff = time.Second
+	DefaultTimeout = 10 * time.Second
+)
+
+// Notification — уведомление о группе алертов.
+type Notification struct {
+	Receiver string `json:"receiver"`
+	// Status — firing, если в группе есть firing-алерты, иначе resolved.
+	// State алерта — firing, resolved или StateSilenced.
+	Status      string            `json:"status"`
+	GroupLabels map[string]string `json:"group_labels"`
+	Alerts      []models.Alert    `json:"alerts"`
+}
+
+// Sender доставляет уведомление получателю.
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// Webhook отправляет уведомления POST-запросом с JSON-телом.
+type Webhook struct {
+	url     string
+	secret  string
+	tmpl    *template.Template
+	client  *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// NewWebhook создаёт вебхук по описанию получателя. Без client используется
+// клиент с таймаутом получателя.
+func NewWebhook(r Receiver, client *http.Client) (*Webhook, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	w := &Webhook{
+		url:     r.URL,
+		secret:  r.Secret,
+		client:  client,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+	if r.Retries != nil {
+		w.retries = *r.Retries
+	}
+	if r.Backoff != nil {
+		w.backoff = time.Duration(*r.Backoff)
+	}
+	if w.client == nil {
+		timeout := DefaultTimeout
+		if r.Timeout != nil {
+			timeout = time.Duration(*r.Timeout)
+		}
+		w.client = &http.Client{Timeout: timeout}
+	}
+	if r.Template != "" {
+		tmpl, err := template.New(r.Name).Funcs(templateFuncs).Parse(r.Template)
+		if err != nil {
+			return nil, fmt.Errorf("%w: receiver %q: %w", ErrInvalidConfig, r.Name, err)
+		}
+		w.tmpl = tmpl
+	}
+
+	return w, nil
+}
+
+// statusError — ответ вебхука не 2xx.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("webhook: non-2xx status: %d %s", e.code, http.StatusText(e.code))
+}
+
+// Send отправляет уведомление. Сетевые ошибки, 5xx и 429 повторяются
+// до retries раз с удвоением паузы, начиная с backoff.
+func (w *Webhook) Send(ctx context.Context, n Notification) error {
+	body, err := w.render(n)
+	if err != nil {
+		return err
+	}
+
+	delay := w.backoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(ctx, body)
+
+		var se *statusError
+		if errors.As(err, &se) && se.code != http.StatusTooManyRequests && se.code < 500 {
+			return err
+		}
+		if err == nil || attempt >= w.retries || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (w *Webhook) render(n Notification) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(n)
+	}
+
+	var b bytes.Buffer
+	if err := w.tmpl.Execute(&b, n); err != nil {
+		return nil, fmt.Errorf("webhook: render template: %w", err)
+	}
+	if !json.Valid(b.Bytes()) {
+		return nil, fmt.Errorf("webhook: template produced invalid JSON: %s", b.Bytes())
+	}
+
+	return b.Bytes(), nil
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set("HashSHA256", service.SignData(body, w.secret))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &statusError{code: resp.StatusCode}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"metrify/internal/alert"
+	models "metrify/internal/model"
+	"metrify/internal/service"
+)
+
+func intPtr(v int) *int { return &v }
+
+func durationPtr(d time.Duration) *alert.Duration {
+	v := alert.Duration(d)
+	return &v
+}
+
+func TestWebhook_Send_SignsTemplatedBody(t *testing.T) {
+	var (
+		gotBody        []byte
+		gotHash        string
+		gotContentType string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHash = r.Header.Get("HashSHA256")
+		gotContentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(Receiver{
+		Name:     "ops",
+		URL:      srv.URL,
+		Secret:   "s3cret",
+		Template: `{"text": "{{.Status}}: {{len .Alerts}} alert(s)", "rule": {{json (index .Alerts 0).Rule}}}`,
+	}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+
+	n := Notification{Receiver: "ops", Status: models.AlertFiring, Alerts: []models.Alert{{Rule: `High"Heap`, State: models.AlertFiring}}}
+	if err := w.Send(context.Background(), n); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if gotContentType != "application/json" {
+		t.Fatalf("Content-Type = %q", gotContentType)
+	}
+	if gotHash != service.SignData(gotBody, "s3cret") {
+		t.Fatalf("HashSHA256 = %q does not sign body %s", gotHash, gotBody)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(gotBody, &body); err != nil {
+		t.Fatalf("body is not JSON: %v; body=%s", err, gotBody)
+	}
+	if body["text"] != "firing: 1 alert(s)" || body["rule"] != `High"Heap` {
+		t.Fatalf("unexpected body: %v", body)
+	}
+}
+
+func TestWebhook_Send_DefaultBodyWithoutSecret(t *testing.T) {
+	var (
+		got     Notification
+		gotHash string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHash = r.Header.Get("HashSHA256")
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(Receiver{Name: "ops", URL: srv.URL}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+
+	n := Notification{Receiver: "ops", Status: models.AlertResolved, GroupLabels: map[string]string{"alertname": "HighHeap"}}
+	if err := w.Send(context.Background(), n); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if gotHash != "" {
+		t.Fatalf("unexpected HashSHA256 without secret: %q", gotHash)
+	}
+	if got.Status != models.AlertResolved || got.GroupLabels["alertname"] != "HighHeap" {
+		t.Fatalf("unexpected notification: %+v", got)
+	}
+}
+
+func TestWebhook_Send_Retries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		retries   int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "succeeds after server errors", statuses: []int{503, 429, 200}, retries: 3, wantCalls: 3},
+		{name: "gives up after retries", statuses: []int{500, 500, 500, 500}, retries: 2, wantCalls: 3, wantErr: true},
+		{name: "client error is not retried", statuses: []int{400, 200}, retries: 3, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := calls.Add(1) - 1
+				w.WriteHeader(tt.statuses[i])
+			}))
+			defer srv.Close()
+
+			w, err := NewWebhook(Receiver{Name: "ops", URL: srv.URL, Retries: intPtr(tt.retries), Backoff: durationPtr(time.Millisecond)}, srv.Client())
+			if err != nil {
+				t.Fatalf("NewWebhook() error = %v", err)
+			}
+
+			err = w.Send(context.Background(), Notification{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "non-2xx") {
+				t.Fatalf("expected error to mention non-2xx, got: %v", err)
+			}
+			if got := calls.Load(); got != tt.wantCalls {
+				t.Fatalf("calls = %d, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWebhook_Send_InvalidTemplateOutput(t *testing.T) {
+	var calls atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(Receiver{Name: "ops", URL: srv.URL, Template: `{"text": {{.Status}}}`}, srv.Client())
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+
+	if err := w.Send(context.Background(), Notification{Status: models.AlertFiring}); err == nil {
+		t.Fatalf("expected error for invalid JSON body")
+	}
+	if calls.Load() != 0 {
+		t.Fatalf("invalid body must not be sent")
+	}
+}
@@ -0,0 +1,58 @@
+package proto
+
+import (
+	"fmt"
+
+	models "metrify/internal/model"
+)
+
+// FromModel переводит метрику в сообщение Metric. Метрика неизвестного типа
+// или без значения — ошибка.
+func FromModel(metric models.Metrics) (*Metric, error) {
+	m := &Metric{}
+	m.SetId(metric.ID)
+
+	switch metric.MType {
+	case models.Gauge:
+		if metric.Value == nil {
+			return nil, fmt.Errorf("gauge metric %q has nil value", metric.ID)
+		}
+
+		m.SetType(Metric_GAUGE)
+		m.SetValue(*metric.Value)
+
+		return m, nil
+
+	case models.Counter:
+		if metric.Delta == nil {
+			return nil, fmt.Errorf("counter metric %q has nil delta", metric.ID)
+		}
+
+		m.SetType(Metric_COUNTER)
+		m.SetDelta(*metric.Delta)
+
+		return m, nil
+
+	default:
+		return nil, fmt.Errorf("unknown metric type %q", metric.MType)
+	}
+}
+
+// ToModel переводит сообщение в метрику без проверки: неизвестный тип попадает
+// в MType числом и отклоняется валидатором вместе с остальными ошибками.
+func ToModel(m *Metric) models.Metrics {
+	metric := models.Metrics{ID: m.GetId()}
+
+	switch m.GetType() {
+	case Metric_GAUGE:
+		value := m.GetValue()
+		metric.MType, metric.Value = models.Gauge, &value
+	case Metric_COUNTER:
+		delta := m.GetDelta()
+		metric.MType, metric.Delta = models.Counter, &delta
+	default:
+		metric.MType = fmt.Sprint(int32(m.GetType()))
+	}
+
+	return metric
+}
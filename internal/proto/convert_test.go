@@ -0,0 +1,131 @@
+package proto
+
+import (
+	"testing"
+
+	models "metrify/internal/model"
+)
+
+func TestFromModel_Gauge(t *testing.T) {
+	v := 12.5
+
+	got, err := FromModel(models.Metrics{
+		ID:    "Alloc",
+		MType: models.Gauge,
+		Value: &v,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.GetId() != "Alloc" {
+		t.Fatalf("got id %q, want Alloc", got.GetId())
+	}
+	if got.GetType() != Metric_GAUGE {
+		t.Fatalf("got type %v, want %v", got.GetType(), Metric_GAUGE)
+	}
+	if got.GetValue() != 12.5 {
+		t.Fatalf("got value %v, want 12.5", got.GetValue())
+	}
+}
+
+func TestFromModel_Counter(t *testing.T) {
+	d := int64(7)
+
+	got, err := FromModel(models.Metrics{
+		ID:    "PollCount",
+		MType: models.Counter,
+		Delta: &d,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.GetId() != "PollCount" {
+		t.Fatalf("got id %q, want PollCount", got.GetId())
+	}
+	if got.GetType() != Metric_COUNTER {
+		t.Fatalf("got type %v, want %v", got.GetType(), Metric_COUNTER)
+	}
+	if got.GetDelta() != 7 {
+		t.Fatalf("got delta %v, want 7", got.GetDelta())
+	}
+}
+
+func TestFromModel_GaugeNilValue(t *testing.T) {
+	_, err := FromModel(models.Metrics{
+		ID:    "Alloc",
+		MType: models.Gauge,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	want := `gauge metric "Alloc" has nil value`
+	if err.Error() != want {
+		t.Fatalf("got error %q, want %q", err.Error(), want)
+	}
+}
+
+func TestFromModel_CounterNilDelta(t *testing.T) {
+	_, err := FromModel(models.Metrics{
+		ID:    "PollCount",
+		MType: models.Counter,
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	want := `counter metric "PollCount" has nil delta`
+	if err.Error() != want {
+		t.Fatalf("got error %q, want %q", err.Error(), want)
+	}
+}
+
+func TestFromModel_UnknownType(t *testing.T) {
+	_, err := FromModel(models.Metrics{
+		ID:    "Broken",
+		MType: "unknown",
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	want := `unknown metric type "unknown"`
+	if err.Error() != want {
+		t.Fatalf("got error %q, want %q", err.Error(), want)
+	}
+}
+
+func TestToModel(t *testing.T) {
+	v, d := 12.5, int64(7)
+
+	for _, want := range []models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &v},
+		{ID: "PollCount", MType: models.Counter, Delta: &d},
+	} {
+		m, err := FromModel(want)
+		if err != nil {
+			t.Fatalf("FromModel(%s): %v", want.ID, err)
+		}
+
+		got := ToModel(m)
+		if got.ID != want.ID || got.MType != want.MType {
+			t.Fatalf("got %s/%s, want %s/%s", got.ID, got.MType, want.ID, want.MType)
+		}
+		if (want.Value != nil) != (got.Value != nil) || want.Value != nil && *got.Value != *want.Value {
+			t.Fatalf("%s: value not preserved", want.ID)
+		}
+		if (want.Delta != nil) != (got.Delta != nil) || want.Delta != nil && *got.Delta != *want.Delta {
+			t.Fatalf("%s: delta not preserved", want.ID)
+		}
+	}
+
+	unknown := &Metric{}
+	unknown.SetId("Broken")
+	unknown.SetType(Metric_MType(42))
+
+	if got := ToModel(unknown); got.MType != "42" || got.Value != nil || got.Delta != nil {
+		t.Fatalf("unknown type: got %+v", got)
+	}
+}
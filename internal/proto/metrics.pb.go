@@ -219,15 +219,115 @@ func (b0 UpdateMetricsRequest_builder) Build() *UpdateMetricsRequest {
 	return m0
 }
 
+type MetricResult struct {
+	state            protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Index int32                  `protobuf:"varint,1,opt,name=index,proto3"`
+	xxx_hidden_Id    string                 `protobuf:"bytes,2,opt,name=id,proto3"`
+	xxx_hidden_Ok    bool                   `protobuf:"varint,3,opt,name=ok,proto3"`
+	xxx_hidden_Error string                 `protobuf:"bytes,4,opt,name=error,proto3"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *MetricResult) Reset() {
+	*x = MetricResult{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricResult) ProtoMessage() {}
+
+func (x *MetricResult) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *MetricResult) GetIndex() int32 {
+	if x != nil {
+		return x.xxx_hidden_Index
+	}
+	return 0
+}
+
+func (x *MetricResult) GetId() string {
+	if x != nil {
+		return x.xxx_hidden_Id
+	}
+	return ""
+}
+
+func (x *MetricResult) GetOk() bool {
+	if x != nil {
+		return x.xxx_hidden_Ok
+	}
+	return false
+}
+
+func (x *MetricResult) GetError() string {
+	if x != nil {
+		return x.xxx_hidden_Error
+	}
+	return ""
+}
+
+func (x *MetricResult) SetIndex(v int32) {
+	x.xxx_hidden_Index = v
+}
+
+func (x *MetricResult) SetId(v string) {
+	x.xxx_hidden_Id = v
+}
+
+func (x *MetricResult) SetOk(v bool) {
+	x.xxx_hidden_Ok = v
+}
+
+func (x *MetricResult) SetError(v string) {
+	x.xxx_hidden_Error = v
+}
+
+type MetricResult_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	Index int32
+	Id    string
+	Ok    bool
+	Error string
+}
+
+func (b0 MetricResult_builder) Build() *MetricResult {
+	m0 := &MetricResult{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_Index = b.Index
+	x.xxx_hidden_Id = b.Id
+	x.xxx_hidden_Ok = b.Ok
+	x.xxx_hidden_Error = b.Error
+	return m0
+}
+
 type UpdateMetricsResponse struct {
-	state         protoimpl.MessageState `protogen:"opaque.v1"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state              protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Results *[]*MetricResult       `protobuf:"bytes,1,rep,name=results,proto3"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
 }
 
 func (x *UpdateMetricsResponse) Reset() {
 	*x = UpdateMetricsResponse{}
-	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	mi := &file_internal_proto_metrics_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -239,7 +339,7 @@ func (x *UpdateMetricsResponse) String() string {
 func (*UpdateMetricsResponse) ProtoMessage() {}
 
 func (x *UpdateMetricsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_internal_proto_metrics_proto_msgTypes[2]
+	mi := &file_internal_proto_metrics_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -250,15 +350,573 @@ func (x *UpdateMetricsResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
+func (x *UpdateMetricsResponse) GetResults() []*MetricResult {
+	if x != nil {
+		if x.xxx_hidden_Results != nil {
+			return *x.xxx_hidden_Results
+		}
+	}
+	return nil
+}
+
+func (x *UpdateMetricsResponse) SetResults(v []*MetricResult) {
+	x.xxx_hidden_Results = &v
+}
+
 type UpdateMetricsResponse_builder struct {
 	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
 
+	Results []*MetricResult
 }
 
 func (b0 UpdateMetricsResponse_builder) Build() *UpdateMetricsResponse {
 	m0 := &UpdateMetricsResponse{}
 	b, x := &b0, m0
 	_, _ = b, x
+	x.xxx_hidden_Results = &b.Results
+	return m0
+}
+
+type WatchMetricsRequest struct {
+	state              protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Pattern string                 `protobuf:"bytes,1,opt,name=pattern,proto3"`
+	xxx_hidden_Types   []Metric_MType         `protobuf:"varint,2,rep,packed,name=types,proto3,enum=metrics.Metric_MType"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *WatchMetricsRequest) Reset() {
+	*x = WatchMetricsRequest{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchMetricsRequest) ProtoMessage() {}
+
+func (x *WatchMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *WatchMetricsRequest) GetPattern() string {
+	if x != nil {
+		return x.xxx_hidden_Pattern
+	}
+	return ""
+}
+
+func (x *WatchMetricsRequest) GetTypes() []Metric_MType {
+	if x != nil {
+		return x.xxx_hidden_Types
+	}
+	return nil
+}
+
+func (x *WatchMetricsRequest) SetPattern(v string) {
+	x.xxx_hidden_Pattern = v
+}
+
+func (x *WatchMetricsRequest) SetTypes(v []Metric_MType) {
+	x.xxx_hidden_Types = v
+}
+
+type WatchMetricsRequest_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	Pattern string
+	Types   []Metric_MType
+}
+
+func (b0 WatchMetricsRequest_builder) Build() *WatchMetricsRequest {
+	m0 := &WatchMetricsRequest{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_Pattern = b.Pattern
+	x.xxx_hidden_Types = b.Types
+	return m0
+}
+
+type QueryRequest struct {
+	state            protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Query string                 `protobuf:"bytes,1,opt,name=query,proto3"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *QueryRequest) GetQuery() string {
+	if x != nil {
+		return x.xxx_hidden_Query
+	}
+	return ""
+}
+
+func (x *QueryRequest) SetQuery(v string) {
+	x.xxx_hidden_Query = v
+}
+
+type QueryRequest_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	Query string
+}
+
+func (b0 QueryRequest_builder) Build() *QueryRequest {
+	m0 := &QueryRequest{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_Query = b.Query
+	return m0
+}
+
+type Sample struct {
+	state             protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Labels map[string]string      `protobuf:"bytes,1,rep,name=labels,proto3" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	xxx_hidden_Value  float64                `protobuf:"fixed64,2,opt,name=value,proto3"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Sample) Reset() {
+	*x = Sample{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sample) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sample) ProtoMessage() {}
+
+func (x *Sample) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *Sample) GetLabels() map[string]string {
+	if x != nil {
+		return x.xxx_hidden_Labels
+	}
+	return nil
+}
+
+func (x *Sample) GetValue() float64 {
+	if x != nil {
+		return x.xxx_hidden_Value
+	}
+	return 0
+}
+
+func (x *Sample) SetLabels(v map[string]string) {
+	x.xxx_hidden_Labels = v
+}
+
+func (x *Sample) SetValue(v float64) {
+	x.xxx_hidden_Value = v
+}
+
+type Sample_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	Labels map[string]string
+	Value  float64
+}
+
+func (b0 Sample_builder) Build() *Sample {
+	m0 := &Sample{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_Labels = b.Labels
+	x.xxx_hidden_Value = b.Value
+	return m0
+}
+
+type QueryResponse struct {
+	state              protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Samples *[]*Sample             `protobuf:"bytes,1,rep,name=samples,proto3"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *QueryResponse) GetSamples() []*Sample {
+	if x != nil {
+		if x.xxx_hidden_Samples != nil {
+			return *x.xxx_hidden_Samples
+		}
+	}
+	return nil
+}
+
+func (x *QueryResponse) SetSamples(v []*Sample) {
+	x.xxx_hidden_Samples = &v
+}
+
+type QueryResponse_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	Samples []*Sample
+}
+
+func (b0 QueryResponse_builder) Build() *QueryResponse {
+	m0 := &QueryResponse{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_Samples = &b.Samples
+	return m0
+}
+
+type ListAlertsRequest struct {
+	state            protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_State string                 `protobuf:"bytes,1,opt,name=state,proto3"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ListAlertsRequest) Reset() {
+	*x = ListAlertsRequest{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAlertsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAlertsRequest) ProtoMessage() {}
+
+func (x *ListAlertsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *ListAlertsRequest) GetState() string {
+	if x != nil {
+		return x.xxx_hidden_State
+	}
+	return ""
+}
+
+func (x *ListAlertsRequest) SetState(v string) {
+	x.xxx_hidden_State = v
+}
+
+type ListAlertsRequest_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	State string
+}
+
+func (b0 ListAlertsRequest_builder) Build() *ListAlertsRequest {
+	m0 := &ListAlertsRequest{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_State = b.State
+	return m0
+}
+
+type Alert struct {
+	state                  protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Rule        string                 `protobuf:"bytes,1,opt,name=rule,proto3"`
+	xxx_hidden_State       string                 `protobuf:"bytes,2,opt,name=state,proto3"`
+	xxx_hidden_Severity    string                 `protobuf:"bytes,3,opt,name=severity,proto3"`
+	xxx_hidden_Labels      map[string]string      `protobuf:"bytes,4,rep,name=labels,proto3" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	xxx_hidden_Annotations map[string]string      `protobuf:"bytes,5,rep,name=annotations,proto3" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	xxx_hidden_Value       float64                `protobuf:"fixed64,6,opt,name=value,proto3"`
+	xxx_hidden_ActiveAt    int64                  `protobuf:"varint,7,opt,name=active_at,json=activeAt,proto3"`
+	xxx_hidden_FiredAt     int64                  `protobuf:"varint,8,opt,name=fired_at,json=firedAt,proto3"`
+	xxx_hidden_ResolvedAt  int64                  `protobuf:"varint,9,opt,name=resolved_at,json=resolvedAt,proto3"`
+	unknownFields          protoimpl.UnknownFields
+	sizeCache              protoimpl.SizeCache
+}
+
+func (x *Alert) Reset() {
+	*x = Alert{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Alert) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Alert) ProtoMessage() {}
+
+func (x *Alert) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *Alert) GetRule() string {
+	if x != nil {
+		return x.xxx_hidden_Rule
+	}
+	return ""
+}
+
+func (x *Alert) GetState() string {
+	if x != nil {
+		return x.xxx_hidden_State
+	}
+	return ""
+}
+
+func (x *Alert) GetSeverity() string {
+	if x != nil {
+		return x.xxx_hidden_Severity
+	}
+	return ""
+}
+
+func (x *Alert) GetLabels() map[string]string {
+	if x != nil {
+		return x.xxx_hidden_Labels
+	}
+	return nil
+}
+
+func (x *Alert) GetAnnotations() map[string]string {
+	if x != nil {
+		return x.xxx_hidden_Annotations
+	}
+	return nil
+}
+
+func (x *Alert) GetValue() float64 {
+	if x != nil {
+		return x.xxx_hidden_Value
+	}
+	return 0
+}
+
+func (x *Alert) GetActiveAt() int64 {
+	if x != nil {
+		return x.xxx_hidden_ActiveAt
+	}
+	return 0
+}
+
+func (x *Alert) GetFiredAt() int64 {
+	if x != nil {
+		return x.xxx_hidden_FiredAt
+	}
+	return 0
+}
+
+func (x *Alert) GetResolvedAt() int64 {
+	if x != nil {
+		return x.xxx_hidden_ResolvedAt
+	}
+	return 0
+}
+
+func (x *Alert) SetRule(v string) {
+	x.xxx_hidden_Rule = v
+}
+
+func (x *Alert) SetState(v string) {
+	x.xxx_hidden_State = v
+}
+
+func (x *Alert) SetSeverity(v string) {
+	x.xxx_hidden_Severity = v
+}
+
+func (x *Alert) SetLabels(v map[string]string) {
+	x.xxx_hidden_Labels = v
+}
+
+func (x *Alert) SetAnnotations(v map[string]string) {
+	x.xxx_hidden_Annotations = v
+}
+
+func (x *Alert) SetValue(v float64) {
+	x.xxx_hidden_Value = v
+}
+
+func (x *Alert) SetActiveAt(v int64) {
+	x.xxx_hidden_ActiveAt = v
+}
+
+func (x *Alert) SetFiredAt(v int64) {
+	x.xxx_hidden_FiredAt = v
+}
+
+func (x *Alert) SetResolvedAt(v int64) {
+	x.xxx_hidden_ResolvedAt = v
+}
+
+type Alert_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	Rule        string
+	State       string
+	Severity    string
+	Labels      map[string]string
+	Annotations map[string]string
+	Value       float64
+	ActiveAt    int64
+	FiredAt     int64
+	ResolvedAt  int64
+}
+
+func (b0 Alert_builder) Build() *Alert {
+	m0 := &Alert{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_Rule = b.Rule
+	x.xxx_hidden_State = b.State
+	x.xxx_hidden_Severity = b.Severity
+	x.xxx_hidden_Labels = b.Labels
+	x.xxx_hidden_Annotations = b.Annotations
+	x.xxx_hidden_Value = b.Value
+	x.xxx_hidden_ActiveAt = b.ActiveAt
+	x.xxx_hidden_FiredAt = b.FiredAt
+	x.xxx_hidden_ResolvedAt = b.ResolvedAt
+	return m0
+}
+
+type ListAlertsResponse struct {
+	state             protoimpl.MessageState `protogen:"opaque.v1"`
+	xxx_hidden_Alerts *[]*Alert              `protobuf:"bytes,1,rep,name=alerts,proto3"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ListAlertsResponse) Reset() {
+	*x = ListAlertsResponse{}
+	mi := &file_internal_proto_metrics_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListAlertsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListAlertsResponse) ProtoMessage() {}
+
+func (x *ListAlertsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_proto_metrics_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+func (x *ListAlertsResponse) GetAlerts() []*Alert {
+	if x != nil {
+		if x.xxx_hidden_Alerts != nil {
+			return *x.xxx_hidden_Alerts
+		}
+	}
+	return nil
+}
+
+func (x *ListAlertsResponse) SetAlerts(v []*Alert) {
+	x.xxx_hidden_Alerts = &v
+}
+
+type ListAlertsResponse_builder struct {
+	_ [0]func() // Prevents comparability and use of unkeyed literals for the builder.
+
+	Alerts []*Alert
+}
+
+func (b0 ListAlertsResponse_builder) Build() *ListAlertsResponse {
+	m0 := &ListAlertsResponse{}
+	b, x := &b0, m0
+	_, _ = b, x
+	x.xxx_hidden_Alerts = &b.Alerts
 	return m0
 }
 
@@ -276,29 +934,97 @@ const file_internal_proto_metrics_proto_rawDesc = "" +
 	"\x05GAUGE\x10\x00\x12\v\n" +
 	"\aCOUNTER\x10\x01\"A\n" +
 	"\x14UpdateMetricsRequest\x12)\n" +
-	"\ametrics\x18\x01 \x03(\v2\x0f.metrics.MetricR\ametrics\"\x17\n" +
-	"\x15UpdateMetricsResponse2Y\n" +
+	"\ametrics\x18\x01 \x03(\v2\x0f.metrics.MetricR\ametrics\"Z\n" +
+	"\fMetricResult\x12\x14\n" +
+	"\x05index\x18\x01 \x01(\x05R\x05index\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x0e\n" +
+	"\x02ok\x18\x03 \x01(\bR\x02ok\x12\x14\n" +
+	"\x05error\x18\x04 \x01(\tR\x05error\"H\n" +
+	"\x15UpdateMetricsResponse\x12/\n" +
+	"\aresults\x18\x01 \x03(\v2\x15.metrics.MetricResultR\aresults\"\\\n" +
+	"\x13WatchMetricsRequest\x12\x18\n" +
+	"\apattern\x18\x01 \x01(\tR\apattern\x12+\n" +
+	"\x05types\x18\x02 \x03(\x0e2\x15.metrics.Metric.MTypeR\x05types\"$\n" +
+	"\fQueryRequest\x12\x14\n" +
+	"\x05query\x18\x01 \x01(\tR\x05query\"\x8e\x01\n" +
+	"\x06Sample\x123\n" +
+	"\x06labels\x18\x01 \x03(\v2\x1b.metrics.Sample.LabelsEntryR\x06labels\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\":\n" +
+	"\rQueryResponse\x12)\n" +
+	"\asamples\x18\x01 \x03(\v2\x0f.metrics.SampleR\asamples\")\n" +
+	"\x11ListAlertsRequest\x12\x14\n" +
+	"\x05state\x18\x01 \x01(\tR\x05state\"\xae\x03\n" +
+	"\x05Alert\x12\x12\n" +
+	"\x04rule\x18\x01 \x01(\tR\x04rule\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12\x1a\n" +
+	"\bseverity\x18\x03 \x01(\tR\bseverity\x122\n" +
+	"\x06labels\x18\x04 \x03(\v2\x1a.metrics.Alert.LabelsEntryR\x06labels\x12A\n" +
+	"\vannotations\x18\x05 \x03(\v2\x1f.metrics.Alert.AnnotationsEntryR\vannotations\x12\x14\n" +
+	"\x05value\x18\x06 \x01(\x01R\x05value\x12\x1b\n" +
+	"\tactive_at\x18\a \x01(\x03R\bactiveAt\x12\x19\n" +
+	"\bfired_at\x18\b \x01(\x03R\afiredAt\x12\x1f\n" +
+	"\vresolved_at\x18\t \x01(\x03R\n" +
+	"resolvedAt\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a>\n" +
+	"\x10AnnotationsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"<\n" +
+	"\x12ListAlertsResponse\x12&\n" +
+	"\x06alerts\x18\x01 \x03(\v2\x0e.metrics.AlertR\x06alerts2\x99\x02\n" +
 	"\aMetrics\x12N\n" +
-	"\rUpdateMetrics\x12\x1d.metrics.UpdateMetricsRequest\x1a\x1e.metrics.UpdateMetricsResponseB-Z+github.com/g123udini/metrify/internal/protob\x06proto3"
+	"\rUpdateMetrics\x12\x1d.metrics.UpdateMetricsRequest\x1a\x1e.metrics.UpdateMetricsResponse\x12?\n" +
+	"\fWatchMetrics\x12\x1c.metrics.WatchMetricsRequest\x1a\x0f.metrics.Metric0\x01\x126\n" +
+	"\x05Query\x12\x15.metrics.QueryRequest\x1a\x16.metrics.QueryResponse\x12E\n" +
+	"\n" +
+	"ListAlerts\x12\x1a.metrics.ListAlertsRequest\x1a\x1b.metrics.ListAlertsResponseB-Z+github.com/g123udini/metrify/internal/protob\x06proto3"
 
 var file_internal_proto_metrics_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_internal_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_internal_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_internal_proto_metrics_proto_goTypes = []any{
 	(Metric_MType)(0),             // 0: metrics.Metric.MType
 	(*Metric)(nil),                // 1: metrics.Metric
 	(*UpdateMetricsRequest)(nil),  // 2: metrics.UpdateMetricsRequest
-	(*UpdateMetricsResponse)(nil), // 3: metrics.UpdateMetricsResponse
+	(*MetricResult)(nil),          // 3: metrics.MetricResult
+	(*UpdateMetricsResponse)(nil), // 4: metrics.UpdateMetricsResponse
+	(*WatchMetricsRequest)(nil),   // 5: metrics.WatchMetricsRequest
+	(*QueryRequest)(nil),          // 6: metrics.QueryRequest
+	(*Sample)(nil),                // 7: metrics.Sample
+	(*QueryResponse)(nil),         // 8: metrics.QueryResponse
+	(*ListAlertsRequest)(nil),     // 9: metrics.ListAlertsRequest
+	(*Alert)(nil),                 // 10: metrics.Alert
+	(*ListAlertsResponse)(nil),    // 11: metrics.ListAlertsResponse
+	nil,                           // 12: metrics.Sample.LabelsEntry
+	nil,                           // 13: metrics.Alert.LabelsEntry
+	nil,                           // 14: metrics.Alert.AnnotationsEntry
 }
 var file_internal_proto_metrics_proto_depIdxs = []int32{
-	0, // 0: metrics.Metric.type:type_name -> metrics.Metric.MType
-	1, // 1: metrics.UpdateMetricsRequest.metrics:type_name -> metrics.Metric
-	2, // 2: metrics.Metrics.UpdateMetrics:input_type -> metrics.UpdateMetricsRequest
-	3, // 3: metrics.Metrics.UpdateMetrics:output_type -> metrics.UpdateMetricsResponse
-	3, // [3:4] is the sub-list for method output_type
-	2, // [2:3] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	0,  // 0: metrics.Metric.type:type_name -> metrics.Metric.MType
+	1,  // 1: metrics.UpdateMetricsRequest.metrics:type_name -> metrics.Metric
+	3,  // 2: metrics.UpdateMetricsResponse.results:type_name -> metrics.MetricResult
+	0,  // 3: metrics.WatchMetricsRequest.types:type_name -> metrics.Metric.MType
+	12, // 4: metrics.Sample.labels:type_name -> metrics.Sample.LabelsEntry
+	7,  // 5: metrics.QueryResponse.samples:type_name -> metrics.Sample
+	13, // 6: metrics.Alert.labels:type_name -> metrics.Alert.LabelsEntry
+	14, // 7: metrics.Alert.annotations:type_name -> metrics.Alert.AnnotationsEntry
+	10, // 8: metrics.ListAlertsResponse.alerts:type_name -> metrics.Alert
+	2,  // 9: metrics.Metrics.UpdateMetrics:input_type -> metrics.UpdateMetricsRequest
+	5,  // 10: metrics.Metrics.WatchMetrics:input_type -> metrics.WatchMetricsRequest
+	6,  // 11: metrics.Metrics.Query:input_type -> metrics.QueryRequest
+	9,  // 12: metrics.Metrics.ListAlerts:input_type -> metrics.ListAlertsRequest
+	4,  // 13: metrics.Metrics.UpdateMetrics:output_type -> metrics.UpdateMetricsResponse
+	1,  // 14: metrics.Metrics.WatchMetrics:output_type -> metrics.Metric
+	8,  // 15: metrics.Metrics.Query:output_type -> metrics.QueryResponse
+	11, // 16: metrics.Metrics.ListAlerts:output_type -> metrics.ListAlertsResponse
+	13, // [13:17] is the sub-list for method output_type
+	9,  // [9:13] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_internal_proto_metrics_proto_init() }
@@ -312,7 +1038,7 @@ func file_internal_proto_metrics_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_proto_metrics_proto_rawDesc), len(file_internal_proto_metrics_proto_rawDesc)),
 			NumEnums:      1,
-			NumMessages:   3,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
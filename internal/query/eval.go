@@ -0,0 +1,204 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	models "metrify/internal/model"
+	"metrify/internal/service"
+)
+
+// Run разбирает и вычисляет запрос q по метрикам storage на момент now.
+func Run(storage service.Storage, q string, now time.Time) ([]models.Sample, error) {
+	e, err := Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return Eval(storage, e, now)
+}
+
+// Eval вычисляет разобранный запрос. Результат не бывает nil.
+func Eval(storage service.Storage, e Expr, now time.Time) ([]models.Sample, error) {
+	var (
+		samples []models.Sample
+		err     error
+	)
+
+	switch e := e.(type) {
+	case *Selector:
+		samples = selectSamples(storage, e)
+	case *Rate:
+		samples = rate(storage, e, now)
+	case *Aggregate:
+		samples, err = aggregate(storage, e, now)
+	default:
+		err = fmt.Errorf("%w: unsupported expression %T", ErrInvalidQuery, e)
+	}
+
+	if samples == nil && err == nil {
+		samples = []models.Sample{}
+	}
+
+	return samples, err
+}
+
+// Labels возвращает метки метрики.
+func Labels(metric models.Metrics) map[string]string {
+	labels := map[string]string{
+		LabelName: metric.ID,
+		LabelType: metric.MType,
+		LabelHost: "",
+	}
+	if i := strings.LastIndexByte(metric.ID, ':'); i >= 0 {
+		labels[LabelHost] = metric.ID[i+1:]
+	}
+
+	return labels
+}
+
+func selectSamples(storage service.Storage, s *Selector) []models.Sample {
+	var samples []models.Sample
+
+	for _, metric := range storage.List() {
+		labels := Labels(metric)
+		if !s.Match(labels) {
+			continue
+		}
+
+		var value float64
+		if metric.MType == models.Gauge && metric.Value != nil {
+			value = *metric.Value
+		} else if metric.Delta != nil {
+			value = float64(*metric.Delta)
+		}
+
+		samples = append(samples, models.Sample{Labels: labels, Value: value})
+	}
+
+	return samples
+}
+
+// rate считает прирост счётчиков в секунду по точкам истории за окно.
+// Уменьшение значения считается сбросом счётчика (удаление и повторное создание).
+// Счётчики, у которых в окне меньше двух точек, в результат не попадают.
+func rate(storage service.Storage, r *Rate, now time.Time) []models.Sample {
+	var samples []models.Sample
+	from := now.Add(-r.Window).UnixMilli()
+
+	for _, metric := range storage.List() {
+		labels := Labels(metric)
+		if metric.MType != models.Counter || !r.Selector.Match(labels) {
+			continue
+		}
+
+		var points []models.Point
+		for _, point := range storage.History(metric.MType, metric.ID) {
+			if point.T >= from {
+				points = append(points, point)
+			}
+		}
+		if len(points) < 2 {
+			continue
+		}
+
+		var increase float64
+		for i := 1; i < len(points); i++ {
+			if delta := points[i].V - points[i-1].V; delta >= 0 {
+				increase += delta
+			} else {
+				increase += points[i].V
+			}
+		}
+
+		seconds := float64(points[len(points)-1].T-points[0].T) / 1000
+		if seconds <= 0 {
+			continue
+		}
+
+		samples = append(samples, models.Sample{Labels: labels, Value: increase / seconds})
+	}
+
+	return samples
+}
+
+func aggregate(storage service.Storage, a *Aggregate, now time.Time) ([]models.Sample, error) {
+	input, err := Eval(storage, a.Expr, now)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]models.Sample)
+	var keys []string
+	for _, sample := range input {
+		key := groupKey(sample.Labels, a.By)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], sample)
+	}
+	sort.Strings(keys)
+
+	var result []models.Sample
+	for _, key := range keys {
+		group := groups[key]
+
+		if a.Op == "topk" {
+			sort.SliceStable(group, func(i, j int) bool {
+				return group[i].Value > group[j].Value
+			})
+			result = append(result, group[:min(a.K, len(group))]...)
+			continue
+		}
+
+		labels := make(map[string]string, len(a.By))
+		for _, label := range a.By {
+			labels[label] = group[0].Labels[label]
+		}
+
+		result = append(result, models.Sample{Labels: labels, Value: reduce(a.Op, group)})
+	}
+
+	return result, nil
+}
+
+func groupKey(labels map[string]string, by []string) string {
+	parts := make([]string, len(by))
+	for i, label := range by {
+		parts[i] = label + "=" + labels[label]
+	}
+
+	return strings.Join(parts, "\xff")
+}
+
+func reduce(op string, group []models.Sample) float64 {
+	switch op {
+	case "count":
+		return float64(len(group))
+	case "min":
+		value := math.Inf(1)
+		for _, s := range group {
+			value = math.Min(value, s.Value)
+		}
+		return value
+	case "max":
+		value := math.Inf(-1)
+		for _, s := range group {
+			value = math.Max(value, s.Value)
+		}
+		return value
+	}
+
+	var sum float64
+	for _, s := range group {
+		sum += s.Value
+	}
+	if op == "avg" {
+		return sum / float64(len(group))
+	}
+
+	return sum
+}
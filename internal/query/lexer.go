@@ -0,0 +1,122 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	// tokWord — имя функции, glob, метка, число или длительность.
+	tokWord
+	tokString
+	tokRegex
+	tokOp
+	tokPunct
+)
+
+func (k tokenKind) String() string {
+	switch k {
+	case tokWord:
+		return "name"
+	case tokString:
+		return "string"
+	case tokRegex:
+		return "regex"
+	case tokOp:
+		return "operator"
+	case tokPunct:
+		return "punctuation"
+	default:
+		return "end of query"
+	}
+}
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return t.kind.String()
+	}
+
+	return strconv.Quote(t.text)
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		strings.IndexByte("_.:-*?", c) >= 0
+}
+
+func lex(q string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(q); {
+		c := q[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isWordChar(c):
+			start := i
+			for i < len(q) && isWordChar(q[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokWord, text: q[start:i], pos: start})
+		case c == '"':
+			end := i + 1
+			for end < len(q) && q[end] != '"' {
+				if q[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(q) {
+				return nil, fmt.Errorf("%w: at %d: unterminated string", ErrInvalidQuery, i+1)
+			}
+			value, err := strconv.Unquote(q[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: at %d: invalid string %s", ErrInvalidQuery, i+1, q[i:end+1])
+			}
+			tokens = append(tokens, token{kind: tokString, text: value, pos: i})
+			i = end + 1
+		case c == '/':
+			var b strings.Builder
+			end := i + 1
+			for ; end < len(q) && q[end] != '/'; end++ {
+				if q[end] == '\\' && end+1 < len(q) && q[end+1] == '/' {
+					end++
+				}
+				b.WriteByte(q[end])
+			}
+			if end >= len(q) {
+				return nil, fmt.Errorf("%w: at %d: unterminated regex", ErrInvalidQuery, i+1)
+			}
+			tokens = append(tokens, token{kind: tokRegex, text: b.String(), pos: i})
+			i = end + 1
+		case c == '=' || c == '!':
+			op := string(c)
+			if i+1 < len(q) && (q[i+1] == '=' || q[i+1] == '~') && !(c == '=' && q[i+1] == '=') {
+				op += string(q[i+1])
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("%w: at %d: expected != or !~", ErrInvalidQuery, i+1)
+			}
+			tokens = append(tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		case strings.IndexByte("(){}[],", c) >= 0:
+			tokens = append(tokens, token{kind: tokPunct, text: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("%w: at %d: unexpected character %q", ErrInvalidQuery, i+1, c)
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(q)}), nil
+}
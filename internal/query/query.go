@@ -0,0 +1,447 @@
+// Package query реализует язык запросов к метрикам для GET /query и gRPC Query.
+//
+// Селектор выбирает метрики по имени и меткам:
+//
+//	CPUutilization*                  glob по имени (*, ?)
+//	/Heap(Alloc|Inuse)/              регулярное выражение по имени
+//	HeapAlloc*{type="gauge"}         имя и метки
+//	{host=~"web-.*", type!="counter"}
+//
+// У каждой метрики есть метки __name__ (имя целиком), type (gauge|counter)
+// и host — часть имени после последнего ':' (HeapAlloc:web-1), пустая, если ':' нет.
+// Операторы меток: =, !=, =~, !~. Регулярные выражения привязаны к началу и концу значения.
+//
+// Функции:
+//
+//	sum, avg, min, max, count (expr)   агрегирование, с группировкой: sum by (host) (expr)
+//	topk(k, expr)                      k наибольших значений, в группе при by
+//	rate(selector[1m])                 скорость роста счётчиков в секунду по недавним
+//	                                   значениям из истории хранилища
+package query
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxQueryLength ограничивает длину запроса.
+const MaxQueryLength = 1024
+
+// ErrInvalidQuery оборачивает все ошибки разбора и вычисления запроса.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Метки метрики.
+const (
+	LabelName = "__name__"
+	LabelType = "type"
+	LabelHost = "host"
+)
+
+// Expr — узел разобранного запроса: *Selector, *Rate или *Aggregate.
+type Expr interface {
+	String() string
+}
+
+// Selector выбирает метрики по имени (Glob или Regex) и меткам.
+type Selector struct {
+	Glob     string
+	Regex    *regexp.Regexp
+	Matchers []Matcher
+}
+
+// Matcher — условие на значение метки.
+type Matcher struct {
+	Label string
+	Op    string
+	Value string
+	re    *regexp.Regexp
+}
+
+// Rate — скорость роста счётчиков за окно Window.
+type Rate struct {
+	Selector *Selector
+	Window   time.Duration
+}
+
+// Aggregate — агрегирующая функция. K задан только для topk.
+type Aggregate struct {
+	Op   string
+	By   []string
+	K    int
+	Expr Expr
+}
+
+var aggregations = map[string]bool{
+	"sum":   true,
+	"avg":   true,
+	"min":   true,
+	"max":   true,
+	"count": true,
+	"topk":  true,
+}
+
+// Parse разбирает запрос.
+func Parse(q string) (Expr, error) {
+	if len(q) > MaxQueryLength {
+		return nil, fmt.Errorf("%w: query is longer than %d characters", ErrInvalidQuery, MaxQueryLength)
+	}
+
+	tokens, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+
+	return e, nil
+}
+
+// Match сообщает, подходит ли метрика с метками labels под селектор.
+func (s *Selector) Match(labels map[string]string) bool {
+	name := labels[LabelName]
+
+	if s.Glob != "" {
+		if ok, _ := path.Match(s.Glob, name); !ok {
+			return false
+		}
+	}
+	if s.Regex != nil && !s.Regex.MatchString(name) {
+		return false
+	}
+
+	for _, m := range s.Matchers {
+		if !m.match(labels[m.Label]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m Matcher) match(value string) bool {
+	switch m.Op {
+	case "=":
+		return value == m.Value
+	case "!=":
+		return value != m.Value
+	case "=~":
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+func (s *Selector) String() string {
+	var b strings.Builder
+
+	switch {
+	case s.Glob != "":
+		b.WriteString(s.Glob)
+	case s.Regex != nil:
+		// В Regex лежит выражение с привязкой ^(?:...)$.
+		src := s.Regex.String()
+		b.WriteString("/" + strings.ReplaceAll(src[4:len(src)-2], "/", `\/`) + "/")
+	}
+
+	if len(s.Matchers) > 0 {
+		parts := make([]string, len(s.Matchers))
+		for i, m := range s.Matchers {
+			parts[i] = m.Label + m.Op + strconv.Quote(m.Value)
+		}
+		b.WriteString("{" + strings.Join(parts, ", ") + "}")
+	}
+
+	return b.String()
+}
+
+func (r *Rate) String() string {
+	return fmt.Sprintf("rate(%s[%s])", r.Selector, r.Window)
+}
+
+func (a *Aggregate) String() string {
+	var b strings.Builder
+
+	b.WriteString(a.Op)
+	if len(a.By) > 0 {
+		b.WriteString(" by (" + strings.Join(a.By, ", ") + ")")
+	}
+	b.WriteString("(")
+	if a.Op == "topk" {
+		b.WriteString(strconv.Itoa(a.K) + ", ")
+	}
+	b.WriteString(a.Expr.String() + ")")
+
+	return b.String()
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, text string) (token, error) {
+	t := p.next()
+	if t.kind != kind || (text != "" && t.text != text) {
+		want := text
+		if want == "" {
+			want = kind.String()
+		}
+		return t, p.errorf(t, "expected %s, got %s", want, t)
+	}
+
+	return t, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("%w: at %d: %s", ErrInvalidQuery, t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expr() (Expr, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokWord:
+		after := p.tokens[p.pos+1]
+		if after.kind == tokPunct && after.text == "(" {
+			return p.call()
+		}
+		if aggregations[t.text] && after.kind == tokWord && after.text == "by" {
+			return p.call()
+		}
+		return p.selector()
+	case tokRegex:
+		return p.selector()
+	case tokPunct:
+		if t.text == "{" {
+			return p.selector()
+		}
+	}
+
+	return nil, p.errorf(t, "expected selector or function, got %s", t)
+}
+
+func (p *parser) call() (Expr, error) {
+	name := p.next()
+
+	if name.text == "rate" {
+		return p.rate()
+	}
+	if !aggregations[name.text] {
+		return nil, p.errorf(name, "unknown function %q", name.text)
+	}
+
+	agg := &Aggregate{Op: name.text}
+
+	by, err := p.by()
+	if err != nil {
+		return nil, err
+	}
+	agg.By = by
+
+	if _, err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+
+	if agg.Op == "topk" {
+		k, err := p.expect(tokWord, "")
+		if err != nil {
+			return nil, err
+		}
+		agg.K, err = strconv.Atoi(k.text)
+		if err != nil || agg.K <= 0 {
+			return nil, p.errorf(k, "topk expects a positive integer, got %q", k.text)
+		}
+		if _, err := p.expect(tokPunct, ","); err != nil {
+			return nil, err
+		}
+	}
+
+	agg.Expr, err = p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(tokPunct, ")"); err != nil {
+		return nil, err
+	}
+
+	if agg.By == nil {
+		if agg.By, err = p.by(); err != nil {
+			return nil, err
+		}
+	}
+
+	return agg, nil
+}
+
+// by разбирает необязательную группировку "by (label, ...)".
+func (p *parser) by() ([]string, error) {
+	if t := p.peek(); t.kind != tokWord || t.text != "by" {
+		return nil, nil
+	}
+	p.next()
+
+	if _, err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+
+	var labels []string
+	for {
+		label, err := p.expect(tokWord, "")
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label.text)
+
+		sep := p.next()
+		if sep.kind == tokPunct && sep.text == ")" {
+			return labels, nil
+		}
+		if sep.kind != tokPunct || sep.text != "," {
+			return nil, p.errorf(sep, "expected , or ), got %s", sep)
+		}
+	}
+}
+
+func (p *parser) rate() (Expr, error) {
+	if _, err := p.expect(tokPunct, "("); err != nil {
+		return nil, err
+	}
+
+	e, err := p.selector()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(tokPunct, "["); err != nil {
+		return nil, err
+	}
+	window, err := p.expect(tokWord, "")
+	if err != nil {
+		return nil, err
+	}
+	d, err := time.ParseDuration(window.text)
+	if err != nil || d <= 0 {
+		return nil, p.errorf(window, "invalid rate window %q (expect a duration like 30s or 1m)", window.text)
+	}
+	if _, err := p.expect(tokPunct, "]"); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokPunct, ")"); err != nil {
+		return nil, err
+	}
+
+	return &Rate{Selector: e.(*Selector), Window: d}, nil
+}
+
+func (p *parser) selector() (Expr, error) {
+	s := &Selector{}
+	t := p.peek()
+
+	switch t.kind {
+	case tokWord:
+		p.next()
+		if _, err := path.Match(t.text, ""); err != nil {
+			return nil, p.errorf(t, "invalid pattern %q", t.text)
+		}
+		s.Glob = t.text
+	case tokRegex:
+		p.next()
+		re, err := anchored(t.text)
+		if err != nil {
+			return nil, p.errorf(t, "invalid regex %q: %v", t.text, err)
+		}
+		s.Regex = re
+	}
+
+	if t := p.peek(); t.kind == tokPunct && t.text == "{" {
+		p.next()
+		matchers, err := p.matchers()
+		if err != nil {
+			return nil, err
+		}
+		s.Matchers = matchers
+	}
+
+	if s.Glob == "" && s.Regex == nil && len(s.Matchers) == 0 {
+		return nil, p.errorf(t, "selector needs a name pattern or a label matcher")
+	}
+
+	return s, nil
+}
+
+func (p *parser) matchers() ([]Matcher, error) {
+	var matchers []Matcher
+
+	if t := p.peek(); t.kind == tokPunct && t.text == "}" {
+		p.next()
+		return nil, nil
+	}
+
+	for {
+		label, err := p.expect(tokWord, "")
+		if err != nil {
+			return nil, err
+		}
+
+		op := p.next()
+		if op.kind != tokOp {
+			return nil, p.errorf(op, "expected =, !=, =~ or !~, got %s", op)
+		}
+
+		value, err := p.expect(tokString, "")
+		if err != nil {
+			return nil, err
+		}
+
+		m := Matcher{Label: label.text, Op: op.text, Value: value.text}
+		if m.Op == "=~" || m.Op == "!~" {
+			m.re, err = anchored(m.Value)
+			if err != nil {
+				return nil, p.errorf(value, "invalid regex %q: %v", m.Value, err)
+			}
+		}
+		matchers = append(matchers, m)
+
+		sep := p.next()
+		if sep.kind == tokPunct && sep.text == "}" {
+			return matchers, nil
+		}
+		if sep.kind != tokPunct || sep.text != "," {
+			return nil, p.errorf(sep, "expected , or }, got %s", sep)
+		}
+	}
+}
+
+func anchored(expr string) (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(expr); err != nil {
+		return nil, err
+	}
+
+	return regexp.Compile("^(?:" + expr + ")$")
+}
@@ -0,0 +1,209 @@
+package query
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	models "metrify/internal/model"
+	"metrify/internal/service"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "CPUutilization*", want: "CPUutilization*"},
+		{query: `  /Heap(Alloc|Inuse)/ `, want: "/Heap(Alloc|Inuse)/"},
+		{query: `{host=~"web-.*",type!="counter"}`, want: `{host=~"web-.*", type!="counter"}`},
+		{query: `sum(CPU*)`, want: "sum(CPU*)"},
+		{query: `max by (host) (HeapAlloc*)`, want: "max by (host)(HeapAlloc*)"},
+		{query: `avg(HeapAlloc*) by (type, host)`, want: "avg by (type, host)(HeapAlloc*)"},
+		{query: `topk(3, {type="gauge"})`, want: `topk(3, {type="gauge"})`},
+		{query: `sum(rate(Poll*[1m]))`, want: "sum(rate(Poll*[1m0s]))"},
+		{query: `count(/a\/b/)`, want: `count(/a\/b/)`},
+		{query: `sum`, want: "sum"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			e, err := Parse(tt.query)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := e.String(); got != tt.want {
+				t.Fatalf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, q := range []string{
+		"",
+		"{}",
+		"median(Alloc)",
+		"sum(Alloc",
+		"topk(0, Alloc)",
+		"topk(Alloc)",
+		"rate(Alloc)",
+		"rate(Alloc[soon])",
+		`Alloc{host="a"`,
+		`Alloc{host "a"}`,
+		`Alloc{host=~"("}`,
+		"/(/",
+		"Alloc[",
+		"sum(Alloc) Free",
+		"Alloc ! x",
+		`Alloc{host="a}`,
+	} {
+		t.Run(q, func(t *testing.T) {
+			if _, err := Parse(q); !errors.Is(err, ErrInvalidQuery) {
+				t.Fatalf("Parse(%q) error = %v, want ErrInvalidQuery", q, err)
+			}
+		})
+	}
+}
+
+// historyStub подменяет историю хранилища точками с известным временем.
+type historyStub struct {
+	service.Storage
+	points map[string][]models.Point
+}
+
+func (s historyStub) History(_, name string) []models.Point {
+	return s.points[name]
+}
+
+func newTestStorage(t *testing.T) service.Storage {
+	t.Helper()
+
+	ms := service.NewMemStorage("", nil)
+	for name, value := range map[string]float64{"HeapAlloc:web-1": 10, "HeapAlloc:web-2": 30, "HeapAlloc": 20, "CPUutilization1": 0.5, "CPUutilization2": 1.5} {
+		if err := ms.UpdateGauge(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, delta := range map[string]int64{"PollCount:web-1": 100, "PollCount:web-2": 7} {
+		if err := ms.UpdateCounter(name, delta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ms
+}
+
+func values(samples []models.Sample) map[string]float64 {
+	got := make(map[string]float64, len(samples))
+	for _, s := range samples {
+		key := s.Labels[LabelName]
+		if key == "" {
+			key = s.Labels[LabelHost] + s.Labels[LabelType]
+		}
+		got[key] = s.Value
+	}
+
+	return got
+}
+
+func TestRun(t *testing.T) {
+	storage := newTestStorage(t)
+
+	tests := []struct {
+		query string
+		want  map[string]float64
+	}{
+		{query: "CPUutilization*", want: map[string]float64{"CPUutilization1": 0.5, "CPUutilization2": 1.5}},
+		{query: "sum(CPUutilization*)", want: map[string]float64{"": 2}},
+		{query: "max(HeapAlloc*)", want: map[string]float64{"": 30}},
+		{query: `min({host=~"web-.*", type="gauge"})`, want: map[string]float64{"": 10}},
+		{query: "avg(/HeapAlloc(:.*)?/)", want: map[string]float64{"": 20}},
+		{query: `count({type!="counter"})`, want: map[string]float64{"": 5}},
+		{query: "sum by (host) (*:*)", want: map[string]float64{"web-1": 110, "web-2": 37}},
+		{query: "sum(PollCount*) by (type)", want: map[string]float64{"counter": 107}},
+		{query: "topk(2, {type=\"gauge\"})", want: map[string]float64{"HeapAlloc:web-2": 30, "HeapAlloc": 20}},
+		{query: "topk by (type) (1, *)", want: map[string]float64{"HeapAlloc:web-2": 30, "PollCount:web-1": 100}},
+		{query: "sum(Missing*)", want: map[string]float64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			samples, err := Run(storage, tt.query, time.Now())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if samples == nil {
+				t.Fatal("Run() returned nil samples")
+			}
+
+			got := values(samples)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Run() = %v, want %v", got, tt.want)
+			}
+			for key, want := range tt.want {
+				if math.Abs(got[key]-want) > 1e-9 {
+					t.Fatalf("Run() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Rate(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	at := func(ago time.Duration, v float64) models.Point {
+		return models.Point{T: now.Add(-ago).UnixMilli(), V: v}
+	}
+
+	storage := historyStub{
+		Storage: newTestStorage(t),
+		points: map[string][]models.Point{
+			// Старая точка вне окна не учитывается.
+			"PollCount:web-1": {at(5*time.Minute, 0), at(60*time.Second, 40), at(30*time.Second, 70), at(0, 100)},
+			// Сброс счётчика: 5 -> 2 даёт прирост 2.
+			"PollCount:web-2": {at(20*time.Second, 0), at(10*time.Second, 5), at(0, 7)},
+			"HeapAlloc:web-1": {at(10*time.Second, 0), at(0, 10)},
+		},
+	}
+	storage.points["PollCount:web-2"][2].V = 2
+
+	samples, err := Run(storage, "rate(PollCount*[90s])", now)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	got := values(samples)
+	if len(got) != 2 || got["PollCount:web-1"] != 1 || got["PollCount:web-2"] != 7.0/20 {
+		t.Fatalf("rate = %v", got)
+	}
+
+	samples, err = Run(storage, "rate(PollCount:web-1[45s])", now)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := values(samples); got["PollCount:web-1"] != 1 {
+		t.Fatalf("rate over a short window = %v", got)
+	}
+
+	samples, _ = Run(storage, `rate({type="gauge"}[1m])`, now)
+	if len(samples) != 0 {
+		t.Fatalf("rate must skip gauges, got %v", samples)
+	}
+
+	samples, _ = Run(storage, "rate(PollCount:web-1[1s])", now)
+	if len(samples) != 0 {
+		t.Fatalf("rate needs two points in the window, got %v", samples)
+	}
+
+	samples, _ = Run(storage, "sum by (host) (rate(PollCount*[90s]))", now)
+	hosts := make([]string, 0, len(samples))
+	for _, s := range samples {
+		hosts = append(hosts, s.Labels[LabelHost])
+	}
+	sort.Strings(hosts)
+	if len(hosts) != 2 || hosts[0] != "web-1" || hosts[1] != "web-2" {
+		t.Fatalf("sum by host of rate = %v", samples)
+	}
+}
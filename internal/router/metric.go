@@ -4,20 +4,50 @@ package router
 // Metric возвращает chi.Router с зарегистрированными middleware и эндпоинтами метрик.
 //
 // Маршруты:
-//   GET  /           - info
+//   GET  /           - dashboard (HTML)
 //   GET  /ping       - db ping
-//   POST /updates/   - batch update (JSON)
+//   GET  /healthz    - liveness
+//   GET  /readyz     - readiness with a breakdown per check (503 if any fails)
+//   POST /updates/   - batch update (JSON, protobuf, MessagePack)
 //   POST /update/    - update (JSON)
 //   POST /update/counter/{name}/{value} - update counter (text/plain)
 //   POST /update/gauge/{name}/{value}   - update gauge (text/plain)
-//   POST /value/     - get metric by body (JSON)
+//   POST /value/     - get metric by body (JSON, protobuf, MessagePack)
 //   GET  /value/counter/{name}          - get counter (text/plain)
 //   GET  /value/gauge/{name}            - get gauge (text/plain)
+//   GET  /watch      - metric updates stream (text/event-stream)
+//   GET  /query?q=   - query with aggregation (JSON, {"data": [...]})
+//   GET  /alerts     - alert states (pending, firing, resolved), ?state= filters
+//
+// API v2 отвечает JSON-конвертом {"data": ...}, ошибки, включая 401, 403, 404, 405, 413, 415 и 429
+// из middleware и маршрутизации, — application/problem+json:
+//   GET    /api/v2/metrics                - list metrics
+//   GET    /api/v2/history                - metrics with recent history
+//   GET    /api/v2/metrics/{type}/{name}  - get metric
+//   PUT    /api/v2/metrics/{type}/{name}  - set gauge or add counter delta
+//   DELETE /api/v2/metrics/{type}/{name}  - delete metric
+//   POST   /api/v2/metrics:batch          - batch update
+//   GET    /api/v2/export                 - export metrics (CSV, NDJSON)
+//   POST   /api/v2/import                 - bulk import (CSV, NDJSON), progress as NDJSON
+//   GET    /api/v2/silences               - maintenance windows, ?state= filters
+//   POST   /api/v2/silences               - create a maintenance window
+//   DELETE /api/v2/silences/{id}          - expire a maintenance window
+//
+// Метрики под активным окном обслуживания помечаются в ответах: "silenced": true
+// в JSON и заголовок X-Silenced в GET /value/{type}/{name}.
+//
+// Если включена аутентификация, /update*, /updates и запись v2 (включая окна обслуживания) требуют права metrics:write,
+// /value*, /watch, /query, /alerts и чтение v2 — metrics:read, экспорт и импорт — admin,
+// /, /ping, /healthz, /readyz и /swagger открыты. Потоковые ответы (/watch, экспорт, импорт) не подписываются.
+// При заданном TRUSTED_SUBNET все маршруты, кроме /healthz и /readyz, доступны только из доверенных подсетей.
+// Частота запросов ограничивается по адресу до проверки токена и по токену после неё.
+// Метрики разделены по арендаторам: арендатор берётся из токена или
+// заголовка X-Tenant-ID, без них используется арендатор default.
 import (
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	httpSwagger "github.com/swaggo/http-swagger"
 	_ "metrify/docs"
+	"metrify/internal/auth"
 	"metrify/internal/handler"
 )
 
@@ -25,50 +55,110 @@ func Metric(handler *handler.Handler) chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(handler.WithLogging)
-	r.Use(handler.WithRequestCompress)
-	r.Use(handler.WithResponseCompress)
-	r.Use(handler.WithHashedRequest)
-	r.Use(handler.WithDecrypt)
-	r.Get("/swagger/*", httpSwagger.WrapHandler)
+	// Пробы оркестратора приходят с адресов узлов вне TRUSTED_SUBNET и без подписи.
+	r.Get("/healthz", handler.Healthz)
+	r.Get("/readyz", handler.Readyz)
+
+	r.Group(func(r chi.Router) {
+		r.Use(handler.WithTrustedSubnet)
+		// Агент сжимает и подписывает тело до шифрования, поэтому распаковка
+		// и проверка подписи идут после расшифровки.
+		r.Use(handler.WithDecrypt)
+		r.Use(handler.WithRequestCompress)
+		r.Use(handler.WithBodyLimit)
+		r.Use(handler.WithResponseCompress)
+		r.Use(handler.WithHashedRequest)
+		r.Get("/swagger/*", httpSwagger.WrapHandler)
+		r.With(handler.WithPeerRateLimit, handler.RequireScope(auth.ScopeRead), handler.WithTenant, handler.WithRateLimit).Get("/watch", handler.WatchMetrics)
+
+		r.Route("/api/v2", func(r chi.Router) {
+			r.NotFound(handler.NotFound)
+			r.MethodNotAllowed(handler.MethodNotAllowed(r))
+			bulk(r, handler)
 
-	update(r, handler)
-	get(r, handler)
+			r.Group(func(r chi.Router) {
+				r.Use(handler.WithSignedResponse)
+				v2(r, handler)
+			})
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(handler.WithSignedResponse)
+
+			update(r, handler)
+			get(r, handler)
+		})
+	})
 
 	return r
 }
 
 func update(r chi.Router, handler *handler.Handler) {
+	r = r.With(handler.WithPeerRateLimit, handler.RequireScope(auth.ScopeWrite), handler.WithTenant, handler.WithRateLimit)
+
 	r.Route("/updates", func(r chi.Router) {
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(handler.AllowContentType(handler.BodyContentTypes...)).
 			Post("/", handler.UpdateMetricsBatch)
 	})
 
 	r.Route("/update", func(r chi.Router) {
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(handler.AllowContentType("application/json")).
 			Post("/", handler.UpdateMetrics)
 
-		r.With(middleware.AllowContentType(handler.AllowedContentType)).
+		r.With(handler.AllowContentType(handler.AllowedContentType)).
 			Post("/counter/{name}/{value}", handler.UpdateCounter)
-		r.With(middleware.AllowContentType(handler.AllowedContentType)).
+		r.With(handler.AllowContentType(handler.AllowedContentType)).
 			Post("/gauge/{name}/{value}", handler.UpdateGauge)
-		r.With(middleware.AllowContentType(handler.AllowedContentType)).
+		r.With(handler.AllowContentType(handler.AllowedContentType)).
 			Post("/{type}/{name}/{value}", handler.InvalidMetricHandler)
 	})
 }
 
 func get(r chi.Router, handler *handler.Handler) {
-	r.Get("/", handler.GetInfo)
 	r.Get("/ping", handler.Ping)
+	// Страница дашборда не содержит данных: токен она спрашивает сама и передаёт в /api/v2/history.
+	r.Get("/", handler.GetInfo)
+
+	r = r.With(handler.WithPeerRateLimit, handler.RequireScope(auth.ScopeRead), handler.WithTenant, handler.WithRateLimit)
+	r.Get("/query", handler.Query)
+	r.Get("/alerts", handler.ListAlerts)
 
 	r.Route("/value", func(r chi.Router) {
-		r.With(middleware.AllowContentType("application/json")).
+		r.With(handler.AllowContentType(handler.BodyContentTypes...)).
 			Post("/", handler.GetMetrics)
 
-		r.With(middleware.AllowContentType(handler.AllowedContentType)).
+		r.With(handler.AllowContentType(handler.AllowedContentType)).
 			Get("/counter/{name}", handler.GetCounter)
-		r.With(middleware.AllowContentType(handler.AllowedContentType)).
+		r.With(handler.AllowContentType(handler.AllowedContentType)).
 			Get("/gauge/{name}", handler.GetGauge)
-		r.With(middleware.AllowContentType(handler.AllowedContentType)).
+		r.With(handler.AllowContentType(handler.AllowedContentType)).
 			Get("/{type}/{name}", handler.InvalidMetricHandler)
 	})
 }
+
+func bulk(r chi.Router, handler *handler.Handler) {
+	r = r.With(handler.WithPeerRateLimit, handler.RequireScope(auth.ScopeAdmin), handler.WithTenant, handler.WithRateLimit)
+
+	r.Get("/export", handler.ExportMetrics)
+	r.With(handler.AllowContentType("text/csv", "application/x-ndjson")).
+		Post("/import", handler.ImportMetrics)
+}
+
+func v2(r chi.Router, handler *handler.Handler) {
+	read := r.With(handler.WithPeerRateLimit, handler.RequireScope(auth.ScopeRead), handler.WithTenant, handler.WithRateLimit)
+	read.Get("/metrics", handler.ListMetricsV2)
+	read.Get("/metrics/{type}/{name}", handler.GetMetricV2)
+	read.Get("/history", handler.HistoryV2)
+
+	write := r.With(handler.WithPeerRateLimit, handler.RequireScope(auth.ScopeWrite), handler.WithTenant, handler.WithRateLimit)
+	write.With(handler.AllowContentType("application/json")).
+		Put("/metrics/{type}/{name}", handler.PutMetricV2)
+	write.Delete("/metrics/{type}/{name}", handler.DeleteMetricV2)
+	write.With(handler.AllowContentType("application/json")).
+		Post("/metrics:batch", handler.BatchMetricsV2)
+
+	read.Get("/silences", handler.ListSilences)
+	write.With(handler.AllowContentType("application/json")).
+		Post("/silences", handler.CreateSilence)
+	write.Delete("/silences/{id}", handler.ExpireSilence)
+}
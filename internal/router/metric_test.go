@@ -1,18 +1,34 @@
 package router
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 	"go.uber.org/zap"
+	gproto "google.golang.org/protobuf/proto"
 	"io"
+	"metrify/internal/agent"
+	"metrify/internal/alert"
 	"metrify/internal/audit"
+	"metrify/internal/auth"
 	"metrify/internal/handler"
+	models "metrify/internal/model"
+	"metrify/internal/proto"
 	"metrify/internal/service"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 func newTestStorage() *service.MemStorage {
@@ -26,7 +42,7 @@ func newTestHandler() *handler.Handler {
 	ms := newTestStorage()
 	logger := zap.NewNop().Sugar()
 	p := audit.NewPublisher()
-	h := handler.NewHandler(ms, logger, nil, p, false, "", nil, "")
+	h := handler.NewHandler(ms, logger, nil, p, false, "", nil, nil)
 	return h
 }
 
@@ -88,6 +104,296 @@ func TestMetric(t *testing.T) {
 	}
 }
 
+func TestMetric_Watch(t *testing.T) {
+	h := newTestHandler()
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/watch?pattern=Heap*&type=gauge", nil)
+	require.NoError(t, err)
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	for _, url := range []string{"/update/gauge/Alloc/1", "/update/counter/HeapCount/1", "/update/gauge/HeapAlloc/2.5"} {
+		r, err := ts.Client().Post(ts.URL+url, "text/plain", nil)
+		require.NoError(t, err)
+		_ = r.Body.Close()
+	}
+
+	sc := bufio.NewScanner(resp.Body)
+	var event, data string
+	for sc.Scan() {
+		line := sc.Text()
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			event = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+			break
+		}
+	}
+
+	assert.Equal(t, "metric", event)
+	assert.JSONEq(t, `{"id":"HeapAlloc","type":"gauge","value":2.5}`, data)
+}
+
+func TestMetric_WatchInvalidFilter(t *testing.T) {
+	h := newTestHandler()
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	resp := testRequest(t, ts, http.MethodGet, "/watch?type=histogram")
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestMetric_SignedResponse(t *testing.T) {
+	h := newTestHandler()
+	h.Keys = service.NewStaticKeyRing("secret")
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	// Настоящий агент проверяет подпись ответа сам, в том числе после gzip.
+	client := agent.NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "secret", "", "", nil, nil)
+	v := 2.5
+	require.NoError(t, client.UpdateMetric(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v}))
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/value/gauge/Alloc", nil)
+	require.NoError(t, err)
+	req.Header.Set(service.NonceHeader, "n1")
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "2.5", string(body))
+	assert.Equal(t, service.SignResponse(http.StatusOK, "n1", body, "secret"), resp.Header.Get("HashSHA256"))
+}
+
+func TestMetric_CompressedAgentBatch(t *testing.T) {
+	h := newTestHandler()
+	h.Keys = service.NewStaticKeyRing("secret")
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	for _, encoding := range []string{service.EncodingGzip, service.EncodingDeflate, service.EncodingZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			client := agent.NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "secret", "", "", nil, nil)
+			client.Compression = encoding
+
+			metrics := make([]models.Metrics, 0, 100)
+			for i := range 100 {
+				v := float64(i)
+				metrics = append(metrics, models.Metrics{ID: fmt.Sprintf("%s%d", encoding, i), MType: models.Gauge, Value: &v})
+			}
+			require.NoError(t, client.UpdateMetrics(metrics))
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/value/gauge/"+encoding+"99", nil)
+			require.NoError(t, err)
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, "99", string(body))
+		})
+	}
+}
+
+// TestMetric_EncryptedAgentBatch прогоняет агента через всю цепочку middleware:
+// расшифровку, распаковку, проверку подписи и AllowContentType.
+func TestMetric_EncryptedAgentBatch(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	h := handler.NewHandler(newTestStorage(), zap.NewNop().Sugar(), nil, audit.NewPublisher(), false, "", privKey, nil)
+	h.Keys = service.NewStaticKeyRing("secret")
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		format      string
+		compression string
+		encryption  string
+		size        int
+	}{
+		{name: "json", format: models.ContentTypeJSON, compression: service.EncodingGzip, encryption: service.EncryptionHybrid, size: 100},
+		{name: "protobuf", format: models.ContentTypeProtobuf, compression: service.EncodingZstd, encryption: service.EncryptionHybrid, size: 100},
+		{name: "uncompressed", format: models.ContentTypeJSON, encryption: service.EncryptionHybrid, size: 100},
+		{name: "legacy", format: models.ContentTypeJSON, compression: service.EncodingGzip, encryption: service.EncryptionRSAPKCS1v15, size: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := agent.NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "secret", "", "", &privKey.PublicKey, nil)
+			client.Format = tt.format
+			client.Compression = tt.compression
+			client.Encryption = tt.encryption
+
+			metrics := make([]models.Metrics, 0, tt.size)
+			for i := range tt.size {
+				v := float64(i)
+				metrics = append(metrics, models.Metrics{ID: fmt.Sprintf("%s%d", tt.name, i), MType: models.Gauge, Value: &v})
+			}
+			require.NoError(t, client.UpdateMetrics(metrics))
+
+			resp, err := ts.Client().Get(fmt.Sprintf("%s/value/gauge/%s%d", ts.URL, tt.name, tt.size-1))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			assert.Equal(t, fmt.Sprint(tt.size-1), string(body))
+		})
+	}
+}
+
+func TestMetric_BinaryContentTypes(t *testing.T) {
+	ts := httptest.NewServer(Metric(newTestHandler()))
+	defer ts.Close()
+
+	client := agent.NewHTTPClient(strings.TrimPrefix(ts.URL, "http://"), zap.NewNop().Sugar(), "", "", "", nil, nil)
+	client.Format = models.ContentTypeProtobuf
+
+	v, d := 1.25, int64(4)
+	require.NoError(t, client.UpdateMetrics([]models.Metrics{
+		{ID: "Alloc", MType: models.Gauge, Value: &v},
+		{ID: "Hits", MType: models.Counter, Delta: &d},
+	}))
+
+	post := func(body []byte, contentType, accept string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/value/", bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		return resp, data
+	}
+
+	query := &proto.Metric{}
+	query.SetId("Hits")
+	query.SetType(proto.Metric_COUNTER)
+	body, err := gproto.Marshal(query)
+	require.NoError(t, err)
+
+	resp, data := post(body, models.ContentTypeProtobuf, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, models.ContentTypeProtobuf, resp.Header.Get("Content-Type"))
+	got := &proto.Metric{}
+	require.NoError(t, gproto.Unmarshal(data, got))
+	assert.Equal(t, int64(4), got.GetDelta())
+
+	body, err = msgpack.Marshal(map[string]string{"id": "Alloc", "type": models.Gauge})
+	require.NoError(t, err)
+
+	resp, data = post(body, models.ContentTypeMsgpack, "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, models.ContentTypeMsgpack, resp.Header.Get("Content-Type"))
+	var metric map[string]any
+	require.NoError(t, msgpack.Unmarshal(data, &metric))
+	assert.Equal(t, 1.25, metric["value"])
+
+	resp, data = post(body, models.ContentTypeMsgpack, "application/json")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"id":"Alloc","type":"gauge","value":1.25}`, string(data))
+
+	resp, _ = post([]byte("<metric/>"), "application/xml", "")
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+type auditRecorder struct {
+	events []audit.Event
+}
+
+func (r *auditRecorder) Receive(_ context.Context, e audit.Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+func TestMetric_Auth(t *testing.T) {
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "agent", Hash: auth.HashToken("write-token"), Scopes: []string{auth.ScopeWrite}},
+		{Name: "dashboard", Hash: auth.HashToken("read-token"), Scopes: []string{auth.ScopeRead}},
+	})
+	require.NoError(t, err)
+
+	recorder := &auditRecorder{}
+	h := handler.NewHandler(newTestStorage(), zap.NewNop().Sugar(), nil, audit.NewPublisher(recorder), false, "", nil, nil)
+	h.Auth = authenticator
+
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{name: "update without token", method: http.MethodPost, path: "/update/gauge/Alloc/1", want: http.StatusUnauthorized},
+		{name: "update with bad token", method: http.MethodPost, path: "/update/gauge/Alloc/1", header: "Authorization", value: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "update with read token", method: http.MethodPost, path: "/update/gauge/Alloc/1", header: "Authorization", value: "Bearer read-token", want: http.StatusForbidden},
+		{name: "update with write token", method: http.MethodPost, path: "/update/gauge/Alloc/1", header: "Authorization", value: "Bearer write-token", want: http.StatusOK},
+		{name: "read with write token", method: http.MethodGet, path: "/value/gauge/Alloc", header: "X-API-Key", value: "write-token", want: http.StatusForbidden},
+		{name: "read with read token", method: http.MethodGet, path: "/value/gauge/Alloc", header: "X-API-Key", value: "read-token", want: http.StatusOK},
+		{name: "dashboard page without token", method: http.MethodGet, path: "/", want: http.StatusOK},
+		{name: "dashboard data without token", method: http.MethodGet, path: "/api/v2/history", want: http.StatusUnauthorized},
+		{name: "watch without token", method: http.MethodGet, path: "/watch", want: http.StatusUnauthorized},
+		{name: "ping is open", method: http.MethodGet, path: "/ping", want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, nil)
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "text/plain")
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			resp, err := ts.Client().Do(req)
+			require.NoError(t, err)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, tt.want, resp.StatusCode)
+		})
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/update", strings.NewReader(`{"id":"Alloc","type":"gauge","value":1}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer write-token")
+
+	resp, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.Len(t, recorder.events, 1)
+	assert.Equal(t, "agent", recorder.events[0].Identity)
+}
+
 func testRequest(t *testing.T, ts *httptest.Server, method,
 	path string) *http.Response {
 	req, err := http.NewRequest(method, ts.URL+path, nil)
@@ -205,3 +511,684 @@ func ExampleMetric_invalidMetric() {
 
 	fmt.Println(resp.StatusCode)
 }
+
+// Лимит по адресу стоит до проверки токена, поэтому перебор токенов упирается в 429.
+func TestMetric_RateLimitBeforeAuth(t *testing.T) {
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "agent", Hash: auth.HashToken("write-token"), Scopes: []string{auth.ScopeWrite}},
+	})
+	require.NoError(t, err)
+
+	h := handler.NewHandler(newTestStorage(), zap.NewNop().Sugar(), nil, nil, false, "", nil, nil)
+	h.Auth = authenticator
+	h.Limiter = service.NewRateLimiter(0.001, 3)
+
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	send := func(token string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/update/gauge/Alloc/1", nil)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+
+		return resp
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, send(fmt.Sprintf("guess-%d", i)).StatusCode)
+	}
+
+	resp := send("guess-3")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Equal(t, http.StatusTooManyRequests, send("write-token").StatusCode)
+}
+
+func TestMetric_Tenants(t *testing.T) {
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "team-a", Hash: auth.HashToken("a-token"), Scopes: []string{auth.ScopeWrite, auth.ScopeRead}, Tenant: "team-a"},
+		{Name: "team-b", Hash: auth.HashToken("b-token"), Scopes: []string{auth.ScopeWrite, auth.ScopeRead}, Tenant: "team-b"},
+	})
+	require.NoError(t, err)
+
+	ms := newTestStorage()
+	ms.SetQuotas(service.Quotas{Default: 1})
+
+	h := handler.NewHandler(ms, zap.NewNop().Sugar(), nil, audit.NewPublisher(), false, "", nil, nil)
+	h.Auth = authenticator
+
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	do := func(method, path, token, tenant string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Authorization", "Bearer "+token)
+		if tenant != "" {
+			req.Header.Set(service.TenantHeader, tenant)
+		}
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	code, _ := do(http.MethodPost, "/update/gauge/Alloc/1", "a-token", "")
+	require.Equal(t, http.StatusOK, code)
+	code, _ = do(http.MethodPost, "/update/gauge/Alloc/2", "b-token", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := do(http.MethodGet, "/value/gauge/Alloc", "a-token", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1", body)
+
+	code, body = do(http.MethodGet, "/value/gauge/Alloc", "b-token", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "2", body)
+
+	code, _ = do(http.MethodGet, "/value/gauge/Alloc", "a-token", "team-b")
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _ = do(http.MethodPost, "/update/gauge/Other/1", "a-token", "")
+	assert.Equal(t, http.StatusForbidden, code, "quota of one metric exceeded")
+
+	_, ok := ms.GetGauge("Alloc")
+	assert.False(t, ok, "default tenant must stay empty")
+}
+
+func TestMetric_V2(t *testing.T) {
+	ts := httptest.NewServer(Metric(newTestHandler()))
+	defer ts.Close()
+
+	do := func(method, path, body string) (*http.Response, string) {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, body := do(http.MethodGet, "/api/v2/metrics/gauge/Alloc", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, models.ProblemContentType, resp.Header.Get("Content-Type"))
+	assert.Contains(t, body, models.ProblemNotFound)
+
+	resp, body = do(http.MethodPut, "/api/v2/metrics/gauge/Alloc", `{"value":1.5}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"data":{"id":"Alloc","type":"gauge","value":1.5}}`, body)
+
+	do(http.MethodPut, "/api/v2/metrics/counter/Hits", `{"delta":2}`)
+	resp, body = do(http.MethodPut, "/api/v2/metrics/counter/Hits", `{"delta":3}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"data":{"id":"Hits","type":"counter","delta":5}}`, body)
+
+	resp, _ = do(http.MethodPut, "/api/v2/metrics/counter/Hits", `{"value":1}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "counter with value")
+
+	resp, _ = do(http.MethodPut, "/api/v2/metrics/gauge/Alloc", `{"id":"Other","value":1}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "id mismatch")
+
+	resp, _ = do(http.MethodGet, "/api/v2/metrics/histogram/Alloc", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp, body = do(http.MethodGet, "/api/v2/metrics/gauge/Alloc", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"data":{"id":"Alloc","type":"gauge","value":1.5}}`, body)
+
+	resp, body = do(http.MethodPost, "/api/v2/metrics:batch", `[{"id":"Hits","type":"counter","delta":1},{"id":"Free","type":"gauge","value":2}]`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"data":[{"id":"Hits","type":"counter","delta":6},{"id":"Free","type":"gauge","value":2}]}`, body)
+
+	resp, body = do(http.MethodPost, "/api/v2/metrics:batch", `[{"id":"Bad","type":"gauge"}]`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, models.ProblemInvalidMetrics)
+
+	resp, body = do(http.MethodGet, "/api/v2/metrics", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"data":[{"id":"Alloc","type":"gauge","value":1.5},{"id":"Free","type":"gauge","value":2},{"id":"Hits","type":"counter","delta":6}]}`, body)
+
+	resp, _ = do(http.MethodDelete, "/api/v2/metrics/gauge/Alloc", "")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, _ = do(http.MethodDelete, "/api/v2/metrics/gauge/Alloc", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/value/counter/Hits", nil)
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "text/plain")
+	legacy, err := ts.Client().Do(req)
+	require.NoError(t, err)
+	defer legacy.Body.Close()
+	data, _ := io.ReadAll(legacy.Body)
+	assert.Equal(t, http.StatusOK, legacy.StatusCode)
+	assert.Equal(t, "6", string(data), "legacy routes share the same storage")
+}
+
+func TestMetric_V2Problems(t *testing.T) {
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "agent", Hash: auth.HashToken("write-token"), Scopes: []string{auth.ScopeWrite}},
+		{Name: "dashboard", Hash: auth.HashToken("read-token"), Scopes: []string{auth.ScopeRead}},
+	})
+	require.NoError(t, err)
+
+	h := newTestHandler()
+	h.Auth = authenticator
+	h.MaxBodySize = 64
+	h.Limiter = service.NewRateLimiter(0.001, 100)
+
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		contentType string
+		body        string
+		want        int
+		problem     string
+	}{
+		{name: "no token", method: http.MethodGet, path: "/api/v2/metrics", want: http.StatusUnauthorized, problem: models.ProblemUnauthorized},
+		{name: "wrong scope", method: http.MethodPut, path: "/api/v2/metrics/gauge/Alloc", token: "read-token", contentType: "application/json", body: `{"value":1}`, want: http.StatusForbidden, problem: models.ProblemForbidden},
+		{name: "unknown route", method: http.MethodGet, path: "/api/v2/nope", token: "read-token", want: http.StatusNotFound, problem: models.ProblemRouteNotFound},
+		{name: "wrong method", method: http.MethodPatch, path: "/api/v2/metrics/gauge/Alloc", token: "write-token", want: http.StatusMethodNotAllowed, problem: models.ProblemMethodNotAllowed},
+		{name: "body too large", method: http.MethodPut, path: "/api/v2/metrics/gauge/Alloc", token: "write-token", contentType: "application/json", body: `{"value":1,"pad":"` + strings.Repeat("x", 64) + `"}`, want: http.StatusRequestEntityTooLarge, problem: models.ProblemBodyTooLarge},
+		{name: "wrong content type", method: http.MethodPut, path: "/api/v2/metrics/gauge/Alloc", token: "write-token", contentType: "text/plain", body: "1", want: http.StatusUnsupportedMediaType, problem: models.ProblemUnsupportedMediaType},
+	}
+
+	do := func(method, path, token, contentType, body string) (*http.Response, models.Problem) {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var problem models.Problem
+		_ = json.NewDecoder(resp.Body).Decode(&problem)
+		return resp, problem
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, problem := do(tt.method, tt.path, tt.token, tt.contentType, tt.body)
+
+			assert.Equal(t, tt.want, resp.StatusCode)
+			assert.Equal(t, models.ProblemContentType, resp.Header.Get("Content-Type"))
+			assert.Equal(t, tt.problem, problem.Type)
+			assert.Equal(t, tt.want, problem.Status)
+			assert.Equal(t, tt.path, problem.Instance)
+		})
+	}
+
+	resp, _ := do(http.MethodPatch, "/api/v2/metrics/gauge/Alloc", "write-token", "", "")
+	assert.ElementsMatch(t, []string{http.MethodGet, http.MethodPut, http.MethodDelete}, resp.Header.Values("Allow"))
+
+	h.Limiter = service.NewRateLimiter(0.001, 1)
+	do(http.MethodGet, "/api/v2/metrics", "read-token", "", "")
+	resp, problem := do(http.MethodGet, "/api/v2/metrics", "read-token", "", "")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, models.ProblemRateLimited, problem.Type)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	h.Limiter = nil
+
+	// Старые маршруты по-прежнему отвечают text/plain.
+	resp, _ = do(http.MethodGet, "/value/gauge/Alloc", "", "", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/plain")
+}
+
+func TestMetric_Dashboard(t *testing.T) {
+	ms := newTestStorage()
+	ms.SetHistory(service.NewHistory(10))
+	h := handler.NewHandler(ms, zap.NewNop().Sugar(), nil, audit.NewPublisher(), false, "", nil, nil)
+
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	require.NoError(t, ms.UpdateGauge("Alloc", 1))
+	require.NoError(t, ms.UpdateGauge("Alloc", 2))
+
+	resp, err := ts.Client().Get(ts.URL + "/")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	assert.Contains(t, string(body), "api/v2/history")
+	assert.NotContains(t, string(body), "https://", "dashboard must not load external resources")
+
+	resp, err = ts.Client().Get(ts.URL + "/api/v2/history")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var got struct {
+		Data []models.Series `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&got))
+	require.Len(t, got.Data, 1)
+	assert.Equal(t, "Alloc", got.Data[0].ID)
+	require.Len(t, got.Data[0].History, 2)
+	assert.Equal(t, 2.0, got.Data[0].History[1].V)
+}
+
+func TestMetric_Bulk(t *testing.T) {
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "ops", Hash: auth.HashToken("admin-token"), Scopes: []string{auth.ScopeAdmin}},
+		{Name: "agent", Hash: auth.HashToken("write-token"), Scopes: []string{auth.ScopeWrite}},
+	})
+	require.NoError(t, err)
+
+	recorder := &auditRecorder{}
+	h := handler.NewHandler(newTestStorage(), zap.NewNop().Sugar(), nil, audit.NewPublisher(recorder), false, "", nil, nil)
+	h.Auth = authenticator
+
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	do := func(method, path, token, contentType, body string) (*http.Response, string) {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, _ := do(http.MethodPost, "/api/v2/import", "write-token", "text/csv", "Alloc,gauge,1\n")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "import requires admin")
+	resp, _ = do(http.MethodGet, "/api/v2/export", "write-token", "", "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "export requires admin")
+
+	resp, _ = do(http.MethodPost, "/api/v2/import", "admin-token", "application/json", "[]")
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+
+	csvBody := "id,type,value\nAlloc,gauge,1.5\nHits,counter,2\nBad,counter,x\nShort,gauge\nHits,counter,3\n"
+	resp, body := do(http.MethodPost, "/api/v2/import", "admin-token", "text/csv", csvBody)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+	var events []handler.ImportEvent
+	dec := json.NewDecoder(strings.NewReader(body))
+	for dec.More() {
+		var ev handler.ImportEvent
+		require.NoError(t, dec.Decode(&ev))
+		events = append(events, ev)
+	}
+	require.NotEmpty(t, events)
+	summary := events[len(events)-1]
+	assert.Equal(t, "summary", summary.Event)
+	assert.Equal(t, 2, summary.Failed, body)
+	assert.Equal(t, 3, summary.Applied, body)
+	assert.Contains(t, body, `"event":"error","line":4`)
+	assert.Contains(t, body, `"event":"error","line":5`)
+	assert.Contains(t, body, `"event":"progress"`)
+
+	ndjsonBody := `{"id":"Hits","type":"counter","delta":5}` + "\n\n" + `{"id":"Free","type":"gauge","value":3}` + "\n" + `{"id":"NoValue","type":"gauge"}`
+	resp, body = do(http.MethodPost, "/api/v2/import", "admin-token", "application/x-ndjson", ndjsonBody)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"id":"NoValue"`)
+	assert.Contains(t, body, `"event":"summary","processed":3,"applied":2,"failed":1`)
+
+	resp, body = do(http.MethodGet, "/api/v2/export?format=csv", "admin-token", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+	assert.Contains(t, resp.Header.Get("Content-Disposition"), "metrics.csv")
+	assert.Equal(t, "id,type,value\nAlloc,gauge,1.5\nFree,gauge,3\nHits,counter,10\n", body)
+
+	resp, body = do(http.MethodGet, "/api/v2/export", "admin-token", "", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"id":"Alloc","type":"gauge","value":1.5}`+"\n"+`{"id":"Free","type":"gauge","value":3}`+"\n"+`{"id":"Hits","type":"counter","delta":10}`+"\n", body)
+
+	resp, _ = do(http.MethodGet, "/api/v2/export?format=xml", "admin-token", "", "")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	var actions []string
+	for _, ev := range recorder.events {
+		actions = append(actions, ev.Action)
+		assert.Equal(t, "ops", ev.Identity)
+	}
+	assert.Equal(t, []string{audit.ActionImport, audit.ActionImport, audit.ActionExport, audit.ActionExport}, actions)
+}
+
+func TestMetric_Health(t *testing.T) {
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "agent", Hash: auth.HashToken("write-token"), Scopes: []string{auth.ScopeWrite}},
+	})
+	require.NoError(t, err)
+
+	h := newTestHandler()
+	h.Auth = authenticator
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	get := func(path string) (int, models.HealthReport) {
+		resp, err := ts.Client().Get(ts.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var report models.HealthReport
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		return resp.StatusCode, report
+	}
+
+	code, report := get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.HealthOK, report.Status)
+
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusOK, code, "no checks means ready")
+	assert.Equal(t, models.HealthOK, report.Status)
+
+	h.Health = service.NewHealth()
+	h.Health.Set("storage", nil)
+	h.Health.Set("grpc", service.ErrNotReady)
+
+	code, report = get("/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, models.HealthFail, report.Status)
+	assert.Equal(t, models.HealthCheck{Status: models.HealthOK}, report.Checks["storage"])
+	assert.Equal(t, models.HealthCheck{Status: models.HealthFail, Error: service.ErrNotReady.Error()}, report.Checks["grpc"])
+
+	code, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, code, "liveness does not depend on readiness")
+
+	// Пробы kubelet приходят с адресов узлов вне доверенной подсети.
+	subnet, err := service.NewTrustedSubnet("10.0.0.0/8", "")
+	require.NoError(t, err)
+	h.TrustedSubnet = subnet
+	h.Health.Set("grpc", nil)
+
+	code, _ = get("/healthz")
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = get("/readyz")
+	assert.Equal(t, http.StatusOK, code)
+
+	resp, err := ts.Client().Get(ts.URL + "/ping")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "other routes stay behind the trusted subnet")
+}
+
+func TestMetric_ConditionalGet(t *testing.T) {
+	ms := newTestStorage()
+	h := handler.NewHandler(ms, zap.NewNop().Sugar(), nil, audit.NewPublisher(), false, "", nil, nil)
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	require.NoError(t, ms.UpdateGauge("Alloc", 1))
+	require.NoError(t, ms.UpdateCounter("Hits", 1))
+
+	get := func(path string, header ...string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "text/plain")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	for _, path := range []string{"/value/gauge/Alloc", "/api/v2/metrics/gauge/Alloc", "/api/v2/metrics", "/api/v2/history"} {
+		t.Run(path, func(t *testing.T) {
+			resp, _ := get(path)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			tag := resp.Header.Get("ETag")
+			modified := resp.Header.Get("Last-Modified")
+			require.NotEmpty(t, tag)
+			require.NotEmpty(t, modified)
+
+			resp, body := get(path, "If-None-Match", tag)
+			assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+			assert.Empty(t, body)
+			assert.Equal(t, tag, resp.Header.Get("ETag"))
+
+			resp, _ = get(path, "If-Modified-Since", modified)
+			assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+			resp, _ = get(path, "If-None-Match", `W/"other"`, "If-Modified-Since", modified)
+			assert.Equal(t, http.StatusOK, resp.StatusCode, "If-None-Match takes precedence")
+
+			require.NoError(t, ms.UpdateGauge("Alloc", 2))
+			resp, _ = get(path, "If-None-Match", tag)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.NotEqual(t, tag, resp.Header.Get("ETag"))
+		})
+	}
+
+	resp, _ := get("/value/counter/Hits")
+	tag := resp.Header.Get("ETag")
+	require.NoError(t, ms.UpdateGauge("Alloc", 3))
+	resp, _ = get("/value/counter/Hits", "If-None-Match", tag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode, "other metrics do not change the counter version")
+
+	resp, _ = get("/api/v2/metrics")
+	tag = resp.Header.Get("ETag")
+	assert.True(t, ms.Delete(models.Counter, "Hits"))
+	resp, _ = get("/api/v2/metrics", "If-None-Match", tag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "delete changes the list")
+
+	resp, _ = get("/value/counter/Hits")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("ETag"))
+}
+
+func TestMetric_Query(t *testing.T) {
+	ms := newTestStorage()
+	h := handler.NewHandler(ms, zap.NewNop().Sugar(), nil, audit.NewPublisher(), false, "", nil, nil)
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	require.NoError(t, ms.UpdateGauge("CPUutilization1", 0.25))
+	require.NoError(t, ms.UpdateGauge("CPUutilization2", 0.5))
+
+	resp, err := ts.Client().Get(ts.URL + "/query?q=" + url.QueryEscape("sum(CPUutilization*)"))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"data":[{"labels":{},"value":0.75}]}`, string(body))
+
+	resp, err = ts.Client().Get(ts.URL + "/query?q=" + url.QueryEscape("sum(CPUutilization"))
+	require.NoError(t, err)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, models.ProblemContentType, resp.Header.Get("Content-Type"))
+	assert.Contains(t, string(body), models.ProblemInvalidQuery)
+}
+
+func TestMetric_Alerts(t *testing.T) {
+	ms := newTestStorage()
+	h := handler.NewHandler(ms, zap.NewNop().Sugar(), nil, audit.NewPublisher(), false, "", nil, nil)
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := ts.Client().Get(ts.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	code, body := get("/alerts")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"data":[]}`, body, "no rules configured")
+
+	engine, err := alert.NewEngine(ms, []alert.Rule{
+		{Name: "HighHeap", Expr: "HeapAlloc", Op: ">", Threshold: 100, Severity: "critical", Annotations: map[string]string{"summary": "heap is large"}},
+	}, zap.NewNop().Sugar())
+	require.NoError(t, err)
+	h.Alerts = engine
+
+	require.NoError(t, ms.UpdateGauge("HeapAlloc", 150))
+	require.NoError(t, engine.Evaluate(time.Now()))
+
+	code, body = get("/alerts?state=firing")
+	assert.Equal(t, http.StatusOK, code)
+
+	var got struct {
+		Data []models.Alert `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	require.Len(t, got.Data, 1)
+	assert.Equal(t, "HighHeap", got.Data[0].Rule)
+	assert.Equal(t, "critical", got.Data[0].Severity)
+	assert.Equal(t, 150.0, got.Data[0].Value)
+	assert.Equal(t, "heap is large", got.Data[0].Annotations["summary"])
+
+	code, body = get("/alerts?state=pending")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"data":[]}`, body)
+
+	code, body = get("/alerts?state=loud")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, models.ProblemInvalidFilter)
+}
+
+func TestMetric_Silences(t *testing.T) {
+	authenticator, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "ops", Hash: auth.HashToken("write-token"), Scopes: []string{auth.ScopeWrite, auth.ScopeRead}},
+		{Name: "viewer", Hash: auth.HashToken("read-token"), Scopes: []string{auth.ScopeRead}},
+	})
+	require.NoError(t, err)
+
+	ms := newTestStorage()
+	require.NoError(t, ms.UpdateGauge("HeapAlloc:web-1", 10))
+	require.NoError(t, ms.UpdateGauge("HeapAlloc:web-2", 20))
+
+	recorder := &auditRecorder{}
+	h := handler.NewHandler(ms, zap.NewNop().Sugar(), nil, audit.NewPublisher(recorder), false, "", nil, nil)
+	h.Auth = authenticator
+
+	ts := httptest.NewServer(Metric(h))
+	defer ts.Close()
+
+	do := func(method, path, token, body string, header ...string) (*http.Response, string) {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+token)
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+
+		resp, err := ts.Client().Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, body := do(http.MethodGet, "/api/v2/metrics", "read-token", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotContains(t, body, "silenced")
+	etag := resp.Header.Get("ETag")
+
+	create := `{"matchers": "HeapAlloc*{host=\"web-1\"}", "ends_at": "` + time.Now().Add(time.Hour).Format(time.RFC3339) + `", "comment": "deploy"}`
+	resp, _ = do(http.MethodPost, "/api/v2/silences", "read-token", create)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "creating silences requires write")
+
+	resp, body = do(http.MethodPost, "/api/v2/silences", "write-token", `{"matchers": "max(HeapAlloc)", "ends_at": "2999-01-01T00:00:00Z", "comment": "deploy"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, models.ProblemInvalidSilence)
+
+	resp, body = do(http.MethodPost, "/api/v2/silences", "write-token", create)
+	require.Equal(t, http.StatusCreated, resp.StatusCode, body)
+
+	var created struct {
+		Data models.Silence `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+	assert.NotEmpty(t, created.Data.ID)
+	assert.Equal(t, "ops", created.Data.CreatedBy, "author defaults to the token name")
+	assert.Equal(t, models.SilenceActive, created.Data.Status)
+
+	resp, body = do(http.MethodGet, "/api/v2/metrics", "read-token", "", "If-None-Match", etag)
+	require.Equal(t, http.StatusOK, resp.StatusCode, "silence changes the list ETag")
+	var list struct {
+		Data []models.Metrics `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &list))
+	require.Len(t, list.Data, 2)
+	assert.True(t, list.Data[0].Silenced, "HeapAlloc:web-1 is silenced")
+	assert.False(t, list.Data[1].Silenced, "HeapAlloc:web-2 is not silenced")
+
+	resp, body = do(http.MethodGet, "/api/v2/metrics/gauge/HeapAlloc:web-1", "read-token", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `"silenced":true`)
+
+	resp, _ = do(http.MethodGet, "/value/gauge/HeapAlloc:web-1", "read-token", "")
+	assert.Equal(t, created.Data.ID, resp.Header.Get(handler.SilencedHeader))
+	resp, _ = do(http.MethodGet, "/value/gauge/HeapAlloc:web-2", "read-token", "")
+	assert.Empty(t, resp.Header.Get(handler.SilencedHeader))
+
+	resp, body = do(http.MethodGet, "/api/v2/silences?state=active", "read-token", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, created.Data.ID)
+
+	resp, _ = do(http.MethodDelete, "/api/v2/silences/unknown", "write-token", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, body = do(http.MethodDelete, "/api/v2/silences/"+created.Data.ID, "write-token", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, models.SilenceExpired)
+
+	resp, body = do(http.MethodGet, "/api/v2/metrics", "read-token", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotContains(t, body, "silenced")
+	assert.Equal(t, etag, resp.Header.Get("ETag"), "without active silences the ETag is the metrics ETag")
+
+	var actions, silences []string
+	for _, e := range recorder.events {
+		actions = append(actions, e.Action)
+		silences = append(silences, e.Silence)
+		assert.Equal(t, "ops", e.Identity)
+		assert.Equal(t, []string{`HeapAlloc*{host="web-1"}`}, e.Metrics)
+	}
+	assert.Equal(t, []string{audit.ActionSilenceCreate, audit.ActionSilenceExpire}, actions)
+	assert.Equal(t, []string{created.Data.ID, created.Data.ID}, silences)
+}
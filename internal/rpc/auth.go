@@ -0,0 +1,114 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"metrify/internal/auth"
+	"metrify/internal/proto"
+)
+
+// AuthorizationMetadataKey — ключ метаданных с токеном вида "Bearer <token>".
+const AuthorizationMetadataKey = "authorization"
+
+// methodScopes — права, необходимые для вызова методов. Методы, которых
+// здесь нет, доступны только токенам с правом admin.
+var methodScopes = map[string]string{
+	proto.Metrics_UpdateMetrics_FullMethodName: auth.ScopeWrite,
+	proto.Metrics_WatchMetrics_FullMethodName:  auth.ScopeRead,
+	proto.Metrics_Query_FullMethodName:         auth.ScopeRead,
+	proto.Metrics_ListAlerts_FullMethodName:    auth.ScopeRead,
+}
+
+// NewAuthInterceptor проверяет токен унарных вызовов, аналог RequireScope в HTTP.
+func NewAuthInterceptor(a *auth.Authenticator) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, err := authenticate(ctx, a, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// NewAuthStreamInterceptor проверяет токен потоковых вызовов (WatchMetrics).
+func NewAuthStreamInterceptor(a *auth.Authenticator) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := authenticate(ss.Context(), a, info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// NewTokenClientInterceptor добавляет токен в метаданные каждого вызова.
+func NewTokenClientInterceptor(token string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, AuthorizationMetadataKey, "Bearer "+token)
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func authenticate(ctx context.Context, a *auth.Authenticator, fullMethod string) (context.Context, error) {
+	if !a.Enabled() || isHealthMethod(fullMethod) {
+		return ctx, nil
+	}
+
+	scope, ok := methodScopes[fullMethod]
+	if !ok {
+		scope = auth.ScopeAdmin
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	id, err := a.Authenticate(auth.BearerToken(firstValue(md, AuthorizationMetadataKey)), scope)
+	switch {
+	case errors.Is(err, auth.ErrForbidden):
+		return ctx, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return auth.WithIdentity(ctx, id), nil
+}
+
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// isHealthMethod — проверки grpc.health.v1 открыты, как /healthz и /readyz.
+func isHealthMethod(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+healthpb.Health_ServiceDesc.ServiceName+"/")
+}
@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"metrify/internal/auth"
+	"metrify/internal/proto"
+)
+
+func newTestAuthenticator(t *testing.T) *auth.Authenticator {
+	t.Helper()
+
+	a, err := auth.NewAuthenticatorFromTokens([]auth.Token{
+		{Name: "agent", Hash: auth.HashToken("write-token"), Scopes: []string{auth.ScopeWrite}},
+		{Name: "dashboard", Hash: auth.HashToken("read-token"), Scopes: []string{auth.ScopeRead}},
+	})
+	if err != nil {
+		t.Fatalf("NewAuthenticatorFromTokens() error = %v", err)
+	}
+
+	return a
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	interceptor := NewAuthInterceptor(newTestAuthenticator(t))
+	info := &grpc.UnaryServerInfo{FullMethod: proto.Metrics_UpdateMetrics_FullMethodName}
+
+	tests := []struct {
+		name     string
+		md       metadata.MD
+		method   string
+		code     codes.Code
+		identity string
+	}{
+		{name: "no token", md: metadata.MD{}, code: codes.Unauthenticated},
+		{name: "unknown token", md: metadata.Pairs(AuthorizationMetadataKey, "Bearer nope"), code: codes.Unauthenticated},
+		{name: "read token", md: metadata.Pairs(AuthorizationMetadataKey, "Bearer read-token"), code: codes.PermissionDenied},
+		{name: "write token", md: metadata.Pairs(AuthorizationMetadataKey, "Bearer write-token"), code: codes.OK, identity: "agent"},
+		{name: "unknown method needs admin", md: metadata.Pairs(AuthorizationMetadataKey, "Bearer write-token"), method: "/metrics.Metrics/Drop", code: codes.PermissionDenied},
+		{name: "health check is open", md: metadata.MD{}, method: healthpb.Health_Check_FullMethodName, code: codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := info
+			if tt.method != "" {
+				info = &grpc.UnaryServerInfo{FullMethod: tt.method}
+			}
+
+			var identity string
+			_, err := interceptor(metadata.NewIncomingContext(context.Background(), tt.md), newGaugeRequest("Alloc", 1), info,
+				func(ctx context.Context, req interface{}) (interface{}, error) {
+					if id, ok := auth.FromContext(ctx); ok {
+						identity = id.Name
+					}
+					return &proto.UpdateMetricsResponse{}, nil
+				})
+
+			if status.Code(err) != tt.code {
+				t.Fatalf("got %v, want %v", status.Code(err), tt.code)
+			}
+			if identity != tt.identity {
+				t.Fatalf("identity = %q, want %q", identity, tt.identity)
+			}
+		})
+	}
+
+	disabled := NewAuthInterceptor(nil)
+	if _, err := disabled(context.Background(), newGaugeRequest("Alloc", 1), info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &proto.UpdateMetricsResponse{}, nil
+	}); err != nil {
+		t.Fatalf("expected disabled auth to allow request, got %v", err)
+	}
+}
+
+func TestAuthStreamInterceptor_WatchMetrics(t *testing.T) {
+	a := newTestAuthenticator(t)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainStreamInterceptor(NewAuthStreamInterceptor(a)))
+	proto.RegisterMetricsServer(srv, NewMetricsService(newStorageMock()))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	dial := func() proto.MetricsClient {
+		conn, err := grpc.NewClient(
+			"passthrough:///bufnet",
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+
+		return proto.NewMetricsClient(conn)
+	}
+
+	watch := func(token string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		ctx = metadata.AppendToOutgoingContext(ctx, AuthorizationMetadataKey, "Bearer "+token)
+		stream, err := dial().WatchMetrics(ctx, &proto.WatchMetricsRequest{})
+		if err != nil {
+			return err
+		}
+
+		_, err = stream.Recv()
+		return err
+	}
+
+	if err := watch("write-token"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for write token, got %v", err)
+	}
+	// С правом чтения поток открывается и ждёт обновлений до таймаута.
+	if err := watch("read-token"); status.Code(err) != codes.DeadlineExceeded {
+		t.Fatalf("expected DeadlineExceeded for read token, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"metrify/internal/proto"
+	"metrify/internal/service"
+)
+
+// DefaultHealthInterval — период пересчёта статуса grpc.health.v1.
+const DefaultHealthInterval = 5 * time.Second
+
+// WatchHealth раз в interval переносит результат проверок h в статус hs
+// для всего сервера и для сервиса Metrics. При отмене ctx все сервисы
+// переводятся в NOT_SERVING, чтобы балансировщик успел снять трафик.
+func WatchHealth(ctx context.Context, hs *health.Server, h *service.Health, interval time.Duration) {
+	update := func() {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		if h.Ready(checkCtx) {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+		cancel()
+
+		hs.SetServingStatus("", status)
+		hs.SetServingStatus(proto.Metrics_ServiceDesc.ServiceName, status)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		update()
+
+		select {
+		case <-ctx.Done():
+			hs.Shutdown()
+			return
+		case <-ticker.C:
+		}
+	}
+}
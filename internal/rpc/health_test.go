@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"metrify/internal/proto"
+	"metrify/internal/service"
+)
+
+func TestWatchHealth(t *testing.T) {
+	h := service.NewHealth()
+	h.Set("storage", service.ErrNotReady)
+	hs := health.NewServer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		WatchHealth(ctx, hs, h, 10*time.Millisecond)
+	}()
+
+	waitStatus := func(want healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+
+		deadline := time.Now().Add(time.Second)
+		for {
+			resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{Service: proto.Metrics_ServiceDesc.ServiceName})
+			if err == nil && resp.GetStatus() == want {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("status = %v (err %v), want %v", resp.GetStatus(), err, want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	waitStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+
+	h.Set("storage", nil)
+	waitStatus(healthpb.HealthCheckResponse_SERVING)
+
+	cancel()
+	<-done
+
+	resp, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("status after shutdown = %v, want NOT_SERVING", resp.GetStatus())
+	}
+}
@@ -2,27 +2,22 @@ package rpc
 
 import (
 	"context"
-	"fmt"
-	"net"
+	"errors"
+	"strings"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
-)
-
-func NewTrustedSubnetInterceptor(trustedSubnet string) (grpc.UnaryServerInterceptor, error) {
-	var ipNet *net.IPNet
 
-	if trustedSubnet != "" {
-		_, parsedNet, err := net.ParseCIDR(trustedSubnet)
-		if err != nil {
-			return nil, fmt.Errorf("invalid trusted subnet %q: %w", trustedSubnet, err)
-		}
-		ipNet = parsedNet
-	}
+	"metrify/internal/service"
+)
 
-	interceptor := func(
+// NewTrustedSubnetInterceptor пропускает унарные вызовы только из доверенных подсетей.
+// nil или выключенный subnet пропускает все вызовы.
+func NewTrustedSubnetInterceptor(subnet *service.TrustedSubnet) grpc.UnaryServerInterceptor {
+	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
@@ -30,31 +25,55 @@ func NewTrustedSubnetInterceptor(trustedSubnet string) (grpc.UnaryServerIntercep
 	) (interface{}, error) {
 		_ = info
 
-		if ipNet == nil {
-			return handler(ctx, req)
+		if err := checkSubnet(ctx, subnet); err != nil {
+			return nil, err
 		}
 
-		md, ok := metadata.FromIncomingContext(ctx)
-		if !ok {
-			return nil, status.Error(codes.PermissionDenied, "metadata is missing")
-		}
+		return handler(ctx, req)
+	}
+}
 
-		values := md.Get("x-real-ip")
-		if len(values) == 0 || values[0] == "" {
-			return nil, status.Error(codes.PermissionDenied, "x-real-ip metadata is missing")
+// NewTrustedSubnetStreamInterceptor — то же для потоковых вызовов (WatchMetrics).
+func NewTrustedSubnetStreamInterceptor(subnet *service.TrustedSubnet) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := checkSubnet(ss.Context(), subnet); err != nil {
+			return err
 		}
 
-		ip := net.ParseIP(values[0])
-		if ip == nil {
-			return nil, status.Error(codes.PermissionDenied, "invalid x-real-ip")
-		}
+		return handler(srv, ss)
+	}
+}
 
-		if !ipNet.Contains(ip) {
-			return nil, status.Error(codes.PermissionDenied, "agent ip is not in trusted subnet")
-		}
+// checkSubnet определяет адрес клиента так же, как WithTrustedSubnet в HTTP:
+// x-real-ip и x-forwarded-for из метаданных, адрес соединения из peer.
+func checkSubnet(ctx context.Context, subnet *service.TrustedSubnet) error {
+	if !subnet.Enabled() {
+		return nil
+	}
 
-		return handler(ctx, req)
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
 	}
 
-	return interceptor, nil
+	md, ok := metadata.FromIncomingContext(ctx)
+
+	err := subnet.Check(remote, firstValue(md, "x-real-ip"), strings.Join(md.Get("x-forwarded-for"), ","))
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, service.ErrNoClientIP) && !ok:
+		return status.Error(codes.PermissionDenied, "metadata is missing")
+	case errors.Is(err, service.ErrNoClientIP):
+		return status.Error(codes.PermissionDenied, "x-real-ip metadata is missing")
+	case errors.Is(err, service.ErrInvalidClientIP):
+		return status.Error(codes.PermissionDenied, "invalid x-real-ip")
+	default:
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
 }
@@ -2,26 +2,35 @@ package rpc
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+
+	"metrify/internal/service"
 )
 
-func TestNewTrustedSubnetInterceptor_InvalidCIDR(t *testing.T) {
-	_, err := NewTrustedSubnetInterceptor("not-a-cidr")
-	if err == nil {
-		t.Fatal("expected error for invalid cidr")
+func newTestSubnet(t *testing.T, subnets, proxies string) *service.TrustedSubnet {
+	t.Helper()
+
+	subnet, err := service.NewTrustedSubnet(subnets, proxies)
+	if err != nil {
+		t.Fatalf("NewTrustedSubnet() error = %v", err)
 	}
+
+	return subnet
+}
+
+func peerContext(ip string) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 4000}})
 }
 
 func TestTrustedSubnetInterceptor_EmptySubnet_AllowsRequest(t *testing.T) {
-	interceptor, err := NewTrustedSubnetInterceptor("")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
+	interceptor := NewTrustedSubnetInterceptor(newTestSubnet(t, "", ""))
 
 	called := false
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
@@ -47,17 +56,14 @@ func TestTrustedSubnetInterceptor_EmptySubnet_AllowsRequest(t *testing.T) {
 }
 
 func TestTrustedSubnetInterceptor_MetadataMissing(t *testing.T) {
-	interceptor, err := NewTrustedSubnetInterceptor("192.168.1.0/24")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
+	interceptor := NewTrustedSubnetInterceptor(newTestSubnet(t, "192.168.1.0/24", ""))
 
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
 		t.Fatal("handler must not be called")
 		return nil, nil
 	}
 
-	_, err = interceptor(
+	_, err := interceptor(
 		context.Background(),
 		"req",
 		&grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"},
@@ -80,10 +86,7 @@ func TestTrustedSubnetInterceptor_MetadataMissing(t *testing.T) {
 }
 
 func TestTrustedSubnetInterceptor_RealIPMissing(t *testing.T) {
-	interceptor, err := NewTrustedSubnetInterceptor("192.168.1.0/24")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
+	interceptor := NewTrustedSubnetInterceptor(newTestSubnet(t, "192.168.1.0/24", ""))
 
 	ctx := metadata.NewIncomingContext(context.Background(), metadata.MD{})
 
@@ -92,7 +95,7 @@ func TestTrustedSubnetInterceptor_RealIPMissing(t *testing.T) {
 		return nil, nil
 	}
 
-	_, err = interceptor(
+	_, err := interceptor(
 		ctx,
 		"req",
 		&grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"},
@@ -115,13 +118,10 @@ func TestTrustedSubnetInterceptor_RealIPMissing(t *testing.T) {
 }
 
 func TestTrustedSubnetInterceptor_InvalidIP(t *testing.T) {
-	interceptor, err := NewTrustedSubnetInterceptor("192.168.1.0/24")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
+	interceptor := NewTrustedSubnetInterceptor(newTestSubnet(t, "192.168.1.0/24", "10.0.0.1"))
 
 	ctx := metadata.NewIncomingContext(
-		context.Background(),
+		peerContext("10.0.0.1"),
 		metadata.Pairs("x-real-ip", "not-an-ip"),
 	)
 
@@ -130,7 +130,7 @@ func TestTrustedSubnetInterceptor_InvalidIP(t *testing.T) {
 		return nil, nil
 	}
 
-	_, err = interceptor(
+	_, err := interceptor(
 		ctx,
 		"req",
 		&grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"},
@@ -152,15 +152,13 @@ func TestTrustedSubnetInterceptor_InvalidIP(t *testing.T) {
 	}
 }
 
+// Без доверенных прокси X-Real-IP не подменяет адрес соединения.
 func TestTrustedSubnetInterceptor_IPNotInSubnet(t *testing.T) {
-	interceptor, err := NewTrustedSubnetInterceptor("192.168.1.0/24")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
+	interceptor := NewTrustedSubnetInterceptor(newTestSubnet(t, "192.168.1.0/24", ""))
 
 	ctx := metadata.NewIncomingContext(
-		context.Background(),
-		metadata.Pairs("x-real-ip", "10.0.0.1"),
+		peerContext("10.0.0.1"),
+		metadata.Pairs("x-real-ip", "192.168.1.42"),
 	)
 
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
@@ -168,7 +166,7 @@ func TestTrustedSubnetInterceptor_IPNotInSubnet(t *testing.T) {
 		return nil, nil
 	}
 
-	_, err = interceptor(
+	_, err := interceptor(
 		ctx,
 		"req",
 		&grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"},
@@ -191,13 +189,10 @@ func TestTrustedSubnetInterceptor_IPNotInSubnet(t *testing.T) {
 }
 
 func TestTrustedSubnetInterceptor_IPInSubnet_AllowsRequest(t *testing.T) {
-	interceptor, err := NewTrustedSubnetInterceptor("192.168.1.0/24")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
+	interceptor := NewTrustedSubnetInterceptor(newTestSubnet(t, "192.168.1.0/24", ""))
 
 	ctx := metadata.NewIncomingContext(
-		context.Background(),
+		peerContext("192.168.1.42"),
 		metadata.Pairs("x-real-ip", "192.168.1.42"),
 	)
 
@@ -223,3 +218,41 @@ func TestTrustedSubnetInterceptor_IPInSubnet_AllowsRequest(t *testing.T) {
 		t.Fatalf("unexpected response: %v", resp)
 	}
 }
+
+func TestTrustedSubnetInterceptor_TrustedProxy(t *testing.T) {
+	interceptor := NewTrustedSubnetInterceptor(newTestSubnet(t, "192.168.1.0/24", "10.0.0.1"))
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "ok", nil
+	}
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+
+	call := func(peerIP string, md metadata.MD) error {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(peerIP), Port: 4000}})
+		_, err := interceptor(metadata.NewIncomingContext(ctx, md), "req", info, handler)
+		return err
+	}
+
+	if err := call("10.0.0.1", metadata.Pairs("x-forwarded-for", "192.168.1.42")); err != nil {
+		t.Fatalf("expected request forwarded by trusted proxy to pass, got %v", err)
+	}
+	if err := call("10.0.0.2", metadata.Pairs("x-real-ip", "192.168.1.42")); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected x-real-ip from untrusted peer to be ignored, got %v", err)
+	}
+	if err := call("192.168.1.10", metadata.MD{}); err != nil {
+		t.Fatalf("expected direct connection from subnet to pass, got %v", err)
+	}
+}
+
+func TestTrustedSubnetStreamInterceptor(t *testing.T) {
+	interceptor := NewTrustedSubnetStreamInterceptor(newTestSubnet(t, "192.168.1.0/24", ""))
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-real-ip", "10.0.0.1"))
+	err := interceptor(nil, &identityStream{ctx: ctx}, &grpc.StreamServerInfo{}, func(srv interface{}, ss grpc.ServerStream) error {
+		t.Fatal("handler must not be called")
+		return nil
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied, got %v", err)
+	}
+}
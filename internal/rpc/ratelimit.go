@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"metrify/internal/auth"
+	"metrify/internal/service"
+)
+
+// RetryAfterMetadataKey — трейлер с числом секунд до следующей попытки, аналог Retry-After.
+const RetryAfterMetadataKey = "retry-after"
+
+// NewPeerRateLimitInterceptor ограничивает частоту унарных вызовов по адресу
+// клиента (subnet.PeerIP), как WithPeerRateLimit в HTTP. Ставится перед
+// NewAuthInterceptor, чтобы перебор токенов тоже упирался в лимит.
+func NewPeerRateLimitInterceptor(limiter *service.RateLimiter, subnet *service.TrustedSubnet) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := allow(ctx, limiter, peerKey(ctx, subnet)); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// NewPeerRateLimitStreamInterceptor ограничивает частоту открытия потоков по адресу клиента.
+func NewPeerRateLimitStreamInterceptor(limiter *service.RateLimiter, subnet *service.TrustedSubnet) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := allow(ss.Context(), limiter, peerKey(ss.Context(), subnet)); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+// NewRateLimitInterceptor ограничивает частоту унарных вызовов токена, как
+// WithRateLimit в HTTP. Ставится после NewAuthInterceptor; вызовы без токена
+// ограничивает только NewPeerRateLimitInterceptor.
+func NewRateLimitInterceptor(limiter *service.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if id, ok := auth.FromContext(ctx); ok {
+			if err := allow(ctx, limiter, "token:"+id.Name); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// NewRateLimitStreamInterceptor ограничивает частоту открытия потоков токена.
+func NewRateLimitStreamInterceptor(limiter *service.RateLimiter) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if id, ok := auth.FromContext(ss.Context()); ok {
+			if err := allow(ss.Context(), limiter, "token:"+id.Name); err != nil {
+				return err
+			}
+		}
+
+		return handler(srv, ss)
+	}
+}
+
+func allow(ctx context.Context, limiter *service.RateLimiter, key string) error {
+	if limiter == nil {
+		return nil
+	}
+
+	wait, ok := limiter.Allow(key)
+	if ok {
+		return nil
+	}
+
+	retryAfter := strconv.Itoa(service.RetryAfterSeconds(wait))
+	_ = grpc.SetTrailer(ctx, metadata.Pairs(RetryAfterMetadataKey, retryAfter))
+
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %ss", retryAfter)
+}
+
+func peerKey(ctx context.Context, subnet *service.TrustedSubnet) string {
+	var remote string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remote = p.Addr.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	return "ip:" + subnet.PeerIP(remote, firstValue(md, "x-real-ip"), strings.Join(md.Get("x-forwarded-for"), ","))
+}
@@ -0,0 +1,91 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"metrify/internal/auth"
+	"metrify/internal/proto"
+	"metrify/internal/service"
+)
+
+func TestPeerRateLimitInterceptor(t *testing.T) {
+	interceptor := NewPeerRateLimitInterceptor(service.NewRateLimiter(0.001, 1), nil)
+	info := &grpc.UnaryServerInfo{FullMethod: proto.Metrics_UpdateMetrics_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &proto.UpdateMetricsResponse{}, nil
+	}
+
+	fromPeer := func(addr, realIP string) context.Context {
+		ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 5000}})
+		return metadata.NewIncomingContext(ctx, metadata.Pairs("x-real-ip", realIP))
+	}
+	agentA := fromPeer("10.0.0.1", "10.0.0.1")
+	agentB := fromPeer("10.0.0.2", "10.0.0.2")
+
+	if _, err := interceptor(agentA, newGaugeRequest("Alloc", 1), info, handler); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	if _, err := interceptor(agentA, newGaugeRequest("Alloc", 1), info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	// Подмена x-real-ip без доверенного прокси не даёт нового лимита.
+	if _, err := interceptor(fromPeer("10.0.0.1", "10.9.9.9"), newGaugeRequest("Alloc", 1), info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("spoofed x-real-ip: expected ResourceExhausted, got %v", err)
+	}
+	// Лимит по адресу действует и с токеном: он стоит до проверки токена.
+	withToken := auth.WithIdentity(agentA, &auth.Identity{Name: "agent"})
+	if _, err := interceptor(withToken, newGaugeRequest("Alloc", 1), info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("token client: expected ResourceExhausted, got %v", err)
+	}
+	if _, err := interceptor(agentB, newGaugeRequest("Alloc", 1), info, handler); err != nil {
+		t.Fatalf("other client: %v", err)
+	}
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	interceptor := NewRateLimitInterceptor(service.NewRateLimiter(0.001, 1))
+	info := &grpc.UnaryServerInfo{FullMethod: proto.Metrics_UpdateMetrics_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return &proto.UpdateMetricsResponse{}, nil
+	}
+
+	// Без токена лимит задаёт только NewPeerRateLimitInterceptor.
+	for i := 0; i < 3; i++ {
+		if _, err := interceptor(peerContext("10.0.0.1"), newGaugeRequest("Alloc", 1), info, handler); err != nil {
+			t.Fatalf("anonymous call: %v", err)
+		}
+	}
+
+	agent := &auth.Identity{Name: "agent"}
+	if _, err := interceptor(auth.WithIdentity(peerContext("10.0.0.1"), agent), newGaugeRequest("Alloc", 1), info, handler); err != nil {
+		t.Fatalf("first token call: %v", err)
+	}
+	// Смена адреса не обходит лимит токена.
+	if _, err := interceptor(auth.WithIdentity(peerContext("10.0.0.2"), agent), newGaugeRequest("Alloc", 1), info, handler); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("same token from other address: expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestMetricsService_UpdateMetrics_BatchLimit(t *testing.T) {
+	st := newStorageMock()
+	svc := NewMetricsService(st)
+	svc.MaxBatchSize = 1
+
+	req := newGaugeRequest("Alloc", 1)
+	req.SetMetrics(append(req.GetMetrics(), newGaugeRequest("Frees", 2).GetMetrics()...))
+
+	if _, err := svc.UpdateMetrics(context.Background(), req); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+	if len(st.gauges) != 0 {
+		t.Fatalf("oversized batch must not be applied, got %v", st.gauges)
+	}
+}
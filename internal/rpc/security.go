@@ -0,0 +1,454 @@
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	protobuf "google.golang.org/protobuf/proto"
+
+	"metrify/internal/service"
+)
+
+// HashMetadataKey — ключ метаданных с HMAC-SHA256 подписью запроса,
+// аналог HTTP-заголовка HashSHA256. Остальные ключи соответствуют
+// заголовкам X-Timestamp, X-Nonce и X-Key-ID.
+const (
+	HashMetadataKey      = "hashsha256"
+	TimestampMetadataKey = "x-timestamp"
+	NonceMetadataKey     = "x-nonce"
+	KeyIDMetadataKey     = "x-key-id"
+)
+
+// SignMessage подписывает детерминированную сериализацию сообщения,
+// чтобы клиент и сервер получали одинаковые байты для одного и того же запроса.
+func SignMessage(msg any, key string) (string, error) {
+	data, err := marshalDeterministic(msg)
+	if err != nil {
+		return "", err
+	}
+
+	return service.SignData(data, key), nil
+}
+
+// SignCall подписывает сообщение вместе с именем метода, временем и nonce.
+// gRPC-вызов — это POST на /пакет.Сервис/Метод, поэтому подпись совпадает по формату с HTTP.
+func SignCall(fullMethod, timestamp, nonce string, msg any, key string) (string, error) {
+	data, err := marshalDeterministic(msg)
+	if err != nil {
+		return "", err
+	}
+
+	return service.SignRequest(http.MethodPost, fullMethod, timestamp, nonce, data, key), nil
+}
+
+// SignReply подписывает ответ сервера вместе с nonce запроса, как SignResponse в HTTP.
+func SignReply(nonce string, msg any, key string) (string, error) {
+	data, err := marshalDeterministic(msg)
+	if err != nil {
+		return "", err
+	}
+
+	return service.SignResponse(int(codes.OK), nonce, data, key), nil
+}
+
+func marshalDeterministic(msg any) ([]byte, error) {
+	m, ok := msg.(protobuf.Message)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %T", msg)
+	}
+
+	return protobuf.MarshalOptions{Deterministic: true}.Marshal(m)
+}
+
+// NewHashInterceptor проверяет подпись запроса ключом из keys, а через replay —
+// время и nonce запроса. Как и WithHashedRequest, запросы без подписи пропускаются
+// для совместимости со старыми агентами, а в строгом режиме отклоняются.
+func NewHashInterceptor(keys *service.KeyRing, replay *service.ReplayGuard) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if _, err := verifyCall(md, info.FullMethod, req, keys, replay); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// NewHashStreamInterceptor — то же для потоков (WatchMetrics): подпись запроса
+// проверяется при чтении первого сообщения. Заголовок ответа подписывается
+// SignReply от nonce и запроса, так клиент убеждается, что поток открыл сервер
+// с тем же ключом. Сообщения потока по отдельности не подписываются: их
+// целостность обеспечивает TLS.
+func NewHashStreamInterceptor(keys *service.KeyRing, replay *service.ReplayGuard) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if !keys.Enabled() {
+			return handler(srv, ss)
+		}
+
+		return handler(srv, &hashStream{ServerStream: ss, fullMethod: info.FullMethod, keys: keys, replay: replay})
+	}
+}
+
+type hashStream struct {
+	grpc.ServerStream
+	fullMethod string
+	keys       *service.KeyRing
+	replay     *service.ReplayGuard
+	checked    bool
+}
+
+func (s *hashStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil || s.checked {
+		return err
+	}
+	s.checked = true
+
+	md, _ := metadata.FromIncomingContext(s.Context())
+	key, err := verifyCall(md, s.fullMethod, m, s.keys, s.replay)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		key, _ = s.keys.Lookup("")
+	}
+	if key == "" {
+		return nil
+	}
+
+	sign, err := SignReply(firstValue(md, NonceMetadataKey), m, key)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return s.SetHeader(metadata.Pairs(HashMetadataKey, sign))
+}
+
+// verifyCall проверяет подпись сообщения req из метаданных md и возвращает ключ,
+// которым оно подписано, или пустую строку для пропущенного неподписанного запроса.
+// Ошибка — уже статус gRPC.
+func verifyCall(md metadata.MD, fullMethod string, req any, keys *service.KeyRing, replay *service.ReplayGuard) (string, error) {
+	if !keys.Enabled() {
+		return "", nil
+	}
+
+	hash := firstValue(md, HashMetadataKey)
+	if hash == "" {
+		if replay.Strict() {
+			return "", status.Error(codes.Unauthenticated, service.ErrUnsignedRequest.Error())
+		}
+		return "", nil
+	}
+
+	key, err := keys.Lookup(firstValue(md, KeyIDMetadataKey))
+	if err != nil {
+		return "", status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	timestamp := firstValue(md, TimestampMetadataKey)
+	nonce := firstValue(md, NonceMetadataKey)
+
+	var sign string
+	if timestamp == "" && nonce == "" {
+		if replay.Strict() {
+			return "", status.Error(codes.Unauthenticated, service.ErrUnsignedTimestamp.Error())
+		}
+		sign, err = SignMessage(req, key)
+	} else {
+		sign, err = SignCall(fullMethod, timestamp, nonce, req, key)
+	}
+	if err != nil {
+		return "", status.Error(codes.Internal, err.Error())
+	}
+
+	if !hmac.Equal([]byte(sign), []byte(hash)) {
+		return "", status.Error(codes.Unauthenticated, "invalid request signature")
+	}
+
+	if timestamp != "" || nonce != "" {
+		if err := replay.Check(timestamp, nonce); err != nil {
+			return "", status.Error(codes.Unauthenticated, err.Error())
+		}
+	}
+
+	return key, nil
+}
+
+// NewResponseSigningInterceptor подписывает успешные ответы ключом, которым
+// подписан запрос (или ключом по умолчанию), и передаёт подпись в трейлере.
+func NewResponseSigningInterceptor(keys *service.KeyRing) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil || !keys.Enabled() {
+			return resp, err
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		key, lookupErr := keys.Lookup(firstValue(md, KeyIDMetadataKey))
+		if lookupErr != nil {
+			key, _ = keys.Lookup("")
+		}
+		if key == "" {
+			return resp, nil
+		}
+
+		sign, signErr := SignReply(firstValue(md, NonceMetadataKey), resp, key)
+		if signErr != nil {
+			return nil, status.Error(codes.Internal, signErr.Error())
+		}
+
+		if err := grpc.SetTrailer(ctx, metadata.Pairs(HashMetadataKey, sign)); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		return resp, nil
+	}
+}
+
+// NewSigningClientInterceptor добавляет в метаданные время, nonce и подпись каждого запроса
+// и проверяет подпись ответа в трейлере. Непустой keyID сообщает серверу,
+// каким ключом из связки подписан запрос.
+func NewSigningClientInterceptor(keyID, key string) grpc.UnaryClientInterceptor {
+	return func(
+		ctx context.Context,
+		method string,
+		req, reply interface{},
+		cc *grpc.ClientConn,
+		invoker grpc.UnaryInvoker,
+		opts ...grpc.CallOption,
+	) error {
+		timestamp := service.FormatTimestamp(time.Now())
+		nonce := service.NewNonce()
+
+		sign, err := SignCall(method, timestamp, nonce, req, key)
+		if err != nil {
+			return err
+		}
+
+		ctx = metadata.AppendToOutgoingContext(ctx,
+			TimestampMetadataKey, timestamp,
+			NonceMetadataKey, nonce,
+			HashMetadataKey, sign,
+		)
+		if keyID != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, KeyIDMetadataKey, keyID)
+		}
+
+		var trailer metadata.MD
+		if err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Trailer(&trailer))...); err != nil {
+			return err
+		}
+
+		expected, err := SignReply(nonce, reply, key)
+		if err != nil {
+			return err
+		}
+
+		if !hmac.Equal([]byte(expected), []byte(firstValue(trailer, HashMetadataKey))) {
+			return service.ErrInvalidResponseSignature
+		}
+
+		return nil
+	}
+}
+
+// NewSigningStreamClientInterceptor подписывает запрос серверного потока так же,
+// как NewSigningClientInterceptor, и проверяет подпись заголовка ответа.
+// Метаданные уходят при открытии потока, а запрос известен только в SendMsg,
+// поэтому поток открывается при отправке запроса. Потоки с отправкой
+// нескольких сообщений клиентом не подписываются.
+func NewSigningStreamClientInterceptor(keyID, key string) grpc.StreamClientInterceptor {
+	return func(
+		ctx context.Context,
+		desc *grpc.StreamDesc,
+		cc *grpc.ClientConn,
+		method string,
+		streamer grpc.Streamer,
+		opts ...grpc.CallOption,
+	) (grpc.ClientStream, error) {
+		if desc.ClientStreams {
+			return streamer(ctx, desc, cc, method, opts...)
+		}
+
+		return &signingStream{
+			ctx:    ctx,
+			method: method,
+			keyID:  keyID,
+			key:    key,
+			open: func(ctx context.Context) (grpc.ClientStream, error) {
+				return streamer(ctx, desc, cc, method, opts...)
+			},
+		}, nil
+	}
+}
+
+type signingStream struct {
+	grpc.ClientStream
+	ctx    context.Context
+	method string
+	keyID  string
+	key    string
+	open   func(context.Context) (grpc.ClientStream, error)
+
+	req      any
+	nonce    string
+	verified bool
+}
+
+func (s *signingStream) Context() context.Context {
+	if s.ClientStream == nil {
+		return s.ctx
+	}
+
+	return s.ClientStream.Context()
+}
+
+func (s *signingStream) SendMsg(m any) error {
+	if s.ClientStream != nil {
+		return s.ClientStream.SendMsg(m)
+	}
+
+	timestamp := service.FormatTimestamp(time.Now())
+	s.nonce = service.NewNonce()
+
+	sign, err := SignCall(s.method, timestamp, s.nonce, m, s.key)
+	if err != nil {
+		return err
+	}
+
+	ctx := metadata.AppendToOutgoingContext(s.ctx,
+		TimestampMetadataKey, timestamp,
+		NonceMetadataKey, s.nonce,
+		HashMetadataKey, sign,
+	)
+	if s.keyID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, KeyIDMetadataKey, s.keyID)
+	}
+
+	if s.ClientStream, err = s.open(ctx); err != nil {
+		return err
+	}
+	s.req = m
+
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *signingStream) RecvMsg(m any) error {
+	if s.ClientStream == nil {
+		return status.Error(codes.Internal, "stream request was not sent")
+	}
+
+	if !s.verified {
+		header, err := s.ClientStream.Header()
+		if err != nil {
+			return err
+		}
+
+		expected, err := SignReply(s.nonce, s.req, s.key)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal([]byte(expected), []byte(firstValue(header, HashMetadataKey))) {
+			// Отклонённый сервером поток приходит без заголовков: возвращаем его ошибку.
+			if err := s.ClientStream.RecvMsg(m); err != nil {
+				return err
+			}
+			return service.ErrInvalidResponseSignature
+		}
+		s.verified = true
+	}
+
+	return s.ClientStream.RecvMsg(m)
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+// EncryptionCodec — protobuf-кодек, шифрующий сообщения открытым ключом
+// и расшифровывающий закрытым. Клиенту нужен только publicKey (запросы
+// шифруются, ответы читаются как есть), серверу — только privateKey.
+// Выбирается по content-subtype, как Content-Encryption в HTTP.
+type EncryptionCodec struct {
+	scheme     string
+	publicKey  *rsa.PublicKey
+	privateKey *rsa.PrivateKey
+}
+
+func NewEncryptionCodec(scheme string, publicKey *rsa.PublicKey, privateKey *rsa.PrivateKey) *EncryptionCodec {
+	return &EncryptionCodec{
+		scheme:     scheme,
+		publicKey:  publicKey,
+		privateKey: privateKey,
+	}
+}
+
+// RegisterEncryptionCodec регистрирует серверные кодеки для всех схем шифрования;
+// вызывается до запуска grpc.Server.
+func RegisterEncryptionCodec(privateKey *rsa.PrivateKey) {
+	encoding.RegisterCodec(NewEncryptionCodec(service.EncryptionHybrid, nil, privateKey))
+	encoding.RegisterCodec(NewEncryptionCodec(service.EncryptionRSAPKCS1v15, nil, privateKey))
+}
+
+func (c *EncryptionCodec) Name() string {
+	return strings.ToLower(c.scheme)
+}
+
+func (c *EncryptionCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(protobuf.Message)
+	if !ok {
+		return nil, fmt.Errorf("unsupported message type %T", v)
+	}
+
+	data, err := protobuf.Marshal(m)
+	if err != nil || c.publicKey == nil {
+		return data, err
+	}
+
+	return service.Encrypt(c.scheme, c.publicKey, data)
+}
+
+func (c *EncryptionCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(protobuf.Message)
+	if !ok {
+		return fmt.Errorf("unsupported message type %T", v)
+	}
+
+	if c.privateKey != nil {
+		plain, err := service.Decrypt(c.scheme, c.privateKey, data)
+		if err != nil {
+			return fmt.Errorf("decrypt message: %w", err)
+		}
+		data = plain
+	}
+
+	return protobuf.Unmarshal(data, m)
+}
@@ -0,0 +1,353 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	models "metrify/internal/model"
+	"metrify/internal/proto"
+	"metrify/internal/service"
+)
+
+func newGaugeRequest(id string, value float64) *proto.UpdateMetricsRequest {
+	metric := &proto.Metric{}
+	metric.SetId(id)
+	metric.SetType(proto.Metric_GAUGE)
+	metric.SetValue(value)
+
+	req := &proto.UpdateMetricsRequest{}
+	req.SetMetrics([]*proto.Metric{metric})
+
+	return req
+}
+
+func TestSignMessage(t *testing.T) {
+	s1, err := SignMessage(newGaugeRequest("Alloc", 1), "key")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s2, _ := SignMessage(newGaugeRequest("Alloc", 1), "key")
+	s3, _ := SignMessage(newGaugeRequest("Alloc", 2), "key")
+	s4, _ := SignMessage(newGaugeRequest("Alloc", 1), "other")
+
+	if s1 != s2 {
+		t.Fatal("expected equal signatures for equal messages")
+	}
+	if s1 == s3 || s1 == s4 {
+		t.Fatal("expected different signatures")
+	}
+
+	if _, err := SignMessage("not a message", "key"); err == nil {
+		t.Fatal("expected error for non-proto message")
+	}
+}
+
+func TestHashInterceptor(t *testing.T) {
+	req := newGaugeRequest("Alloc", 1)
+	legacy, _ := SignMessage(req, "key")
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+
+	ts := service.FormatTimestamp(time.Now())
+	signed := func(method, timestamp, nonce string) metadata.MD {
+		sign, _ := SignCall(method, timestamp, nonce, req, "key")
+		return metadata.Pairs(HashMetadataKey, sign, TimestampMetadataKey, timestamp, NonceMetadataKey, nonce)
+	}
+	old := service.FormatTimestamp(time.Now().Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		key    string
+		strict bool
+		md     metadata.MD
+		code   codes.Code
+	}{
+		{name: "no key configured", key: "", md: metadata.Pairs(HashMetadataKey, "garbage"), code: codes.OK},
+		{name: "unsigned request", key: "key", md: metadata.MD{}, code: codes.OK},
+		{name: "unsigned request in strict mode", key: "key", strict: true, md: metadata.MD{}, code: codes.Unauthenticated},
+		{name: "unsigned request in strict mode without keys", key: "", strict: true, md: metadata.MD{}, code: codes.OK},
+		{name: "valid legacy signature", key: "key", md: metadata.Pairs(HashMetadataKey, legacy), code: codes.OK},
+		{name: "legacy signature in strict mode", key: "key", strict: true, md: metadata.Pairs(HashMetadataKey, legacy), code: codes.Unauthenticated},
+		{name: "invalid signature", key: "key", md: metadata.Pairs(HashMetadataKey, "garbage"), code: codes.Unauthenticated},
+		{name: "wrong key", key: "other", md: metadata.Pairs(HashMetadataKey, legacy), code: codes.Unauthenticated},
+		{name: "valid timestamped signature", key: "key", strict: true, md: signed(info.FullMethod, ts, "n1"), code: codes.OK},
+		{name: "signature for other method", key: "key", md: signed("/metrics.Metrics/Other", ts, "n1"), code: codes.Unauthenticated},
+		{name: "stale timestamp", key: "key", md: signed(info.FullMethod, old, "n1"), code: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := NewHashInterceptor(service.NewStaticKeyRing(tt.key), service.NewReplayGuard(time.Minute, tt.strict))
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+
+			_, err := interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return &proto.UpdateMetricsResponse{}, nil
+			})
+
+			if status.Code(err) != tt.code {
+				t.Fatalf("got %v, want %v", status.Code(err), tt.code)
+			}
+		})
+	}
+}
+
+func TestHashInterceptor_RejectsReplay(t *testing.T) {
+	req := newGaugeRequest("Alloc", 1)
+	info := &grpc.UnaryServerInfo{FullMethod: "/metrics.Metrics/UpdateMetrics"}
+	interceptor := NewHashInterceptor(service.NewStaticKeyRing("key"), service.NewReplayGuard(time.Minute, false))
+
+	ts := service.FormatTimestamp(time.Now())
+	sign, _ := SignCall(info.FullMethod, ts, "nonce", req, "key")
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs(HashMetadataKey, sign, TimestampMetadataKey, ts, NonceMetadataKey, "nonce"))
+
+	calls := 0
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		calls++
+		return &proto.UpdateMetricsResponse{}, nil
+	}
+
+	if _, err := interceptor(ctx, req, info, handler); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := interceptor(ctx, req, info, handler); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for replayed request, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+}
+
+// startSignedServer поднимает сервер на bufconn с проверкой подписи запросов;
+// signReplies включает подпись ответов.
+func startSignedServer(t *testing.T, keys *service.KeyRing, signReplies bool) (*bufconn.Listener, *storageMock) {
+	t.Helper()
+
+	interceptors := []grpc.UnaryServerInterceptor{NewHashInterceptor(keys, service.NewReplayGuard(time.Minute, true))}
+	if signReplies {
+		interceptors = append(interceptors, NewResponseSigningInterceptor(keys))
+	}
+
+	st := newStorageMock()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(interceptors...))
+	proto.RegisterMetricsServer(srv, NewMetricsService(st))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	return lis, st
+}
+
+func dialSigned(t *testing.T, lis *bufconn.Listener, keyID, key string, opts ...grpc.DialOption) proto.MetricsClient {
+	t.Helper()
+
+	opts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(NewSigningClientInterceptor(keyID, key)),
+	}, opts...)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return proto.NewMetricsClient(conn)
+}
+
+func TestSigningClientInterceptor_KeyID(t *testing.T) {
+	keys, err := service.NewKeyRing(writeKeys(t, `{"keys":[{"id":"next","secret":"rotated"}]}`), "")
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+	lis, _ := startSignedServer(t, keys, true)
+
+	call := func(keyID, key string) error {
+		_, err := dialSigned(t, lis, keyID, key).UpdateMetrics(context.Background(), newGaugeRequest("Alloc", 1))
+		return err
+	}
+
+	if err := call("next", "rotated"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := call("gone", "rotated"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for unknown key id, got %v", err)
+	}
+	if err := call("next", "wrong"); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for wrong secret, got %v", err)
+	}
+	if err := call("", ""); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated for empty key without a default key, got %v", err)
+	}
+}
+
+func TestSigningClientInterceptor_VerifiesReply(t *testing.T) {
+	keys := service.NewStaticKeyRing("secret")
+
+	lis, _ := startSignedServer(t, keys, true)
+	if _, err := dialSigned(t, lis, "", "secret").UpdateMetrics(context.Background(), newGaugeRequest("Alloc", 1)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Ответ без подписи (например, подменённый по дороге) клиент не принимает.
+	unsigned, _ := startSignedServer(t, keys, false)
+	_, err := dialSigned(t, unsigned, "", "secret").UpdateMetrics(context.Background(), newGaugeRequest("Alloc", 1))
+	if !errors.Is(err, service.ErrInvalidResponseSignature) {
+		t.Fatalf("expected ErrInvalidResponseSignature, got %v", err)
+	}
+}
+
+func writeKeys(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestEncryptionCodec_RoundTrip(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	for _, scheme := range []string{service.EncryptionHybrid, service.EncryptionRSAPKCS1v15} {
+		t.Run(scheme, func(t *testing.T) {
+			client := NewEncryptionCodec(scheme, &privKey.PublicKey, nil)
+			server := NewEncryptionCodec(scheme, nil, privKey)
+
+			data, err := client.Marshal(newGaugeRequest("Alloc", 3.5))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if again, _ := client.Marshal(newGaugeRequest("Alloc", 3.5)); string(again) == string(data) {
+				t.Fatal("expected ciphertext to be randomized")
+			}
+
+			got := &proto.UpdateMetricsRequest{}
+			if err := server.Unmarshal(data, got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.GetMetrics()[0].GetId() != "Alloc" || got.GetMetrics()[0].GetValue() != 3.5 {
+				t.Fatalf("unexpected message: %v", got)
+			}
+		})
+	}
+}
+
+func TestSignedEncryptedTransport(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	RegisterEncryptionCodec(privKey)
+
+	lis, st := startSignedServer(t, service.NewStaticKeyRing("secret"), true)
+	codec := grpc.WithDefaultCallOptions(grpc.ForceCodec(NewEncryptionCodec(service.EncryptionHybrid, &privKey.PublicKey, nil)))
+
+	if _, err := dialSigned(t, lis, "", "secret", codec).UpdateMetrics(context.Background(), newGaugeRequest("Alloc", 7)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, ok := st.GetGauge("Alloc"); !ok || v != 7 {
+		t.Fatalf("unexpected gauge: %v %v", v, ok)
+	}
+
+	_, err = dialSigned(t, lis, "", "wrong", codec).UpdateMetrics(context.Background(), newGaugeRequest("Alloc", 8))
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("expected Unauthenticated, got %v", err)
+	}
+}
+
+func TestHashStreamInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		serverKey string
+		clientKey string
+		wantErr   error
+		wantCode  codes.Code
+	}{
+		{name: "signed", serverKey: "secret", clientKey: "secret"},
+		{name: "wrong key", serverKey: "secret", clientKey: "other", wantCode: codes.Unauthenticated},
+		{name: "unsigned in strict mode", serverKey: "secret", wantCode: codes.Unauthenticated},
+		{name: "unsigned header", clientKey: "secret", wantErr: service.ErrInvalidResponseSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newStorageMock()
+
+			lis := bufconn.Listen(1 << 20)
+			srv := grpc.NewServer(grpc.ChainStreamInterceptor(
+				NewHashStreamInterceptor(service.NewStaticKeyRing(tt.serverKey), service.NewReplayGuard(time.Minute, true)),
+			))
+			proto.RegisterMetricsServer(srv, NewMetricsService(st))
+			go func() { _ = srv.Serve(lis) }()
+			t.Cleanup(srv.Stop)
+
+			opts := []grpc.DialOption{
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}),
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+			}
+			if tt.clientKey != "" {
+				opts = append(opts, grpc.WithChainStreamInterceptor(NewSigningStreamClientInterceptor("", tt.clientKey)))
+			}
+			conn, err := grpc.NewClient("passthrough:///bufnet", opts...)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			req := &proto.WatchMetricsRequest{}
+			req.SetPattern("Alloc")
+			stream, err := proto.NewMetricsClient(conn).WatchMetrics(ctx, req)
+			if err != nil {
+				t.Fatalf("WatchMetrics() error = %v", err)
+			}
+
+			if tt.wantCode == codes.OK {
+				waitSubscribers(t, st.hub, 1)
+				v := 1.5
+				st.hub.Publish(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v})
+			}
+
+			m, err := stream.Recv()
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantCode != codes.OK:
+				if status.Code(err) != tt.wantCode {
+					t.Fatalf("got %v, want %v", err, tt.wantCode)
+				}
+			case err != nil || m.GetId() != "Alloc" || m.GetValue() != 1.5:
+				t.Fatalf("got %v, %v; want Alloc 1.5", m, err)
+			}
+		})
+	}
+}
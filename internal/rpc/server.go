@@ -2,40 +2,83 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"metrify/internal/alert"
 	models "metrify/internal/model"
 	"metrify/internal/proto"
+	"metrify/internal/query"
 	"metrify/internal/service"
+	"metrify/internal/validation"
 	"net"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-func RunGRPCServer(addr string, storage service.Storage, logger *zap.SugaredLogger) error {
+const shutdownTimeout = 5 * time.Second
+
+// RunGRPCServer слушает addr и обслуживает grpcServer до отмены ctx,
+// после чего выполняет GracefulStop. Если активные стримы (WatchMetrics)
+// не завершились за shutdownTimeout, сервер останавливается принудительно.
+func RunGRPCServer(ctx context.Context, addr string, grpcServer *grpc.Server, logger *zap.SugaredLogger) error {
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		return err
 	}
 
-	grpcServer := grpc.NewServer()
-	proto.RegisterMetricsServer(grpcServer, NewMetricsService(storage))
+	return ServeGRPC(ctx, lis, grpcServer, logger)
+}
+
+// ServeGRPC — RunGRPCServer для уже открытого слушателя.
+func ServeGRPC(ctx context.Context, lis net.Listener, grpcServer *grpc.Server, logger *zap.SugaredLogger) error {
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+
+		done := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(done)
+		}()
 
-	logger.Infow("grpc server started", "addr", addr)
+		select {
+		case <-done:
+		case <-time.After(shutdownTimeout):
+			grpcServer.Stop()
+		}
+	}()
+
+	logger.Infow("grpc server started", "addr", lis.Addr().String())
 
-	return grpcServer.Serve(lis)
+	if err := grpcServer.Serve(lis); err != nil {
+		return err
+	}
+
+	<-stopped
+
+	return nil
 }
 
 type MetricsService struct {
 	proto.UnimplementedMetricsServer
 	storage service.Storage
+	// MaxBatchSize ограничивает число метрик в UpdateMetrics. Ноль снимает ограничение.
+	MaxBatchSize int
+	Validator    *validation.Validator
+	// Alerts — движок правил для ListAlerts; nil, если правила не заданы.
+	Alerts *alert.Engine
 }
 
 func NewMetricsService(storage service.Storage) *MetricsService {
 	return &MetricsService{
-		storage: storage,
+		storage:      storage,
+		MaxBatchSize: service.DefaultMaxBatchSize,
+		Validator:    validation.New(),
 	}
 }
 
@@ -43,35 +86,160 @@ func (s *MetricsService) UpdateMetrics(
 	ctx context.Context,
 	req *proto.UpdateMetricsRequest,
 ) (*proto.UpdateMetricsResponse, error) {
-	_ = ctx
-
 	if req == nil {
 		return nil, status.Error(codes.InvalidArgument, "request is nil")
 	}
 
-	for _, grpcMetric := range req.GetMetrics() {
+	if s.MaxBatchSize > 0 && len(req.GetMetrics()) > s.MaxBatchSize {
+		return nil, status.Errorf(codes.ResourceExhausted, "batch of %d metrics exceeds the limit of %d", len(req.GetMetrics()), s.MaxBatchSize)
+	}
+
+	storage := s.storage.ForTenant(service.TenantFromContext(ctx))
+	results := make([]*proto.MetricResult, 0, len(req.GetMetrics()))
+
+	// Некорректные метрики и метрики сверх квоты отмечаются в результатах,
+	// остальные записываются. Сбой хранилища прерывает весь вызов.
+	for i, grpcMetric := range req.GetMetrics() {
+		result := &proto.MetricResult{}
+		result.SetIndex(int32(i))
+		result.SetId(grpcMetric.GetId())
+		results = append(results, result)
+
 		metric, err := metricFromProto(grpcMetric)
+		if err == nil {
+			err = s.Validator.Metric(*metric)
+		}
 		if err != nil {
-			return nil, status.Error(codes.InvalidArgument, err.Error())
+			result.SetError(err.Error())
+			continue
+		}
+
+		if metric.MType == models.Gauge {
+			err = storage.UpdateGauge(metric.ID, *metric.Value)
+		} else {
+			err = storage.UpdateCounter(metric.ID, *metric.Delta)
+		}
+
+		switch {
+		case err == nil:
+			result.SetOk(true)
+		case errors.Is(err, service.ErrQuotaExceeded):
+			result.SetError(err.Error())
+		default:
+			return nil, status.Error(codes.Internal, err.Error())
 		}
+	}
+
+	resp := &proto.UpdateMetricsResponse{}
+	resp.SetResults(results)
+
+	return resp, nil
+}
+
+func (s *MetricsService) WatchMetrics(
+	req *proto.WatchMetricsRequest,
+	stream grpc.ServerStreamingServer[proto.Metric],
+) error {
+	filter := service.WatchFilter{Pattern: req.GetPattern()}
+
+	for _, t := range req.GetTypes() {
+		switch t {
+		case proto.Metric_GAUGE:
+			filter.Types = append(filter.Types, models.Gauge)
+		case proto.Metric_COUNTER:
+			filter.Types = append(filter.Types, models.Counter)
+		default:
+			return status.Error(codes.InvalidArgument, fmt.Sprintf("unknown metric type %v", t))
+		}
+	}
 
-		switch metric.MType {
-		case models.Gauge:
-			if err := s.storage.UpdateGauge(metric.ID, *metric.Value); err != nil {
-				return nil, status.Error(codes.Internal, err.Error())
+	sub, err := s.storage.ForTenant(service.TenantFromContext(stream.Context())).Watch(filter)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	defer sub.Close()
+
+	ctx := stream.Context()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case metric, ok := <-sub.Updates():
+			if !ok {
+				if err := sub.Err(); err != nil {
+					return status.Error(codes.ResourceExhausted, err.Error())
+				}
+				return nil
 			}
-		case models.Counter:
-			if err := s.storage.UpdateCounter(metric.ID, *metric.Delta); err != nil {
-				return nil, status.Error(codes.Internal, err.Error())
+
+			// Обновление без значения не переводится в сообщение — пропускаем его.
+			m, err := proto.FromModel(metric)
+			if err != nil {
+				continue
+			}
+			if err := stream.Send(m); err != nil {
+				return err
 			}
-		default:
-			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("unknown metric type %q", metric.MType))
 		}
 	}
+}
 
-	return &proto.UpdateMetricsResponse{}, nil
+// Query вычисляет запрос так же, как GET /query.
+func (s *MetricsService) Query(ctx context.Context, req *proto.QueryRequest) (*proto.QueryResponse, error) {
+	samples, err := query.Run(s.storage.ForTenant(service.TenantFromContext(ctx)), req.GetQuery(), time.Now())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	result := make([]*proto.Sample, 0, len(samples))
+	for _, sample := range samples {
+		ps := &proto.Sample{}
+		ps.SetLabels(sample.Labels)
+		ps.SetValue(sample.Value)
+		result = append(result, ps)
+	}
+
+	resp := &proto.QueryResponse{}
+	resp.SetSamples(result)
+
+	return resp, nil
 }
 
+// ListAlerts возвращает алерты так же, как GET /alerts.
+func (s *MetricsService) ListAlerts(ctx context.Context, req *proto.ListAlertsRequest) (*proto.ListAlertsResponse, error) {
+	if !alert.ValidState(req.GetState()) {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown alert state %q (expect pending|firing|resolved)", req.GetState())
+	}
+
+	alerts := s.Alerts.Alerts(service.TenantFromContext(ctx), req.GetState())
+	result := make([]*proto.Alert, 0, len(alerts))
+	for _, a := range alerts {
+		pa := &proto.Alert{}
+		pa.SetRule(a.Rule)
+		pa.SetState(a.State)
+		pa.SetSeverity(a.Severity)
+		pa.SetLabels(a.Labels)
+		pa.SetAnnotations(a.Annotations)
+		pa.SetValue(a.Value)
+		pa.SetActiveAt(a.ActiveAt.UnixMilli())
+		if a.FiredAt != nil {
+			pa.SetFiredAt(a.FiredAt.UnixMilli())
+		}
+		if a.ResolvedAt != nil {
+			pa.SetResolvedAt(a.ResolvedAt.UnixMilli())
+		}
+		result = append(result, pa)
+	}
+
+	resp := &proto.ListAlertsResponse{}
+	resp.SetAlerts(result)
+
+	return resp, nil
+}
+
+// metricFromProto переводит метрику запроса, отклоняя пустую метрику,
+// метрику без имени и метрику неизвестного типа.
 func metricFromProto(m *proto.Metric) (*models.Metrics, error) {
 	if m == nil {
 		return nil, fmt.Errorf("metric is nil")
@@ -81,26 +249,11 @@ func metricFromProto(m *proto.Metric) (*models.Metrics, error) {
 		return nil, fmt.Errorf("metric id is empty")
 	}
 
-	switch m.GetType() {
-	case proto.Metric_GAUGE:
-		value := m.GetValue()
-
-		return &models.Metrics{
-			ID:    m.GetId(),
-			MType: models.Gauge,
-			Value: &value,
-		}, nil
-
-	case proto.Metric_COUNTER:
-		delta := m.GetDelta()
-
-		return &models.Metrics{
-			ID:    m.GetId(),
-			MType: models.Counter,
-			Delta: &delta,
-		}, nil
-
-	default:
+	if m.GetType() != proto.Metric_GAUGE && m.GetType() != proto.Metric_COUNTER {
 		return nil, fmt.Errorf("unknown metric type %v", m.GetType())
 	}
+
+	metric := proto.ToModel(m)
+
+	return &metric, nil
 }
@@ -3,10 +3,22 @@ package rpc
 import (
 	"context"
 	"errors"
+	"math"
+	"net"
 	"testing"
+	"time"
 
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"metrify/internal/alert"
 	models "metrify/internal/model"
 	"metrify/internal/proto"
+	"metrify/internal/service"
 )
 
 type storageMock struct {
@@ -14,12 +26,14 @@ type storageMock struct {
 	counters         map[string]int64
 	updateGaugeErr   error
 	updateCounterErr error
+	hub              *service.Hub
 }
 
 func newStorageMock() *storageMock {
 	return &storageMock{
 		gauges:   make(map[string]float64),
 		counters: make(map[string]int64),
+		hub:      service.NewHub(1),
 	}
 }
 
@@ -53,6 +67,34 @@ func (m *storageMock) FlushToFile() error {
 	return nil
 }
 
+func (m *storageMock) Watch(filter service.WatchFilter) (*service.Subscription, error) {
+	return m.hub.Subscribe(filter)
+}
+
+func (m *storageMock) List() []models.Metrics {
+	return nil
+}
+
+func (m *storageMock) Delete(string, string) bool {
+	return false
+}
+
+func (m *storageMock) History(string, string) []models.Point {
+	return nil
+}
+
+func (m *storageMock) Version(string, string) (service.Version, bool) {
+	return service.Version{}, false
+}
+
+func (m *storageMock) ListVersion() service.Version {
+	return service.Version{}
+}
+
+func (m *storageMock) ForTenant(string) service.Storage {
+	return m
+}
+
 func TestNewMetricsService(t *testing.T) {
 	st := newStorageMock()
 
@@ -166,14 +208,46 @@ func TestMetricsService_UpdateMetrics(t *testing.T) {
 		req := &proto.UpdateMetricsRequest{}
 		req.SetMetrics([]*proto.Metric{nil})
 
-		_, err := svc.UpdateMetrics(context.Background(), req)
-		if err == nil {
-			t.Fatal("expected error")
+		resp, err := svc.UpdateMetrics(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
 		}
 
-		if got := err.Error(); got != "rpc error: code = InvalidArgument desc = metric is nil" {
+		results := resp.GetResults()
+		if len(results) != 1 || results[0].GetOk() || results[0].GetError() != "metric is nil" {
+			t.Fatalf("unexpected results: %v", results)
+		}
+	})
+
+	t.Run("partially invalid batch", func(t *testing.T) {
+		st := newStorageMock()
+		svc := NewMetricsService(st)
+
+		unknown := &proto.Metric{}
+		unknown.SetId("Bad")
+		unknown.SetType(proto.Metric_MType(99))
+
+		req := newGaugeRequest("Alloc", 1)
+		req.SetMetrics(append(req.GetMetrics(), unknown))
+
+		resp, err := svc.UpdateMetrics(context.Background(), req)
+		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
+
+		results := resp.GetResults()
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results, got %v", results)
+		}
+		if !results[0].GetOk() || results[0].GetId() != "Alloc" {
+			t.Fatalf("unexpected first result: %v", results[0])
+		}
+		if results[1].GetOk() || results[1].GetIndex() != 1 || results[1].GetError() != "unknown metric type 99" {
+			t.Fatalf("unexpected second result: %v", results[1])
+		}
+		if _, ok := st.GetGauge("Alloc"); !ok {
+			t.Fatal("valid metric must be applied")
+		}
 	})
 
 	t.Run("update gauge and counter", func(t *testing.T) {
@@ -218,6 +292,22 @@ func TestMetricsService_UpdateMetrics(t *testing.T) {
 		}
 	})
 
+	t.Run("non-finite gauge", func(t *testing.T) {
+		st := newStorageMock()
+		svc := NewMetricsService(st)
+
+		resp, err := svc.UpdateMetrics(context.Background(), newGaugeRequest("Alloc", math.NaN()))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if results := resp.GetResults(); len(results) != 1 || results[0].GetOk() {
+			t.Fatalf("expected NaN gauge to be rejected, got %v", results)
+		}
+		if _, ok := st.GetGauge("Alloc"); ok {
+			t.Fatal("NaN gauge must not be stored")
+		}
+	})
+
 	t.Run("storage update gauge error", func(t *testing.T) {
 		st := newStorageMock()
 		st.updateGaugeErr = errors.New("db failed")
@@ -277,3 +367,274 @@ func TestMetricsService_UpdateMetrics(t *testing.T) {
 		}
 	})
 }
+
+func newBufconnClient(t *testing.T, svc proto.MetricsServer) proto.MetricsClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	proto.RegisterMetricsServer(srv, svc)
+
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return proto.NewMetricsClient(conn)
+}
+
+func waitSubscribers(t *testing.T, hub *service.Hub, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for hub.Len() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("subscribers = %d, want %d", hub.Len(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMetricsService_WatchMetrics(t *testing.T) {
+	st := newStorageMock()
+	client := newBufconnClient(t, NewMetricsService(st))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	req := &proto.WatchMetricsRequest{}
+	req.SetPattern("Heap*")
+	req.SetTypes([]proto.Metric_MType{proto.Metric_GAUGE})
+
+	stream, err := client.WatchMetrics(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitSubscribers(t, st.hub, 1)
+
+	v := 42.0
+	st.hub.Publish(models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &v})
+	st.hub.Publish(models.Metrics{ID: "HeapAlloc", MType: models.Gauge, Value: &v})
+
+	got, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.GetId() != "HeapAlloc" || got.GetType() != proto.Metric_GAUGE || got.GetValue() != 42 {
+		t.Fatalf("unexpected metric: %v", got)
+	}
+
+	cancel()
+	waitSubscribers(t, st.hub, 0)
+}
+
+func TestMetricsService_WatchMetrics_InvalidPattern(t *testing.T) {
+	client := newBufconnClient(t, NewMetricsService(newStorageMock()))
+
+	req := &proto.WatchMetricsRequest{}
+	req.SetPattern("[")
+
+	stream, err := client.WatchMetrics(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = stream.Recv()
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestMetricsService_WatchMetrics_SlowConsumer(t *testing.T) {
+	st := newStorageMock()
+	svc := NewMetricsService(st)
+	client := newBufconnClient(t, svc)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	stream, err := client.WatchMetrics(ctx, &proto.WatchMetricsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitSubscribers(t, st.hub, 1)
+
+	// Буфер хаба в моке — одно значение: пока сервер отправляет первое
+	// обновление, последующие переполняют буфер и подписка сбрасывается.
+	for i := 0; i < 1000 && st.hub.Len() > 0; i++ {
+		st.hub.Publish(models.Metrics{ID: "Alloc", MType: models.Gauge})
+	}
+
+	for {
+		_, err = stream.Recv()
+		if err != nil {
+			break
+		}
+	}
+
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestMetricsService_Query(t *testing.T) {
+	ms := service.NewMemStorage("", nil)
+	for name, value := range map[string]float64{"HeapAlloc:web-1": 10, "HeapAlloc:web-2": 30} {
+		if err := ms.UpdateGauge(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ms.ForTenant("team").UpdateGauge("HeapAlloc:web-3", 99); err != nil {
+		t.Fatal(err)
+	}
+
+	svc := NewMetricsService(ms)
+
+	req := &proto.QueryRequest{}
+	req.SetQuery("max by (host) (HeapAlloc*)")
+	resp, err := svc.Query(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(resp.GetSamples()) != 2 {
+		t.Fatalf("got %d samples, want 2", len(resp.GetSamples()))
+	}
+	if got := resp.GetSamples()[1]; got.GetLabels()["host"] != "web-2" || got.GetValue() != 30 {
+		t.Fatalf("second sample = %v", got)
+	}
+
+	req.SetQuery("max(HeapAlloc*)")
+	resp, err = svc.Query(service.WithTenant(context.Background(), "team"), req)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(resp.GetSamples()) != 1 || resp.GetSamples()[0].GetValue() != 99 {
+		t.Fatalf("query must see only tenant metrics, got %v", resp.GetSamples())
+	}
+
+	req.SetQuery("median(HeapAlloc*)")
+	if _, err := svc.Query(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("invalid query code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestMetricsService_ListAlerts(t *testing.T) {
+	ms := service.NewMemStorage("", nil)
+	svc := NewMetricsService(ms)
+
+	resp, err := svc.ListAlerts(context.Background(), &proto.ListAlertsRequest{})
+	if err != nil || len(resp.GetAlerts()) != 0 {
+		t.Fatalf("ListAlerts() without rules = %v, %v", resp.GetAlerts(), err)
+	}
+
+	engine, err := alert.NewEngine(ms, []alert.Rule{{Name: "HighHeap", Expr: "HeapAlloc", Op: ">", Threshold: 1}}, zap.NewNop().Sugar())
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.Alerts = engine
+
+	if err := ms.UpdateGauge("HeapAlloc", 5); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := engine.Evaluate(now); err != nil {
+		t.Fatal(err)
+	}
+
+	req := &proto.ListAlertsRequest{}
+	req.SetState("firing")
+	resp, err = svc.ListAlerts(context.Background(), req)
+	if err != nil {
+		t.Fatalf("ListAlerts() error = %v", err)
+	}
+	if len(resp.GetAlerts()) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(resp.GetAlerts()))
+	}
+	a := resp.GetAlerts()[0]
+	if a.GetRule() != "HighHeap" || a.GetValue() != 5 || a.GetLabels()["__name__"] != "HeapAlloc" ||
+		a.GetActiveAt() != now.UnixMilli() || a.GetFiredAt() != now.UnixMilli() || a.GetResolvedAt() != 0 {
+		t.Fatalf("alert = %v", a)
+	}
+
+	resp, err = svc.ListAlerts(service.WithTenant(context.Background(), "team"), &proto.ListAlertsRequest{})
+	if err != nil || len(resp.GetAlerts()) != 0 {
+		t.Fatalf("other tenant must not see alerts: %v, %v", resp.GetAlerts(), err)
+	}
+
+	req.SetState("loud")
+	if _, err := svc.ListAlerts(context.Background(), req); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown state code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestRunGRPCServer_ServesUntilCanceled(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := lis.Addr().String()
+	_ = lis.Close()
+
+	st := newStorageMock()
+	srv := grpc.NewServer()
+	proto.RegisterMetricsServer(srv, NewMetricsService(st))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- RunGRPCServer(ctx, addr, srv, zap.NewNop().Sugar())
+	}()
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	metric := &proto.Metric{}
+	metric.SetId("PollCount")
+	metric.SetType(proto.Metric_COUNTER)
+	metric.SetDelta(2)
+	req := &proto.UpdateMetricsRequest{}
+	req.SetMetrics([]*proto.Metric{metric})
+
+	callCtx, callCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer callCancel()
+
+	if _, err := proto.NewMetricsClient(conn).UpdateMetrics(callCtx, req, grpc.WaitForReady(true)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, _ := st.GetCounter("PollCount"); v != 2 {
+		t.Fatalf("unexpected counter value: %v", v)
+	}
+
+	cancel()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * shutdownTimeout):
+		t.Fatal("server did not stop")
+	}
+}
+
+func TestRunGRPCServer_InvalidAddr(t *testing.T) {
+	err := RunGRPCServer(context.Background(), "invalid-addr", grpc.NewServer(), zap.NewNop().Sugar())
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
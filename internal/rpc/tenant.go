@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"metrify/internal/auth"
+	"metrify/internal/service"
+)
+
+// TenantMetadataKey — ключ метаданных с арендатором, аналог заголовка X-Tenant-ID.
+const TenantMetadataKey = "x-tenant-id"
+
+// NewTenantInterceptor определяет арендатора унарного вызова.
+// Ставится после NewAuthInterceptor, как WithTenant после RequireScope в HTTP.
+func NewTenantInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, err := resolveTenant(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// NewTenantStreamInterceptor определяет арендатора потокового вызова.
+func NewTenantStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := resolveTenant(ss.Context())
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &identityStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func resolveTenant(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id, _ := auth.FromContext(ctx)
+
+	tenant, err := auth.ResolveTenant(id, firstValue(md, TenantMetadataKey))
+	switch {
+	case errors.Is(err, auth.ErrWrongTenant):
+		return ctx, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		return ctx, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return service.WithTenant(ctx, tenant), nil
+}
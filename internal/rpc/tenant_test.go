@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"metrify/internal/auth"
+	"metrify/internal/proto"
+	"metrify/internal/service"
+)
+
+func TestTenantInterceptor(t *testing.T) {
+	ms := service.NewMemStorage("", nil)
+	ms.SetQuotas(service.Quotas{Default: 1})
+
+	svc := NewMetricsService(ms)
+	interceptor := NewTenantInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: proto.Metrics_UpdateMetrics_FullMethodName}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return svc.UpdateMetrics(ctx, req.(*proto.UpdateMetricsRequest))
+	}
+
+	call := func(ctx context.Context, id string) error {
+		_, err := interceptor(ctx, newGaugeRequest(id, 1), info, handler)
+		return err
+	}
+
+	header := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "team-a"))
+	if err := call(header, "Alloc"); err != nil {
+		t.Fatalf("update with tenant metadata: %v", err)
+	}
+	if _, ok := ms.ForTenant("team-a").GetGauge("Alloc"); !ok {
+		t.Fatal("metric not stored for team-a")
+	}
+	if _, ok := ms.GetGauge("Alloc"); ok {
+		t.Fatal("metric leaked into default tenant")
+	}
+
+	resp, err := interceptor(header, newGaugeRequest("Other", 1), info, handler)
+	if err != nil {
+		t.Fatalf("update over quota: %v", err)
+	}
+	if results := resp.(*proto.UpdateMetricsResponse).GetResults(); len(results) != 1 || results[0].GetOk() {
+		t.Fatalf("expected metric over quota to be rejected, got %v", results)
+	}
+
+	bound := auth.WithIdentity(metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "team-a")),
+		&auth.Identity{Name: "b", Tenant: "team-b"})
+	if err := call(bound, "Alloc"); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected PermissionDenied for foreign tenant, got %v", err)
+	}
+
+	invalid := metadata.NewIncomingContext(context.Background(), metadata.Pairs(TenantMetadataKey, "team a"))
+	if err := call(invalid, "Alloc"); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument for invalid tenant, got %v", err)
+	}
+}
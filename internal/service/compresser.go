@@ -1,63 +1,362 @@
 package service
 
 import (
+	"bytes"
 	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"metrify/pkg/pool"
+)
+
+// Поддерживаемые Content-Encoding. deflate по RFC 9110 — это поток zlib.
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+	EncodingZstd    = "zstd"
 )
 
+// DefaultMinCompressSize — ответы короче не сжимаются: заголовки кодека съедают выигрыш.
+const DefaultMinCompressSize = 1024
+
+// zstdMaxWindow ограничивает память декодера; браузеры тоже не шлют окна больше 8 МБ.
+const zstdMaxWindow = 8 << 20
+
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// encodingPreference — порядок выбора при равных q-value.
+var encodingPreference = []string{EncodingZstd, EncodingGzip, EncodingDeflate}
+
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// encoder приводит Reset(io.Writer) кодеков к pool.Resettable.
+type encoder struct {
+	compressor
+}
+
+func (e *encoder) Reset() {
+	e.compressor.Reset(io.Discard)
+}
+
+var encoders = map[string]*pool.Pool[*encoder]{
+	EncodingGzip: mustPool(func() compressor {
+		return gzip.NewWriter(io.Discard)
+	}),
+	EncodingDeflate: mustPool(func() compressor {
+		return zlib.NewWriter(io.Discard)
+	}),
+	EncodingZstd: mustPool(func() compressor {
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(zstdMaxWindow))
+		return w
+	}),
+}
+
+func mustPool(factory func() compressor) *pool.Pool[*encoder] {
+	p, err := pool.New(func() *encoder { return &encoder{factory()} })
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+func getEncoder(encoding string, w io.Writer) (*encoder, error) {
+	p, ok := encoders[encoding]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, encoding)
+	}
+
+	enc := p.Get()
+	enc.compressor.Reset(w)
+
+	return enc, nil
+}
+
+func putEncoder(encoding string, enc *encoder) {
+	encoders[encoding].Put(enc)
+}
+
+// Compress сжимает body целиком, например тело запроса агента.
+func Compress(encoding string, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	enc, err := getEncoder(encoding, &buf)
+	if err != nil {
+		return nil, err
+	}
+	defer putEncoder(encoding, enc)
+
+	if _, err := enc.Write(body); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// NegotiateEncoding выбирает кодек по Accept-Encoding с учётом q-value.
+// При равных весах предпочтение у zstd, затем gzip и deflate.
+// Пустая строка означает ответ без сжатия.
+func NegotiateEncoding(acceptEncoding string) string {
+	weights := make(map[string]float64)
+	wildcard := -1.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(key) != "q" {
+				continue
+			}
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				parsed = 0
+			}
+			q = parsed
+		}
+
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		weights[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range encodingPreference {
+		q, ok := weights[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+
+	return best
+}
+
+// CompressWriter сжимает ответ выбранным кодеком. Пока тело короче minSize,
+// оно копится в буфере; короткие ответы, ответы с ошибкой и уже сжатые
+// данные отдаются как есть.
 type CompressWriter struct {
 	http.ResponseWriter
-	gz *gzip.Writer
+	encoding string
+	minSize  int
+
+	enc     *encoder
+	buf     []byte
+	status  int
+	decided bool
 }
 
+// NewCompressWriter сжимает ответ gzip без порога по размеру.
 func NewCompressWriter(w http.ResponseWriter) *CompressWriter {
-	gz := gzip.NewWriter(w)
+	return NewEncodingWriter(w, EncodingGzip, 0)
+}
+
+func NewEncodingWriter(w http.ResponseWriter, encoding string, minSize int) *CompressWriter {
 	return &CompressWriter{
 		ResponseWriter: w,
-		gz:             gz,
+		encoding:       encoding,
+		minSize:        minSize,
 	}
 }
 
 func (c *CompressWriter) Write(b []byte) (int, error) {
-	c.Header().Set("Content-Encoding", "gzip")
-	return c.gz.Write(b)
+	if !c.decided {
+		c.buf = append(c.buf, b...)
+		if len(c.buf) < c.minSize {
+			return len(b), nil
+		}
+		if err := c.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if c.enc != nil {
+		return c.enc.Write(b)
+	}
+
+	return c.ResponseWriter.Write(b)
 }
 
 func (c *CompressWriter) WriteHeader(statusCode int) {
-	if statusCode < 300 {
-		c.Header().Set("Content-Encoding", "gzip")
+	if c.decided {
+		c.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	c.status = statusCode
+	if statusCode >= 300 || statusCode == http.StatusNoContent {
+		_ = c.decide(false)
+		return
+	}
+	if c.minSize <= 0 {
+		_ = c.decide(true)
 	}
-	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+// decide отправляет заголовки и накопленное тело. Сжатие включается,
+// только если compress и содержимое имеет смысл сжимать.
+func (c *CompressWriter) decide(compress bool) error {
+	c.decided = true
+	h := c.Header()
+
+	if compress && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		enc, err := getEncoder(c.encoding, c.ResponseWriter)
+		if err != nil {
+			return err
+		}
+		c.enc = enc
+		h.Set("Content-Encoding", c.encoding)
+		h.Del("Content-Length")
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	if c.status != 0 {
+		c.ResponseWriter.WriteHeader(c.status)
+	}
+
+	buf := c.buf
+	c.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+
+	_, err := c.Write(buf)
+
+	return err
 }
 
 func (c *CompressWriter) Close() error {
-	return c.gz.Close()
+	if !c.decided {
+		if err := c.decide(len(c.buf) > 0 && len(c.buf) >= c.minSize); err != nil {
+			return err
+		}
+	}
+
+	if c.enc == nil {
+		return nil
+	}
+
+	err := c.enc.Close()
+	putEncoder(c.encoding, c.enc)
+	c.enc = nil
+
+	return err
+}
+
+// FlushError нужен стримам (/watch): при первом сбросе ответ сжимается
+// независимо от порога, так как его длина заранее неизвестна.
+func (c *CompressWriter) FlushError() error {
+	if !c.decided {
+		if err := c.decide(true); err != nil {
+			return err
+		}
+	}
+
+	if c.enc != nil {
+		if err := c.enc.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+func (c *CompressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// compressible отсекает форматы, которые уже сжаты.
+func compressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+
+	switch {
+	case strings.HasPrefix(contentType, "image/svg"):
+		return true
+	case strings.HasPrefix(contentType, "image/"),
+		strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "audio/"),
+		strings.HasPrefix(contentType, "application/zip"),
+		strings.HasPrefix(contentType, "application/gzip"),
+		strings.HasPrefix(contentType, "application/x-gzip"),
+		strings.HasPrefix(contentType, "application/zstd"):
+		return false
+	}
+
+	return true
 }
 
 type CompressReader struct {
 	io.ReadCloser // исходный r.Body
-	gz            *gzip.Reader
+	dec           io.ReadCloser
 }
 
+// NewCompressReader распаковывает gzip.
 func NewCompressReader(r io.ReadCloser) (*CompressReader, error) {
-	zr, err := gzip.NewReader(r)
-	if err != nil {
-		return nil, err
+	return NewDecodingReader(r, EncodingGzip)
+}
+
+// NewDecodingReader распаковывает тело с заданным Content-Encoding.
+func NewDecodingReader(r io.ReadCloser, encoding string) (*CompressReader, error) {
+	var dec io.ReadCloser
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case EncodingGzip, "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		dec = zr
+	case EncodingDeflate:
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		dec = zr
+	case EncodingZstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(zstdMaxWindow))
+		if err != nil {
+			return nil, err
+		}
+		dec = zr.IOReadCloser()
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, encoding)
 	}
 
 	return &CompressReader{
 		ReadCloser: r,
-		gz:         zr,
+		dec:        dec,
 	}, nil
 }
 
 func (z *CompressReader) Read(p []byte) (int, error) {
-	return z.gz.Read(p)
+	return z.dec.Read(p)
 }
 
 func (z *CompressReader) Close() error {
-	if err := z.gz.Close(); err != nil {
+	if err := z.dec.Close(); err != nil {
 		return err
 	}
 
@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -33,8 +34,8 @@ func TestNewCompressWriter_Basic(t *testing.T) {
 	if cw.ResponseWriter != rr {
 		t.Errorf("NewCompressWriter: ResponseWriter not set correctly")
 	}
-	if cw.gz == nil {
-		t.Fatalf("NewCompressWriter: gz writer is nil")
+	if cw.encoding != EncodingGzip {
+		t.Fatalf("NewCompressWriter: encoding = %q, want gzip", cw.encoding)
 	}
 }
 
@@ -64,6 +65,13 @@ func TestCompressWriter_WriteHeader_DoesNotSetGzipOnErrorStatus(t *testing.T) {
 	if rr.Code != http.StatusBadRequest {
 		t.Errorf("status code = %d, want %d", rr.Code, http.StatusBadRequest)
 	}
+
+	cw.Write([]byte("bad request"))
+	cw.Close()
+
+	if got := rr.Body.String(); got != "bad request" {
+		t.Errorf("body = %q, want plain %q", got, "bad request")
+	}
 }
 
 func TestCompressWriter_FullFlow_WriteAndClose(t *testing.T) {
@@ -201,7 +209,7 @@ func TestCompressReader_Close_ClosesBothReaders(t *testing.T) {
 
 	cr := &CompressReader{
 		ReadCloser: rc,
-		gz:         gzr,
+		dec:        gzr,
 	}
 
 	if err := cr.Close(); err != nil {
@@ -246,3 +254,128 @@ func TestNewCompressWriter_IntegrationWithHTTP(t *testing.T) {
 		t.Errorf("decoded body = %q, want %q", data, "ping")
 	}
 }
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "gzip", want: EncodingGzip},
+		{header: "gzip, deflate, br, zstd", want: EncodingZstd},
+		{header: "gzip;q=1.0, zstd;q=0.5", want: EncodingGzip},
+		{header: "deflate, gzip;q=0.8", want: EncodingDeflate},
+		{header: "zstd;q=0, gzip;q=0.1", want: EncodingGzip},
+		{header: "*", want: EncodingZstd},
+		{header: "*;q=0.5, zstd;q=0", want: EncodingGzip},
+		{header: "gzip;q=0", want: ""},
+		{header: "identity, br", want: ""},
+		{header: " GZIP ; q=0.9 ", want: EncodingGzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := NegotiateEncoding(tt.header); got != tt.want {
+				t.Fatalf("NegotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodingWriter_RoundTrip(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1}`), 100)
+
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate, EncodingZstd} {
+		t.Run(encoding, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			cw := NewEncodingWriter(rr, encoding, DefaultMinCompressSize)
+
+			cw.Header().Set("Content-Type", "application/json")
+			cw.WriteHeader(http.StatusOK)
+			if _, err := cw.Write(body); err != nil {
+				t.Fatalf("Write error: %v", err)
+			}
+			if err := cw.Close(); err != nil {
+				t.Fatalf("Close error: %v", err)
+			}
+
+			if got := rr.Header().Get("Content-Encoding"); got != encoding {
+				t.Fatalf("Content-Encoding = %q, want %q", got, encoding)
+			}
+			if rr.Body.Len() >= len(body) {
+				t.Fatalf("body was not compressed: %d bytes", rr.Body.Len())
+			}
+
+			cr, err := NewDecodingReader(io.NopCloser(rr.Body), encoding)
+			if err != nil {
+				t.Fatalf("NewDecodingReader error: %v", err)
+			}
+			defer cr.Close()
+
+			decoded, err := io.ReadAll(cr)
+			if err != nil {
+				t.Fatalf("ReadAll error: %v", err)
+			}
+			if !bytes.Equal(decoded, body) {
+				t.Fatal("decoded body differs")
+			}
+		})
+	}
+}
+
+func TestEncodingWriter_SkipsSmallAndCompressed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+	}{
+		{name: "below threshold", contentType: "application/json", body: []byte(`{"status":"ok"}`)},
+		{name: "already compressed", contentType: "image/png", body: bytes.Repeat([]byte("x"), 2*DefaultMinCompressSize)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			cw := NewEncodingWriter(rr, EncodingZstd, DefaultMinCompressSize)
+
+			cw.Header().Set("Content-Type", tt.contentType)
+			cw.WriteHeader(http.StatusCreated)
+			cw.Write(tt.body)
+			cw.Close()
+
+			if got := rr.Header().Get("Content-Encoding"); got != "" {
+				t.Fatalf("Content-Encoding = %q, want empty", got)
+			}
+			if rr.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d", rr.Code, http.StatusCreated)
+			}
+			if !bytes.Equal(rr.Body.Bytes(), tt.body) {
+				t.Fatalf("body = %q, want it unchanged", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := []byte("hello zstd")
+
+	compressed, err := Compress(EncodingZstd, body)
+	if err != nil {
+		t.Fatalf("Compress error: %v", err)
+	}
+
+	cr, err := NewDecodingReader(io.NopCloser(bytes.NewReader(compressed)), EncodingZstd)
+	if err != nil {
+		t.Fatalf("NewDecodingReader error: %v", err)
+	}
+	defer cr.Close()
+
+	decoded, _ := io.ReadAll(cr)
+	if string(decoded) != string(body) {
+		t.Fatalf("decoded = %q, want %q", decoded, body)
+	}
+
+	if _, err := Compress("br", body); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Fatalf("Compress(br) error = %v, want ErrUnsupportedEncoding", err)
+	}
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Значения заголовка Content-Encryption.
+const (
+	// EncryptionRSAPKCS1v15 — тело целиком зашифровано открытым ключом сервера.
+	// Ограничено размером ключа минус 11 байт, оставлено для старых агентов.
+	EncryptionRSAPKCS1v15 = "RSA-PKCS1v15"
+	// EncryptionHybrid — тело зашифровано случайным ключом AES-256-GCM,
+	// который в свою очередь зашифрован открытым ключом сервера по схеме RSA-OAEP.
+	EncryptionHybrid = "RSA-OAEP-AES256-GCM"
+)
+
+const dataKeySize = 32
+
+var (
+	ErrNoKey             = errors.New("crypto key is not configured")
+	ErrUnknownEncryption = errors.New("unknown encryption scheme")
+	errMalformedEnvelope = errors.New("malformed encrypted envelope")
+)
+
+func Encrypt(scheme string, publicKey *rsa.PublicKey, plain []byte) ([]byte, error) {
+	switch scheme {
+	case EncryptionRSAPKCS1v15:
+		return EncryptPKCS1v15(publicKey, plain)
+	case EncryptionHybrid:
+		return EncryptHybrid(publicKey, plain)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownEncryption, scheme)
+	}
+}
+
+func Decrypt(scheme string, privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	switch scheme {
+	case EncryptionRSAPKCS1v15:
+		return DecryptPKCS1v15(privateKey, data)
+	case EncryptionHybrid:
+		return DecryptHybrid(privateKey, data)
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownEncryption, scheme)
+	}
+}
+
+func EncryptPKCS1v15(publicKey *rsa.PublicKey, plain []byte) ([]byte, error) {
+	if publicKey == nil {
+		return nil, ErrNoKey
+	}
+
+	return rsa.EncryptPKCS1v15(rand.Reader, publicKey, plain)
+}
+
+func DecryptPKCS1v15(privateKey *rsa.PrivateKey, ciphertext []byte) ([]byte, error) {
+	if privateKey == nil {
+		return nil, ErrNoKey
+	}
+
+	return rsa.DecryptPKCS1v15(rand.Reader, privateKey, ciphertext)
+}
+
+// EncryptHybrid возвращает конверт вида
+// [длина ключа, 2 байта BE][RSA-OAEP(SHA-256) ключ][nonce GCM][шифртекст с тегом].
+func EncryptHybrid(publicKey *rsa.PublicKey, plain []byte) ([]byte, error) {
+	if publicKey == nil {
+		return nil, ErrNoKey
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, dataKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 2, 2+len(wrappedKey)+len(nonce)+len(plain)+gcm.Overhead())
+	binary.BigEndian.PutUint16(out, uint16(len(wrappedKey)))
+	out = append(out, wrappedKey...)
+	out = append(out, nonce...)
+
+	return gcm.Seal(out, nonce, plain, nil), nil
+}
+
+func DecryptHybrid(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
+	if privateKey == nil {
+		return nil, ErrNoKey
+	}
+
+	if len(data) < 2 {
+		return nil, errMalformedEnvelope
+	}
+
+	keyLen := int(binary.BigEndian.Uint16(data))
+	data = data[2:]
+	if keyLen != privateKey.Size() || len(data) < keyLen {
+		return nil, errMalformedEnvelope
+	}
+
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, data[:keyLen], nil)
+	if err != nil {
+		return nil, err
+	}
+	data = data[keyLen:]
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize()+gcm.Overhead() {
+		return nil, errMalformedEnvelope
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func TestHybridEncryption_RoundTrip(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	// Заметно больше, чем помещается в один блок RSA.
+	plain := bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1.5},`), 4096)
+
+	data, err := Encrypt(EncryptionHybrid, &privKey.PublicKey, plain)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	got, err := Decrypt(EncryptionHybrid, privKey, data)
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatal("decrypted data differs from plain data")
+	}
+
+	data[len(data)-1] ^= 1
+	if _, err := Decrypt(EncryptionHybrid, privKey, data); err == nil {
+		t.Fatal("expected error for tampered ciphertext")
+	}
+
+	for _, malformed := range [][]byte{nil, {0x01}, {0x01, 0x00, 0xff}} {
+		if _, err := DecryptHybrid(privKey, malformed); err == nil {
+			t.Fatalf("expected error for malformed envelope %v", malformed)
+		}
+	}
+}
+
+func TestPKCS1v15Encryption(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	data, err := Encrypt(EncryptionRSAPKCS1v15, &privKey.PublicKey, []byte("hello"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	got, err := Decrypt(EncryptionRSAPKCS1v15, privKey, data)
+	if err != nil || string(got) != "hello" {
+		t.Fatalf("Decrypt() = %q, %v", got, err)
+	}
+
+	if _, err := Encrypt(EncryptionRSAPKCS1v15, &privKey.PublicKey, make([]byte, 1024)); err == nil {
+		t.Fatal("expected error for payload larger than the key")
+	}
+}
+
+func TestEncryption_Errors(t *testing.T) {
+	if _, err := Encrypt("ROT13", nil, nil); !errors.Is(err, ErrUnknownEncryption) {
+		t.Fatalf("got %v, want ErrUnknownEncryption", err)
+	}
+	if _, err := Decrypt("ROT13", nil, nil); !errors.Is(err, ErrUnknownEncryption) {
+		t.Fatalf("got %v, want ErrUnknownEncryption", err)
+	}
+	if _, err := Encrypt(EncryptionHybrid, nil, []byte("x")); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("got %v, want ErrNoKey", err)
+	}
+	if _, err := Decrypt(EncryptionHybrid, nil, []byte("x")); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("got %v, want ErrNoKey", err)
+	}
+}
@@ -4,11 +4,30 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"strconv"
 )
 
+// ErrInvalidResponseSignature — подпись ответа сервера отсутствует или не совпадает:
+// ответ мог быть подделан по дороге.
+var ErrInvalidResponseSignature = errors.New("invalid response signature")
+
 func SignData(value []byte, key string) string {
 	hasher := hmac.New(sha256.New, []byte(key))
 	hasher.Write(value)
 
 	return hex.EncodeToString(hasher.Sum(nil))
 }
+
+// SignResponse подписывает ответ вместе с кодом статуса и nonce запроса,
+// чтобы подписанный ответ нельзя было подставить к другому запросу.
+func SignResponse(status int, nonce string, body []byte, key string) string {
+	data := make([]byte, 0, len(nonce)+len(body)+8)
+	data = strconv.AppendInt(data, int64(status), 10)
+	data = append(data, '\n')
+	data = append(data, nonce...)
+	data = append(data, '\n')
+	data = append(data, body...)
+
+	return SignData(data, key)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	models "metrify/internal/model"
+)
+
+// ErrNotReady — состояние проверки, которая ещё не была отмечена готовой.
+var ErrNotReady = errors.New("not ready")
+
+// HealthCheck возвращает nil, если компонент готов.
+type HealthCheck func(ctx context.Context) error
+
+// Health собирает проверки готовности сервера. Нулевой *Health всегда готов.
+type Health struct {
+	mu     sync.RWMutex
+	names  []string
+	checks map[string]HealthCheck
+}
+
+func NewHealth() *Health {
+	return &Health{checks: make(map[string]HealthCheck)}
+}
+
+// Add регистрирует проверку name, повторный вызов заменяет её.
+func (h *Health) Add(name string, check HealthCheck) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// Set регистрирует проверку с заранее известным результатом — для состояний,
+// которые меняет сам сервер: восстановление снимка, запуск слушателя.
+func (h *Health) Set(name string, err error) {
+	h.Add(name, func(context.Context) error {
+		return err
+	})
+}
+
+// Check выполняет все проверки по очереди.
+func (h *Health) Check(ctx context.Context) models.HealthReport {
+	report := models.HealthReport{Status: models.HealthOK}
+	if h == nil {
+		return report
+	}
+
+	h.mu.RLock()
+	names := append([]string(nil), h.names...)
+	checks := make([]HealthCheck, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.RUnlock()
+
+	report.Checks = make(map[string]models.HealthCheck, len(names))
+	for i, name := range names {
+		result := models.HealthCheck{Status: models.HealthOK}
+		if err := checks[i](ctx); err != nil {
+			result = models.HealthCheck{Status: models.HealthFail, Error: err.Error()}
+			report.Status = models.HealthFail
+		}
+		report.Checks[name] = result
+	}
+
+	return report
+}
+
+// Ready сообщает, прошли ли все проверки.
+func (h *Health) Ready(ctx context.Context) bool {
+	return h.Check(ctx).Status == models.HealthOK
+}
+
+// FileWritable проверяет, что в path можно записать снимок. Существующий файл
+// открывается без усечения, иначе в каталоге создаётся и удаляется временный файл.
+func FileWritable(path string) HealthCheck {
+	return func(context.Context) error {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err == nil {
+			return f.Close()
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		tmp, err := os.CreateTemp(filepath.Dir(path), ".healthcheck-*")
+		if err != nil {
+			return err
+		}
+		_ = tmp.Close()
+
+		return os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	models "metrify/internal/model"
+	"metrify/internal/validation"
+)
+
+func TestHealth_Check(t *testing.T) {
+	var nilHealth *Health
+	if got := nilHealth.Check(context.Background()); got.Status != models.HealthOK {
+		t.Fatalf("nil health status = %q, want ok", got.Status)
+	}
+
+	h := NewHealth()
+	h.Set("storage", ErrNotReady)
+	h.Add("database", func(context.Context) error { return nil })
+
+	report := h.Check(context.Background())
+	if report.Status != models.HealthFail {
+		t.Fatalf("status = %q, want fail", report.Status)
+	}
+	if got := report.Checks["storage"]; got.Status != models.HealthFail || got.Error != ErrNotReady.Error() {
+		t.Fatalf("storage check = %+v", got)
+	}
+	if got := report.Checks["database"]; got.Status != models.HealthOK {
+		t.Fatalf("database check = %+v", got)
+	}
+
+	h.Set("storage", nil)
+	if !h.Ready(context.Background()) {
+		t.Fatalf("health must be ready after storage is set")
+	}
+	if len(h.Check(context.Background()).Checks) != 2 {
+		t.Fatalf("Set must replace the existing check")
+	}
+}
+
+func TestRestoreStatus_Readiness(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.json")
+
+	if err := os.WriteFile(path, []byte(`{"gauges": {"load": 0.5, "bad name": 1, "__sys": 2}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ms := NewMemStorage(path, nil)
+	ms.SetValidator(validation.New("__"))
+
+	err := ms.ReadFromFile(path)
+	if !errors.Is(err, ErrSkippedInvalid) {
+		t.Fatalf("ReadFromFile() error = %v, want ErrSkippedInvalid", err)
+	}
+
+	h := NewHealth()
+	h.Set("storage", RestoreStatus(err))
+	if !h.Ready(context.Background()) {
+		t.Fatalf("skipped invalid metric must not fail readiness: %+v", h.Check(context.Background()))
+	}
+	if _, ok := ms.GetGauge("load"); !ok {
+		t.Fatalf("valid gauge must be restored")
+	}
+
+	// Метрика с зарезервированным префиксом переживает следующий сброс снимка.
+	if err := ms.FlushToFile(); err != nil {
+		t.Fatalf("FlushToFile() error = %v", err)
+	}
+	restored := NewMemStorage(path, nil)
+	restored.SetValidator(validation.New("__"))
+	if err := restored.ReadFromFile(path); err != nil {
+		t.Fatalf("ReadFromFile() after flush error = %v", err)
+	}
+	if v, ok := restored.GetGauge("__sys"); !ok || v != 2 {
+		t.Fatalf("gauge with reserved prefix must survive the dump, got %v, %v", v, ok)
+	}
+
+	if err := RestoreStatus(NewMemStorage(path, nil).ReadFromFile(filepath.Join(dir, "missing.json"))); err != nil {
+		t.Fatalf("missing snapshot must not fail readiness: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"gauges": `), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreStatus(NewMemStorage(path, nil).ReadFromFile(path)); err == nil {
+		t.Fatalf("corrupted snapshot must fail readiness")
+	}
+}
+
+func TestFileWritable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "metrics.json")
+
+	if err := FileWritable(path)(context.Background()); err != nil {
+		t.Fatalf("missing file in writable dir: %v", err)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("check must not create the snapshot file")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"gauges":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := FileWritable(path)(context.Background()); err != nil {
+		t.Fatalf("existing file: %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"gauges":{}}` {
+		t.Fatalf("check must not truncate the snapshot, got %q", data)
+	}
+
+	if err := FileWritable(filepath.Join(dir, "missing", "metrics.json"))(context.Background()); err == nil {
+		t.Fatalf("expected error for missing directory")
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	models "metrify/internal/model"
+)
+
+// DefaultHistorySize — сколько последних значений каждой метрики хранится для дашборда.
+const DefaultHistorySize = 60
+
+type metricKey struct {
+	tenant string
+	mType  string
+	name   string
+}
+
+// History хранит последние значения метрик в кольцевых буферах фиксированного размера.
+// Нулевой *History ничего не хранит.
+type History struct {
+	size int
+	now  func() time.Time
+
+	mu     sync.Mutex
+	series map[metricKey]*ring
+}
+
+type ring struct {
+	points []models.Point
+	next   int
+}
+
+// NewHistory возвращает nil, если size не положителен.
+func NewHistory(size int) *History {
+	if size <= 0 {
+		return nil
+	}
+
+	return &History{
+		size:   size,
+		now:    time.Now,
+		series: make(map[metricKey]*ring),
+	}
+}
+
+func (h *History) Record(tenant, mType, name string, value float64) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := metricKey{tenant, mType, name}
+	r, ok := h.series[key]
+	if !ok {
+		r = &ring{points: make([]models.Point, 0, h.size)}
+		h.series[key] = r
+	}
+
+	p := models.Point{T: h.now().UnixMilli(), V: value}
+	if len(r.points) < h.size {
+		r.points = append(r.points, p)
+		return
+	}
+
+	r.points[r.next] = p
+	r.next = (r.next + 1) % h.size
+}
+
+// Series возвращает значения метрики от старых к новым.
+func (h *History) Series(tenant, mType, name string) []models.Point {
+	if h == nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r, ok := h.series[metricKey{tenant, mType, name}]
+	if !ok {
+		return nil
+	}
+
+	points := make([]models.Point, 0, len(r.points))
+	points = append(points, r.points[r.next:]...)
+	points = append(points, r.points[:r.next]...)
+
+	return points
+}
+
+func (h *History) Forget(tenant, mType, name string) {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.series, metricKey{tenant, mType, name})
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	models "metrify/internal/model"
+)
+
+func TestHistory_Ring(t *testing.T) {
+	h := NewHistory(3)
+	now := time.UnixMilli(1000)
+	h.now = func() time.Time { return now }
+
+	for i := 1; i <= 5; i++ {
+		h.Record(DefaultTenant, models.Gauge, "Alloc", float64(i))
+		now = now.Add(time.Second)
+	}
+
+	got := h.Series(DefaultTenant, models.Gauge, "Alloc")
+	want := []models.Point{{T: 3000, V: 3}, {T: 4000, V: 4}, {T: 5000, V: 5}}
+	if len(got) != len(want) {
+		t.Fatalf("Series() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Series() = %v, want %v", got, want)
+		}
+	}
+
+	if s := h.Series("team", models.Gauge, "Alloc"); s != nil {
+		t.Fatalf("series of another tenant = %v, want nil", s)
+	}
+
+	h.Forget(DefaultTenant, models.Gauge, "Alloc")
+	if s := h.Series(DefaultTenant, models.Gauge, "Alloc"); s != nil {
+		t.Fatalf("series after Forget = %v, want nil", s)
+	}
+}
+
+func TestHistory_Disabled(t *testing.T) {
+	h := NewHistory(0)
+	if h != nil {
+		t.Fatal("NewHistory(0) must return nil")
+	}
+
+	h.Record(DefaultTenant, models.Gauge, "Alloc", 1)
+	if s := h.Series(DefaultTenant, models.Gauge, "Alloc"); s != nil {
+		t.Fatalf("nil history returned %v", s)
+	}
+}
+
+func TestMemStorage_History(t *testing.T) {
+	ms := NewMemStorage("", nil)
+	ms.SetHistory(NewHistory(10))
+
+	ms.UpdateCounter("hits", 2)
+	ms.UpdateCounter("hits", 3)
+
+	got := ms.History(models.Counter, "hits")
+	if len(got) != 2 || got[0].V != 2 || got[1].V != 5 {
+		t.Fatalf("counter history = %v, want running totals 2 and 5", got)
+	}
+
+	ms.Delete(models.Counter, "hits")
+	if got := ms.History(models.Counter, "hits"); got != nil {
+		t.Fatalf("history of deleted metric = %v, want nil", got)
+	}
+}
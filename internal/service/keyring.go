@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// KeyIDHeader указывает, каким ключом из связки подписан запрос.
+const KeyIDHeader = "X-Key-ID"
+
+const keyRingReloadInterval = 10 * time.Second
+
+var (
+	ErrUnknownKey = errors.New("unknown signing key")
+	ErrKeyExpired = errors.New("signing key has expired")
+)
+
+// SigningKey — один ключ HMAC в связке. Нулевой ExpiresAt означает бессрочный ключ.
+type SigningKey struct {
+	ID        string    `json:"id"`
+	Secret    string    `json:"secret"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type keyRingFile struct {
+	Keys []SigningKey `json:"keys"`
+}
+
+// KeyRing хранит несколько действующих ключей подписи, чтобы KEY можно было
+// менять постепенно: новый ключ добавляется в файл, агенты переходят на него,
+// старый удаляется или истекает. Файл перечитывается при изменении, как в CertReloader.
+// Запросы без X-Key-ID проверяются ключом по умолчанию (настройка KEY).
+type KeyRing struct {
+	path       string
+	defaultKey string
+	interval   time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]SigningKey
+	modTime time.Time
+	checked time.Time
+}
+
+// NewKeyRing загружает связку из path. Пустой path означает связку из одного defaultKey.
+func NewKeyRing(path, defaultKey string) (*KeyRing, error) {
+	k := &KeyRing{
+		path:       path,
+		defaultKey: defaultKey,
+		interval:   keyRingReloadInterval,
+		now:        time.Now,
+		keys:       map[string]SigningKey{},
+	}
+
+	if path == "" {
+		return k, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := k.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// NewStaticKeyRing возвращает связку из одного ключа по умолчанию.
+func NewStaticKeyRing(defaultKey string) *KeyRing {
+	k, _ := NewKeyRing("", defaultKey)
+
+	return k
+}
+
+// Enabled сообщает, настроен ли хотя бы один ключ.
+func (k *KeyRing) Enabled() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.reload()
+
+	return k.defaultKey != "" || len(k.keys) > 0
+}
+
+// Lookup возвращает секрет ключа id; пустой id означает ключ по умолчанию.
+// Без ключа по умолчанию запрос без X-Key-ID не проверить: пустой секрет
+// знает любой, поэтому возвращается ErrUnknownKey.
+func (k *KeyRing) Lookup(id string) (string, error) {
+	if id == "" {
+		if k.defaultKey == "" {
+			return "", fmt.Errorf("%w: %s is required without a default key", ErrUnknownKey, KeyIDHeader)
+		}
+		return k.defaultKey, nil
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.reload()
+
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+
+	if !key.ExpiresAt.IsZero() && k.now().After(key.ExpiresAt) {
+		return "", fmt.Errorf("%w: %q", ErrKeyExpired, id)
+	}
+
+	return key.Secret, nil
+}
+
+func (k *KeyRing) reload() {
+	if k.path == "" || k.now().Sub(k.checked) < k.interval {
+		return
+	}
+	k.checked = k.now()
+
+	info, err := os.Stat(k.path)
+	if err != nil || !info.ModTime().After(k.modTime) {
+		return
+	}
+
+	// Файл может быть записан не до конца: при ошибке остаёмся на старых ключах.
+	_ = k.load(info.ModTime())
+}
+
+func (k *KeyRing) load(modTime time.Time) error {
+	file, err := FromFile[keyRingFile](k.path)
+	if err != nil {
+		return fmt.Errorf("load key ring %s: %w", k.path, err)
+	}
+
+	keys := make(map[string]SigningKey, len(file.Keys))
+	for _, key := range file.Keys {
+		if key.ID == "" || key.Secret == "" {
+			return fmt.Errorf("load key ring %s: key id and secret are required", k.path)
+		}
+		keys[key.ID] = key
+	}
+
+	k.keys = keys
+	k.modTime = modTime
+	k.checked = k.now()
+
+	return nil
+}
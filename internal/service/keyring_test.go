@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeKeyRing(t *testing.T, path, data string, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(path, modTime, modTime)
+}
+
+func TestKeyRing_Lookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyRing(t, path, `{"keys":[
+		{"id":"2026-01","secret":"old","expires_at":"2026-02-01T00:00:00Z"},
+		{"id":"2026-02","secret":"new"}
+	]}`, time.Now())
+
+	k, err := NewKeyRing(path, "default")
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+	k.now = func() time.Time { return time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		id      string
+		want    string
+		wantErr error
+	}{
+		{id: "", want: "default"},
+		{id: "2026-01", want: "old"},
+		{id: "2026-02", want: "new"},
+		{id: "missing", wantErr: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		got, err := k.Lookup(tt.id)
+		if !errors.Is(err, tt.wantErr) || got != tt.want {
+			t.Fatalf("Lookup(%q) = %q, %v; want %q, %v", tt.id, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	k.now = func() time.Time { return time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC) }
+	if _, err := k.Lookup("2026-01"); !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("Lookup() for expired key = %v, want ErrKeyExpired", err)
+	}
+	if got, err := k.Lookup("2026-02"); err != nil || got != "new" {
+		t.Fatalf("Lookup() = %q, %v", got, err)
+	}
+}
+
+func TestKeyRing_LookupWithoutDefaultKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyRing(t, path, `{"keys":[{"id":"2026-02","secret":"new"}]}`, time.Now())
+
+	k, err := NewKeyRing(path, "")
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+
+	// Иначе запрос без X-Key-ID проходил бы проверку подписью с пустым ключом.
+	if got, err := k.Lookup(""); !errors.Is(err, ErrUnknownKey) || got != "" {
+		t.Fatalf("Lookup(\"\") = %q, %v; want ErrUnknownKey", got, err)
+	}
+	if got, err := k.Lookup("2026-02"); err != nil || got != "new" {
+		t.Fatalf("Lookup() = %q, %v", got, err)
+	}
+}
+
+func TestKeyRing_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	start := time.Now()
+	writeKeyRing(t, path, `{"keys":[{"id":"a","secret":"1"}]}`, start)
+
+	k, err := NewKeyRing(path, "")
+	if err != nil {
+		t.Fatalf("NewKeyRing() error = %v", err)
+	}
+	k.interval = 0
+
+	writeKeyRing(t, path, `{"keys":[{"id":"b","secret":"2"}]}`, start.Add(time.Minute))
+
+	if _, err := k.Lookup("a"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected removed key to be unknown, got %v", err)
+	}
+	if got, _ := k.Lookup("b"); got != "2" {
+		t.Fatalf("Lookup(b) = %q, want 2", got)
+	}
+
+	// Битый файл не должен сбрасывать уже загруженные ключи.
+	writeKeyRing(t, path, `{"keys":[`, start.Add(2*time.Minute))
+
+	if got, _ := k.Lookup("b"); got != "2" {
+		t.Fatalf("Lookup(b) after broken write = %q, want 2", got)
+	}
+}
+
+func TestKeyRing_Errors(t *testing.T) {
+	if _, err := NewKeyRing("missing.json", ""); err == nil {
+		t.Error("expected error for missing file")
+	}
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	writeKeyRing(t, path, `{"keys":[{"id":"a"}]}`, time.Now())
+	if _, err := NewKeyRing(path, ""); err == nil {
+		t.Error("expected error for key without secret")
+	}
+
+	if NewStaticKeyRing("").Enabled() {
+		t.Error("expected empty key ring to be disabled")
+	}
+	if !NewStaticKeyRing("key").Enabled() {
+		t.Error("expected key ring with default key to be enabled")
+	}
+}
@@ -40,3 +40,7 @@ func (lrw *LoggingResponseWriter) Write(b []byte) (int, error) {
 	lrw.ResponseData.Size += size
 	return size, err
 }
+
+func (lrw *LoggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.ResponseWriter
+}
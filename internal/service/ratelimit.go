@@ -0,0 +1,100 @@
+package service
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const rateLimiterPruneInterval = time.Minute
+
+// Ограничения запросов по умолчанию. Агент отправляет несколько десятков метрик
+// за раз, так что запас большой. Импорт читается потоком, ему можно больше.
+const (
+	DefaultMaxBodySize   = 10 << 20
+	DefaultMaxBatchSize  = 10000
+	DefaultMaxImportSize = 1 << 30
+	DefaultRateBurst     = 10
+)
+
+// RateLimiter — token bucket на каждого клиента (адрес или имя токена).
+// Ведро вмещает burst запросов и пополняется со скоростью rate запросов в секунду.
+// Нулевой *RateLimiter пропускает все запросы.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter возвращает nil, если rate не положителен. Burst меньше 1 считается равным 1.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		rate:    rate,
+		burst:   math.Max(1, float64(burst)),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow забирает токен из ведра клиента key. Если ведро пусто, возвращает
+// время, через которое появится следующий токен.
+func (l *RateLimiter) Allow(key string) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+
+	b.tokens--
+
+	return 0, true
+}
+
+// prune удаляет вёдра, которые успели наполниться: они не отличаются от новых.
+func (l *RateLimiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < rateLimiterPruneInterval {
+		return
+	}
+	l.pruned = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RetryAfterSeconds округляет ожидание вверх до целых секунд для заголовка Retry-After.
+func RetryAfterSeconds(wait time.Duration) int {
+	return max(1, int(math.Ceil(wait.Seconds())))
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewRateLimiter(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, ok := l.Allow("a"); !ok {
+			t.Fatalf("request %d within burst was rejected", i)
+		}
+	}
+
+	wait, ok := l.Allow("a")
+	if ok {
+		t.Fatal("request over burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Fatalf("wait = %v, want 500ms", wait)
+	}
+	if RetryAfterSeconds(wait) != 1 {
+		t.Fatalf("RetryAfterSeconds(%v) = %d, want 1", wait, RetryAfterSeconds(wait))
+	}
+
+	if _, ok := l.Allow("b"); !ok {
+		t.Fatal("other client must have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if _, ok := l.Allow("a"); !ok {
+		t.Fatal("token was not refilled")
+	}
+	if _, ok := l.Allow("a"); ok {
+		t.Fatal("only one token should have been refilled")
+	}
+}
+
+func TestRateLimiter_Prune(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := NewRateLimiter(1, 1)
+	l.now = func() time.Time { return now }
+
+	l.Allow("a")
+	now = now.Add(2 * rateLimiterPruneInterval)
+	l.Allow("b")
+
+	if _, ok := l.buckets["a"]; ok {
+		t.Fatal("refilled bucket was not pruned")
+	}
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	l := NewRateLimiter(0, 10)
+	if l != nil {
+		t.Fatal("NewRateLimiter(0) must return nil")
+	}
+	if _, ok := l.Allow("a"); !ok {
+		t.Fatal("nil limiter must allow requests")
+	}
+}
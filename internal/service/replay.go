@@ -0,0 +1,134 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Заголовки, которые вместе с методом, путём и строкой запроса входят в подпись HashSHA256.
+const (
+	TimestampHeader = "X-Timestamp"
+	NonceHeader     = "X-Nonce"
+)
+
+const DefaultReplayWindow = 5 * time.Minute
+
+var (
+	ErrUnsignedRequest   = errors.New("request signature is required")
+	ErrUnsignedTimestamp = errors.New("request timestamp and nonce are required")
+	ErrInvalidTimestamp  = errors.New("invalid request timestamp")
+	ErrStaleRequest      = errors.New("request timestamp is outside the allowed window")
+	ErrReplayedRequest   = errors.New("request nonce has already been used")
+)
+
+// SignRequest подписывает запрос вместе с методом, путём и строкой запроса
+// (uri — url.URL.RequestURI), временем и nonce, чтобы перехваченную подпись
+// нельзя было повторить или перенести на другой эндпоинт или другие параметры.
+func SignRequest(method, uri, timestamp, nonce string, body []byte, key string) string {
+	data := make([]byte, 0, len(method)+len(uri)+len(timestamp)+len(nonce)+len(body)+4)
+	data = append(data, method...)
+	data = append(data, '\n')
+	data = append(data, uri...)
+	data = append(data, '\n')
+	data = append(data, timestamp...)
+	data = append(data, '\n')
+	data = append(data, nonce...)
+	data = append(data, '\n')
+	data = append(data, body...)
+
+	return SignData(data, key)
+}
+
+// NewNonce возвращает случайную строку для заголовка X-Nonce.
+func NewNonce() string {
+	buf := make([]byte, 16)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
+
+// FormatTimestamp возвращает значение заголовка X-Timestamp — unix-время в секундах.
+func FormatTimestamp(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// ReplayGuard отклоняет запросы со временем вне окна window и запоминает
+// nonce на время окна, чтобы один и тот же запрос нельзя было принять дважды.
+// В строгом режиме не принимаются запросы без подписи и со старой подписью только по телу.
+type ReplayGuard struct {
+	window time.Duration
+	strict bool
+	now    func() time.Time
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastPrune time.Time
+}
+
+func NewReplayGuard(window time.Duration, strict bool) *ReplayGuard {
+	if window <= 0 {
+		window = DefaultReplayWindow
+	}
+
+	return &ReplayGuard{
+		window: window,
+		strict: strict,
+		now:    time.Now,
+		nonces: make(map[string]time.Time),
+	}
+}
+
+// Strict сообщает, нужно ли отклонять запросы без подписи и подписи без времени и nonce.
+func (g *ReplayGuard) Strict() bool {
+	return g.strict
+}
+
+// Check проверяет время запроса и регистрирует nonce.
+// Вызывается только после проверки подписи, иначе кэш можно забить чужими nonce.
+func (g *ReplayGuard) Check(timestamp, nonce string) error {
+	if timestamp == "" || nonce == "" {
+		return ErrUnsignedTimestamp
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	now := g.now()
+	ts := time.Unix(sec, 0)
+	if ts.Before(now.Add(-g.window)) || ts.After(now.Add(g.window)) {
+		return ErrStaleRequest
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.prune(now)
+
+	if _, ok := g.nonces[nonce]; ok {
+		return ErrReplayedRequest
+	}
+
+	// Nonce достаточно помнить, пока запрос с тем же временем проходит по окну.
+	g.nonces[nonce] = ts.Add(g.window)
+
+	return nil
+}
+
+func (g *ReplayGuard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < time.Second {
+		return
+	}
+	g.lastPrune = now
+
+	for nonce, expires := range g.nonces {
+		if now.After(expires) {
+			delete(g.nonces, nonce)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReplayGuard_Check(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	g := NewReplayGuard(time.Minute, false)
+	g.now = func() time.Time { return now }
+
+	ts := FormatTimestamp(now)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		nonce     string
+		want      error
+	}{
+		{name: "fresh", timestamp: ts, nonce: "a"},
+		{name: "replayed nonce", timestamp: ts, nonce: "a", want: ErrReplayedRequest},
+		{name: "other nonce", timestamp: ts, nonce: "b"},
+		{name: "too old", timestamp: FormatTimestamp(now.Add(-2 * time.Minute)), nonce: "c", want: ErrStaleRequest},
+		{name: "from the future", timestamp: FormatTimestamp(now.Add(2 * time.Minute)), nonce: "d", want: ErrStaleRequest},
+		{name: "inside window", timestamp: FormatTimestamp(now.Add(-30 * time.Second)), nonce: "e"},
+		{name: "not a number", timestamp: "yesterday", nonce: "f", want: ErrInvalidTimestamp},
+		{name: "no nonce", timestamp: ts, nonce: "", want: ErrUnsignedTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := g.Check(tt.timestamp, tt.nonce); !errors.Is(err, tt.want) {
+				t.Fatalf("Check() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayGuard_ForgetsExpiredNonces(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	g := NewReplayGuard(time.Minute, false)
+	g.now = func() time.Time { return now }
+
+	if err := g.Check(FormatTimestamp(now), "a"); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := g.Check(FormatTimestamp(now), "b"); err != nil {
+		t.Fatalf("Check() = %v", err)
+	}
+
+	if len(g.nonces) != 1 {
+		t.Fatalf("expected expired nonce to be pruned, got %d nonces", len(g.nonces))
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	base := SignRequest("POST", "/updates", "1", "n", []byte("body"), "key")
+
+	variants := []string{
+		SignRequest("GET", "/updates", "1", "n", []byte("body"), "key"),
+		SignRequest("POST", "/update", "1", "n", []byte("body"), "key"),
+		SignRequest("POST", "/updates?x=1", "1", "n", []byte("body"), "key"),
+		SignRequest("POST", "/updates", "2", "n", []byte("body"), "key"),
+		SignRequest("POST", "/updates", "1", "m", []byte("body"), "key"),
+		SignRequest("POST", "/updates", "1", "n", []byte("other"), "key"),
+		SignRequest("POST", "/updates", "1", "n", []byte("body"), "other"),
+	}
+
+	for i, v := range variants {
+		if v == base {
+			t.Fatalf("variant %d has the same signature", i)
+		}
+	}
+
+	if SignRequest("POST", "/updates", "1", "n", []byte("body"), "key") != base {
+		t.Fatal("expected deterministic signature")
+	}
+}
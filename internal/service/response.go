@@ -0,0 +1,34 @@
+package service
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// BufferedResponseWriter копит ответ в памяти, чтобы его можно было
+// обработать целиком (например, подписать) до отправки клиенту.
+type BufferedResponseWriter struct {
+	http.ResponseWriter
+	Status int
+	Body   bytes.Buffer
+}
+
+func NewBufferedResponseWriter(w http.ResponseWriter) *BufferedResponseWriter {
+	return &BufferedResponseWriter{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (bw *BufferedResponseWriter) WriteHeader(code int) {
+	bw.Status = code
+}
+
+func (bw *BufferedResponseWriter) Write(b []byte) (int, error) {
+	return bw.Body.Write(b)
+}
+
+// Send отправляет накопленный ответ в исходный ResponseWriter.
+func (bw *BufferedResponseWriter) Send() error {
+	bw.ResponseWriter.WriteHeader(bw.Status)
+	_, err := bw.ResponseWriter.Write(bw.Body.Bytes())
+
+	return err
+}
@@ -3,28 +3,60 @@ package service
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	models "metrify/internal/model"
+	"metrify/internal/validation"
 	"os"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 )
 
+// ErrSkippedInvalid — при восстановлении часть метрик не прошла проверку и
+// отброшена; остальные метрики загружены.
+var ErrSkippedInvalid = errors.New("skipped invalid metrics on restore")
+
+// MemStorage хранит метрики с разбиением по арендаторам. Метрики DefaultTenant
+// лежат в gauges/counters, остальных — в tenants.
 type MemStorage struct {
-	gauges   map[string]float64
-	counters map[string]int64
-	mu       sync.RWMutex
-	filepath string
-	db       *sql.DB
-	maxRetry int
+	gauges    map[string]float64
+	counters  map[string]int64
+	tenants   map[string]*tenantMetrics
+	mu        sync.RWMutex
+	filepath  string
+	db        *sql.DB
+	maxRetry  int
+	hub       *Hub
+	hubs      map[string]*Hub
+	quotas    Quotas
+	validator *validation.Validator
+	history   *History
+	silences  SnapshotPart
+
+	// seq, versions, lists и base — версии для условных запросов, см. Version.
+	seq      uint64
+	versions map[metricKey]Version
+	lists    map[string]Version
+	base     Version
+}
+
+type tenantMetrics struct {
+	Gauges   map[string]float64 `json:"gauges"`
+	Counters map[string]int64   `json:"counters"`
 }
 
 func NewMemStorage(filepath string, db *sql.DB) *MemStorage {
 	return &MemStorage{
 		gauges:   make(map[string]float64),
 		counters: make(map[string]int64),
+		tenants:  make(map[string]*tenantMetrics),
 		filepath: filepath,
 		db:       db,
 		maxRetry: 3,
+		hub:      NewHub(defaultWatchBuffer),
+		hubs:     make(map[string]*Hub),
 	}
 }
 
@@ -34,80 +66,373 @@ type Storage interface {
 	UpdateGauge(name string, value float64) error
 	UpdateCounter(name string, delta int64) error
 	FlushToFile() error
+	Watch(filter WatchFilter) (*Subscription, error)
+	// List возвращает все метрики, отсортированные по имени и типу.
+	List() []models.Metrics
+	// Delete удаляет метрику и сообщает, была ли она.
+	Delete(mType, name string) bool
+	// History возвращает последние значения метрики от старых к новым.
+	History(mType, name string) []models.Point
+	// Version возвращает версию метрики или false, если метрики нет.
+	// Версию стоит читать до значения: так ответ не окажется новее своего ETag.
+	Version(mType, name string) (Version, bool)
+	// ListVersion меняется при любом обновлении или удалении метрик арендатора.
+	ListVersion() Version
+	// ForTenant возвращает хранилище, которое видит только метрики арендатора.
+	ForTenant(tenant string) Storage
+}
+
+// SetQuotas задаёт ограничения на число метрик арендаторов.
+func (ms *MemStorage) SetQuotas(q Quotas) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.quotas = q
+}
+
+// SnapshotPart — данные, которые сохраняются в файле снимка рядом с метриками.
+type SnapshotPart interface {
+	json.Marshaler
+	json.Unmarshaler
+}
+
+// SetSilences подключает окна обслуживания к снимку хранилища. Вызывается
+// до ReadFromFile, чтобы они восстановились вместе с метриками.
+func (ms *MemStorage) SetSilences(p SnapshotPart) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.silences = p
+}
+
+// SetHistory включает запись последних значений метрик.
+func (ms *MemStorage) SetHistory(h *History) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.history = h
+}
+
+func (ms *MemStorage) ForTenant(tenant string) Storage {
+	if tenant == "" || tenant == DefaultTenant {
+		return ms
+	}
+
+	return &tenantStorage{ms: ms, tenant: tenant}
 }
 
 func (ms *MemStorage) GetCounter(key string) (int64, bool) {
+	return ms.getCounter(DefaultTenant, key)
+}
+
+func (ms *MemStorage) GetGauge(key string) (float64, bool) {
+	return ms.getGauge(DefaultTenant, key)
+}
+
+func (ms *MemStorage) UpdateGauge(name string, value float64) error {
+	return ms.updateGauge(DefaultTenant, name, value)
+}
+
+func (ms *MemStorage) UpdateCounter(name string, delta int64) error {
+	return ms.updateCounter(DefaultTenant, name, delta)
+}
+
+// Watch подписывает на изменения метрик, подходящих под фильтр.
+// Для счётчиков в Delta передаётся накопленное значение, как в ответе /value/.
+func (ms *MemStorage) Watch(filter WatchFilter) (*Subscription, error) {
+	return ms.watch(DefaultTenant, filter)
+}
+
+func (ms *MemStorage) List() []models.Metrics {
+	return ms.list(DefaultTenant)
+}
+
+// Delete удаляет метрику из памяти. Таблица metrics — журнал обновлений,
+// поэтому строки в базе остаются.
+func (ms *MemStorage) Delete(mType, name string) bool {
+	return ms.delete(DefaultTenant, mType, name)
+}
+
+func (ms *MemStorage) History(mType, name string) []models.Point {
+	return ms.tenantHistory(DefaultTenant, mType, name)
+}
+
+func (ms *MemStorage) Version(mType, name string) (Version, bool) {
+	return ms.version(DefaultTenant, mType, name)
+}
+
+func (ms *MemStorage) ListVersion() Version {
+	return ms.listVersion(DefaultTenant)
+}
+
+// metrics возвращает карты арендатора; при create отсутствующий арендатор создаётся.
+// Вызывается под ms.mu.
+func (ms *MemStorage) metrics(tenant string, create bool) *tenantMetrics {
+	if tenant == DefaultTenant {
+		if create && ms.gauges == nil {
+			ms.gauges = make(map[string]float64)
+		}
+		if create && ms.counters == nil {
+			ms.counters = make(map[string]int64)
+		}
+
+		return &tenantMetrics{Gauges: ms.gauges, Counters: ms.counters}
+	}
+
+	m, ok := ms.tenants[tenant]
+	if !ok && create {
+		if ms.tenants == nil {
+			ms.tenants = make(map[string]*tenantMetrics)
+		}
+		m = &tenantMetrics{Gauges: make(map[string]float64), Counters: make(map[string]int64)}
+		ms.tenants[tenant] = m
+	}
+
+	return m
+}
+
+func (ms *MemStorage) getCounter(tenant, key string) (int64, bool) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	val, ok := ms.counters[key]
+	m := ms.metrics(tenant, false)
+	if m == nil {
+		return 0, false
+	}
+
+	val, ok := m.Counters[key]
 
 	return val, ok
 }
 
-func (ms *MemStorage) GetGauge(key string) (float64, bool) {
+func (ms *MemStorage) getGauge(tenant, key string) (float64, bool) {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	val, ok := ms.gauges[key]
+	m := ms.metrics(tenant, false)
+	if m == nil {
+		return 0, false
+	}
+
+	val, ok := m.Gauges[key]
 
 	return val, ok
 }
 
-func (ms *MemStorage) UpdateGauge(name string, value float64) error {
-	ms.mu.Lock()
-	defer ms.mu.Unlock()
+func (ms *MemStorage) list(tenant string) []models.Metrics {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	m := ms.metrics(tenant, false)
+	if m == nil {
+		return nil
+	}
 
-	ms.gauges[name] = value
+	result := make([]models.Metrics, 0, len(m.Gauges)+len(m.Counters))
+	for name, value := range m.Gauges {
+		result = append(result, models.Metrics{ID: name, MType: models.Gauge, Value: &value})
+	}
+	for name, delta := range m.Counters {
+		result = append(result, models.Metrics{ID: name, MType: models.Counter, Delta: &delta})
+	}
 
-	return ms.saveDB(name, strconv.FormatFloat(value, 'f', -1, 64))
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ID != result[j].ID {
+			return result[i].ID < result[j].ID
+		}
+		return result[i].MType < result[j].MType
+	})
+
+	return result
 }
 
-func (ms *MemStorage) UpdateCounter(name string, delta int64) error {
+func (ms *MemStorage) delete(tenant, mType, name string) bool {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	ms.counters[name] += delta
+	m := ms.metrics(tenant, false)
+	if m == nil {
+		return false
+	}
+
+	switch mType {
+	case models.Gauge:
+		if _, ok := m.Gauges[name]; ok {
+			delete(m.Gauges, name)
+			ms.history.Forget(tenant, mType, name)
+			ms.forget(tenant, mType, name)
+			return true
+		}
+	case models.Counter:
+		if _, ok := m.Counters[name]; ok {
+			delete(m.Counters, name)
+			ms.history.Forget(tenant, mType, name)
+			ms.forget(tenant, mType, name)
+			return true
+		}
+	}
 
-	return ms.saveDB(name, strconv.FormatInt(delta, 10))
+	return false
 }
 
-func (ms *MemStorage) UnmarshalJSON(data []byte) error {
-	type dto struct {
-		Gauges   map[string]float64 `json:"gauges"`
-		Counters map[string]int64   `json:"counters"`
+// checkQuota не даёт арендатору завести новую метрику сверх квоты.
+// Обновление уже существующих метрик разрешено всегда.
+func (ms *MemStorage) checkQuota(tenant string, m *tenantMetrics, exists bool) error {
+	limit := ms.quotas.Limit(tenant)
+	if exists || limit <= 0 {
+		return nil
+	}
+
+	if len(m.Gauges)+len(m.Counters) >= limit {
+		return fmt.Errorf("%w: tenant %q is limited to %d metrics", ErrQuotaExceeded, tenant, limit)
+	}
+
+	return nil
+}
+
+func (ms *MemStorage) updateGauge(tenant, name string, value float64) error {
+	ms.mu.Lock()
+	m := ms.metrics(tenant, true)
+	_, exists := m.Gauges[name]
+	if err := ms.checkQuota(tenant, m, exists); err != nil {
+		ms.mu.Unlock()
+		return err
+	}
+	m.Gauges[name] = value
+	ms.history.Record(tenant, models.Gauge, name, value)
+	ms.touch(tenant, models.Gauge, name)
+	err := ms.saveDB(tenant, name, strconv.FormatFloat(value, 'f', -1, 64))
+	// Publish не блокируется; под ms.mu подписчики получают значения в порядке записи.
+	ms.tenantHub(tenant, false).Publish(models.Metrics{ID: name, MType: models.Gauge, Value: &value})
+	ms.mu.Unlock()
+
+	return err
+}
+
+func (ms *MemStorage) updateCounter(tenant, name string, delta int64) error {
+	ms.mu.Lock()
+	m := ms.metrics(tenant, true)
+	_, exists := m.Counters[name]
+	if err := ms.checkQuota(tenant, m, exists); err != nil {
+		ms.mu.Unlock()
+		return err
 	}
+	m.Counters[name] += delta
+	total := m.Counters[name]
+	ms.history.Record(tenant, models.Counter, name, float64(total))
+	ms.touch(tenant, models.Counter, name)
+	err := ms.saveDB(tenant, name, strconv.FormatInt(delta, 10))
+	ms.tenantHub(tenant, false).Publish(models.Metrics{ID: name, MType: models.Counter, Delta: &total})
+	ms.mu.Unlock()
+
+	return err
+}
 
+func (ms *MemStorage) tenantHistory(tenant, mType, name string) []models.Point {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	result := dto{}
+	return ms.history.Series(tenant, mType, name)
+}
+
+// tenantHub возвращает хаб подписок арендатора. Хабы остальных арендаторов
+// создаются при первой подписке. Вызывается под ms.mu.
+func (ms *MemStorage) tenantHub(tenant string, create bool) *Hub {
+	if tenant == DefaultTenant {
+		if ms.hub == nil && create {
+			ms.hub = NewHub(defaultWatchBuffer)
+		}
+		return ms.hub
+	}
+
+	h, ok := ms.hubs[tenant]
+	if !ok && create {
+		if ms.hubs == nil {
+			ms.hubs = make(map[string]*Hub)
+		}
+		h = NewHub(defaultWatchBuffer)
+		ms.hubs[tenant] = h
+	}
+
+	return h
+}
+
+func (ms *MemStorage) watch(tenant string, filter WatchFilter) (*Subscription, error) {
+	ms.mu.Lock()
+	hub := ms.tenantHub(tenant, true)
+	ms.mu.Unlock()
+
+	return hub.Subscribe(filter)
+}
+
+// snapshot — формат файла хранилища. Метрики DefaultTenant остаются на верхнем
+// уровне, чтобы старые файлы читались без изменений.
+type snapshot struct {
+	Gauges   map[string]float64        `json:"gauges"`
+	Counters map[string]int64          `json:"counters"`
+	Tenants  map[string]*tenantMetrics `json:"tenants,omitempty"`
+	Silences json.RawMessage           `json:"silences,omitempty"`
+}
+
+func (ms *MemStorage) UnmarshalJSON(data []byte) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	result := snapshot{}
 
 	err := json.Unmarshal(data, &result)
 
 	ms.gauges = result.Gauges
 	ms.counters = result.Counters
+	ms.resetVersions()
+	ms.tenants = make(map[string]*tenantMetrics, len(result.Tenants))
+
+	for tenant, m := range result.Tenants {
+		if m == nil {
+			continue
+		}
+		if m.Gauges == nil {
+			m.Gauges = make(map[string]float64)
+		}
+		if m.Counters == nil {
+			m.Counters = make(map[string]int64)
+		}
+		ms.tenants[tenant] = m
+	}
+
+	if ms.silences != nil && len(result.Silences) > 0 {
+		err = errors.Join(err, ms.silences.UnmarshalJSON(result.Silences))
+	}
 
 	return err
 }
 
 func (ms *MemStorage) MarshalJSON() ([]byte, error) {
-	type dto struct {
-		Gauges   map[string]float64 `json:"gauges"`
-		Counters map[string]int64   `json:"counters"`
-	}
-
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	result := dto{
+	result := snapshot{
 		Gauges:   ms.gauges,
 		Counters: ms.counters,
+		Tenants:  ms.tenants,
+	}
+
+	if ms.silences != nil {
+		silences, err := ms.silences.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		result.Silences = silences
 	}
 
 	return json.Marshal(result)
 }
 
+// ReadFromFile восстанавливает метрики из файла. Метрики, не прошедшие проверку,
+// отбрасываются, а их список возвращается ошибкой ErrSkippedInvalid; остальные
+// остаются загруженными. Метрики с зарезервированными префиксами сохраняются:
+// префикс могли зарезервировать после записи снимка, а запрет касается только
+// новой записи, иначе следующий сброс снимка потерял бы эти данные.
 func (ms *MemStorage) ReadFromFile(filepath string) error {
 	data, err := os.ReadFile(filepath)
 
@@ -115,7 +440,70 @@ func (ms *MemStorage) ReadFromFile(filepath string) error {
 		return err
 	}
 
-	return json.Unmarshal(data, ms)
+	if err := json.Unmarshal(data, ms); err != nil {
+		return err
+	}
+
+	return ms.dropInvalid()
+}
+
+// SetValidator задаёт правила проверки метрик при восстановлении из файла.
+// Зарезервированные префиксы validator при восстановлении не применяются.
+func (ms *MemStorage) SetValidator(v *validation.Validator) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.validator = v
+}
+
+func (ms *MemStorage) dropInvalid() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var errs []error
+
+	check := func(tenant string, m *tenantMetrics) {
+		for name, value := range m.Gauges {
+			if err := errors.Join(ms.validator.Format(name), ms.validator.Gauge(value)); err != nil {
+				errs = append(errs, fmt.Errorf("tenant %q gauge %q: %w", tenant, name, err))
+				delete(m.Gauges, name)
+			}
+		}
+		for name := range m.Counters {
+			if err := ms.validator.Format(name); err != nil {
+				errs = append(errs, fmt.Errorf("tenant %q counter %q: %w", tenant, name, err))
+				delete(m.Counters, name)
+			}
+		}
+	}
+
+	check(DefaultTenant, ms.metrics(DefaultTenant, true))
+
+	for tenant, m := range ms.tenants {
+		if err := ValidateTenant(tenant); err != nil || tenant == DefaultTenant {
+			errs = append(errs, fmt.Errorf("skip tenant %q: %w", tenant, ErrInvalidTenant))
+			delete(ms.tenants, tenant)
+			continue
+		}
+		check(tenant, m)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrSkippedInvalid, errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// RestoreStatus переводит ошибку ReadFromFile в состояние проверки готовности
+// хранилища. Отсутствующий снимок (первый запуск) и отброшенные при проверке
+// метрики не мешают работе: остальные данные восстановлены.
+func RestoreStatus(err error) error {
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrSkippedInvalid) {
+		return nil
+	}
+
+	return err
 }
 
 func (ms *MemStorage) FlushToFile() error {
@@ -128,10 +516,10 @@ func (ms *MemStorage) FlushToFile() error {
 	return os.WriteFile(ms.filepath, data, 0644)
 }
 
-func (ms *MemStorage) saveDB(name string, value string) error {
+func (ms *MemStorage) saveDB(tenant, name, value string) error {
 	if ms.db != nil {
 		_, err := RetryDB(ms.maxRetry, 1*time.Second, 2*time.Second, func() (sql.Result, error) {
-			return ms.db.Exec("INSERT INTO metrics (name, value) VALUES ($1, $2)", name, value)
+			return ms.db.Exec("INSERT INTO metrics (tenant, name, value) VALUES ($1, $2, $3)", tenant, name, value)
 		})
 
 		if err != nil {
@@ -141,3 +529,58 @@ func (ms *MemStorage) saveDB(name string, value string) error {
 
 	return nil
 }
+
+// tenantStorage — представление MemStorage для одного арендатора.
+// Файл снимка общий, поэтому FlushToFile сохраняет всех арендаторов.
+type tenantStorage struct {
+	ms     *MemStorage
+	tenant string
+}
+
+func (ts *tenantStorage) GetCounter(key string) (int64, bool) {
+	return ts.ms.getCounter(ts.tenant, key)
+}
+
+func (ts *tenantStorage) GetGauge(key string) (float64, bool) {
+	return ts.ms.getGauge(ts.tenant, key)
+}
+
+func (ts *tenantStorage) UpdateGauge(name string, value float64) error {
+	return ts.ms.updateGauge(ts.tenant, name, value)
+}
+
+func (ts *tenantStorage) UpdateCounter(name string, delta int64) error {
+	return ts.ms.updateCounter(ts.tenant, name, delta)
+}
+
+func (ts *tenantStorage) FlushToFile() error {
+	return ts.ms.FlushToFile()
+}
+
+func (ts *tenantStorage) Watch(filter WatchFilter) (*Subscription, error) {
+	return ts.ms.watch(ts.tenant, filter)
+}
+
+func (ts *tenantStorage) List() []models.Metrics {
+	return ts.ms.list(ts.tenant)
+}
+
+func (ts *tenantStorage) Delete(mType, name string) bool {
+	return ts.ms.delete(ts.tenant, mType, name)
+}
+
+func (ts *tenantStorage) History(mType, name string) []models.Point {
+	return ts.ms.tenantHistory(ts.tenant, mType, name)
+}
+
+func (ts *tenantStorage) Version(mType, name string) (Version, bool) {
+	return ts.ms.version(ts.tenant, mType, name)
+}
+
+func (ts *tenantStorage) ListVersion() Version {
+	return ts.ms.listVersion(ts.tenant)
+}
+
+func (ts *tenantStorage) ForTenant(tenant string) Storage {
+	return ts.ms.ForTenant(tenant)
+}
@@ -1,12 +1,17 @@
 package service
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"os"
 	"reflect"
 	"sync"
 	"testing"
+
+	models "metrify/internal/model"
+	"metrify/internal/validation"
 )
 
 type memDTO struct {
@@ -159,6 +164,101 @@ func TestMemStorage_UpdateCounter(t *testing.T) {
 	}
 }
 
+func TestMemStorage_ListAndDelete(t *testing.T) {
+	ms := &MemStorage{
+		gauges:   make(map[string]float64),
+		counters: make(map[string]int64),
+	}
+
+	ms.UpdateGauge("b", 1.5)
+	ms.UpdateCounter("a", 2)
+	ms.UpdateGauge("a", 3)
+	ms.ForTenant("team").UpdateGauge("c", 4)
+
+	got := ms.List()
+	if len(got) != 3 {
+		t.Fatalf("List() returned %d metrics, want 3", len(got))
+	}
+	if got[0].ID != "a" || got[0].MType != models.Counter || got[1].MType != models.Gauge || got[2].ID != "b" {
+		t.Fatalf("List() is not sorted by id and type: %+v", got)
+	}
+
+	if !ms.Delete(models.Gauge, "b") {
+		t.Fatal("Delete() of existing gauge returned false")
+	}
+	if ms.Delete(models.Gauge, "b") {
+		t.Fatal("Delete() of missing gauge returned true")
+	}
+	if _, ok := ms.GetGauge("b"); ok {
+		t.Fatal("gauge b was not deleted")
+	}
+
+	team := ms.ForTenant("team")
+	if len(team.List()) != 1 || !team.Delete(models.Gauge, "c") || len(team.List()) != 0 {
+		t.Fatal("tenant metrics must be listed and deleted separately")
+	}
+}
+
+func TestMemStorage_Version(t *testing.T) {
+	ms := &MemStorage{
+		gauges:   make(map[string]float64),
+		counters: make(map[string]int64),
+	}
+
+	if _, ok := ms.Version(models.Gauge, "a"); ok {
+		t.Fatal("Version() of missing metric returned true")
+	}
+
+	ms.UpdateGauge("a", 1)
+	ms.UpdateCounter("b", 1)
+	a1, _ := ms.Version(models.Gauge, "a")
+	b1, _ := ms.Version(models.Counter, "b")
+	list1 := ms.ListVersion()
+
+	if b1.Seq <= a1.Seq || list1 != b1 {
+		t.Fatalf("versions must grow with each update: a=%v b=%v list=%v", a1, b1, list1)
+	}
+	if a1.Modified.IsZero() {
+		t.Fatal("Modified is not set")
+	}
+
+	ms.UpdateGauge("a", 2)
+	a2, _ := ms.Version(models.Gauge, "a")
+	if a2.Seq <= a1.Seq {
+		t.Fatalf("gauge version did not change: %v -> %v", a1, a2)
+	}
+	if b2, _ := ms.Version(models.Counter, "b"); b2 != b1 {
+		t.Fatalf("unrelated metric version changed: %v -> %v", b1, b2)
+	}
+
+	teamList := ms.ForTenant("team").ListVersion()
+	ms.ForTenant("team").UpdateGauge("c", 1)
+	if ms.ListVersion() != a2 {
+		t.Fatal("other tenant updates must not change the list version")
+	}
+	if ms.ForTenant("team").ListVersion() == teamList {
+		t.Fatal("tenant list version did not change")
+	}
+
+	ms.Delete(models.Gauge, "a")
+	if ms.ListVersion().Seq <= a2.Seq {
+		t.Fatal("delete must change the list version")
+	}
+
+	data, err := json.Marshal(ms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := ms.ListVersion()
+	if err := json.Unmarshal(data, ms); err != nil {
+		t.Fatal(err)
+	}
+	restored, ok := ms.Version(models.Counter, "b")
+	if !ok || restored.Seq <= before.Seq || ms.ListVersion() != restored {
+		t.Fatalf("restored metrics must get a new version: before=%v restored=%v", before, restored)
+	}
+}
+
 func TestMemStorage_MarshalJSON(t *testing.T) {
 	ms := &MemStorage{
 		gauges: map[string]float64{
@@ -256,6 +356,52 @@ func TestMemStorage_FlushToFile_and_ReadFromFile(t *testing.T) {
 	}
 }
 
+// rawPart — SnapshotPart, хранящий JSON как есть.
+type rawPart struct {
+	data json.RawMessage
+}
+
+func (p *rawPart) MarshalJSON() ([]byte, error) { return p.data, nil }
+
+func (p *rawPart) UnmarshalJSON(data []byte) error {
+	p.data = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+func TestMemStorage_SnapshotSilences(t *testing.T) {
+	path := t.TempDir() + "/snapshot.json"
+
+	ms := NewMemStorage(path, nil)
+	ms.SetSilences(&rawPart{data: json.RawMessage(`[{"id":"s1"}]`)})
+	if err := ms.UpdateGauge("load", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.FlushToFile(); err != nil {
+		t.Fatalf("FlushToFile() error: %v", err)
+	}
+
+	restored := &rawPart{}
+	ms2 := NewMemStorage(path, nil)
+	ms2.SetSilences(restored)
+	if err := ms2.ReadFromFile(path); err != nil {
+		t.Fatalf("ReadFromFile() error: %v", err)
+	}
+
+	var got bytes.Buffer
+	if err := json.Compact(&got, restored.data); err != nil || got.String() != `[{"id":"s1"}]` {
+		t.Errorf("silences after ReadFromFile = %s", restored.data)
+	}
+	if _, ok := ms2.GetGauge("load"); !ok {
+		t.Error("gauge was not restored")
+	}
+
+	// Хранилище без окон читает снимок с окнами.
+	ms3 := NewMemStorage(path, nil)
+	if err := ms3.ReadFromFile(path); err != nil {
+		t.Fatalf("ReadFromFile() without silences error: %v", err)
+	}
+}
+
 func TestMemStorage_saveDB_NoDB(t *testing.T) {
 	ms := &MemStorage{
 		gauges:   make(map[string]float64),
@@ -266,7 +412,54 @@ func TestMemStorage_saveDB_NoDB(t *testing.T) {
 		maxRetry: 3,
 	}
 
-	if err := ms.saveDB("metric", "42"); err != nil {
+	if err := ms.saveDB(DefaultTenant, "metric", "42"); err != nil {
 		t.Fatalf("saveDB() with nil db returned error: %v", err)
 	}
 }
+
+func TestMemStorage_ReadFromFile_DropsInvalid(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "memstorage-*.json")
+	if err != nil {
+		t.Fatalf("CreateTemp error: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	data := `{
+		"gauges": {"load": 0.5, "bad name": 1, "__sys": 2},
+		"counters": {"hits": 10, "": 1},
+		"tenants": {"team-a": {"gauges": {"ok": 1}}, "bad tenant": {"gauges": {"x": 1}}}
+	}`
+	if err := os.WriteFile(tmpFile.Name(), []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile error: %v", err)
+	}
+
+	ms := NewMemStorage(tmpFile.Name(), nil)
+	ms.SetValidator(validation.New("__"))
+
+	if err := ms.ReadFromFile(tmpFile.Name()); !errors.Is(err, ErrSkippedInvalid) {
+		t.Fatalf("ReadFromFile() error = %v, want ErrSkippedInvalid", err)
+	}
+
+	if _, ok := ms.GetGauge("load"); !ok {
+		t.Error("valid gauge was dropped")
+	}
+	if _, ok := ms.GetCounter("hits"); !ok {
+		t.Error("valid counter was dropped")
+	}
+	if _, ok := ms.GetGauge("bad name"); ok {
+		t.Error("invalid gauge was restored")
+	}
+	// Префикс, зарезервированный после записи снимка, не отбрасывает данные.
+	if _, ok := ms.GetGauge("__sys"); !ok {
+		t.Error("gauge with reserved prefix was dropped")
+	}
+	if _, ok := ms.GetCounter(""); ok {
+		t.Error("counter with empty name was restored")
+	}
+	if _, ok := ms.ForTenant("team-a").GetGauge("ok"); !ok {
+		t.Error("valid tenant metric was dropped")
+	}
+	if _, ok := ms.tenants["bad tenant"]; ok {
+		t.Error("invalid tenant was restored")
+	}
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+var (
+	ErrNoClientIP      = errors.New("client ip is unknown")
+	ErrInvalidClientIP = errors.New("invalid client ip")
+	ErrUntrustedIP     = errors.New("agent ip is not in trusted subnet")
+)
+
+// TrustedSubnet пропускает только клиентов из заданных подсетей и определяет
+// адрес клиента одинаково для HTTP и gRPC:
+//   - если соединение пришло от доверенного прокси, адрес берётся из X-Forwarded-For:
+//     справа налево пропускаются доверенные прокси, первый чужой адрес — клиент;
+//   - X-Real-IP принимается только от доверенных прокси: от остальных клиентов
+//     он совпадает с адресом соединения или подделан;
+//   - иначе используется адрес соединения.
+//
+// Нулевой *TrustedSubnet пропускает всех.
+type TrustedSubnet struct {
+	subnets []*net.IPNet
+	proxies []*net.IPNet
+}
+
+// NewTrustedSubnet разбирает списки CIDR через запятую. Пустой subnets выключает проверку.
+func NewTrustedSubnet(subnets, proxies string) (*TrustedSubnet, error) {
+	s, err := ParseCIDRs(subnets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted subnet: %w", err)
+	}
+
+	p, err := ParseCIDRs(proxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+
+	return &TrustedSubnet{subnets: s, proxies: p}, nil
+}
+
+// ParseCIDRs разбирает список подсетей через запятую. Одиночный адрес
+// считается подсетью из одного адреса.
+func ParseCIDRs(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is neither an ip nor a cidr", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+
+	return nets, nil
+}
+
+// Enabled сообщает, ограничен ли доступ подсетями.
+func (t *TrustedSubnet) Enabled() bool {
+	return t != nil && len(t.subnets) > 0
+}
+
+// ClientIP определяет адрес клиента по адресу соединения remote (host:port или ip)
+// и заголовкам X-Real-IP и X-Forwarded-For.
+func (t *TrustedSubnet) ClientIP(remote, realIP, forwardedFor string) (net.IP, error) {
+	peer := parseHost(remote)
+	fromProxy := peer != nil && t.isProxy(peer)
+
+	if fromProxy && strings.TrimSpace(forwardedFor) != "" {
+		hops := strings.Split(forwardedFor, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				return nil, fmt.Errorf("%w in X-Forwarded-For: %q", ErrInvalidClientIP, strings.TrimSpace(hops[i]))
+			}
+			if i == 0 || !t.isProxy(ip) {
+				return ip, nil
+			}
+		}
+	}
+
+	if realIP = strings.TrimSpace(realIP); realIP != "" && fromProxy {
+		ip := net.ParseIP(realIP)
+		if ip == nil {
+			return nil, fmt.Errorf("%w in X-Real-IP: %q", ErrInvalidClientIP, realIP)
+		}
+		return ip, nil
+	}
+
+	if peer == nil {
+		return nil, ErrNoClientIP
+	}
+
+	return peer, nil
+}
+
+// PeerIP определяет адрес клиента для лимитов запросов: как ClientIP, но без
+// ошибок — без доверенного прокси или с неверными заголовками возвращается
+// адрес соединения без порта.
+func (t *TrustedSubnet) PeerIP(remote, realIP, forwardedFor string) string {
+	peer := parseHost(remote)
+	if peer == nil {
+		return hostOf(remote)
+	}
+
+	if t.isProxy(peer) {
+		if ip, err := t.ClientIP(remote, realIP, forwardedFor); err == nil {
+			return ip.String()
+		}
+	}
+
+	return peer.String()
+}
+
+// Check проверяет, что клиент входит в одну из доверенных подсетей.
+func (t *TrustedSubnet) Check(remote, realIP, forwardedFor string) error {
+	if !t.Enabled() {
+		return nil
+	}
+
+	ip, err := t.ClientIP(remote, realIP, forwardedFor)
+	if err != nil {
+		return err
+	}
+
+	if !contains(t.subnets, ip) {
+		return ErrUntrustedIP
+	}
+
+	return nil
+}
+
+func (t *TrustedSubnet) isProxy(ip net.IP) bool {
+	return t != nil && contains(t.proxies, ip)
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseHost(addr string) net.IP {
+	return net.ParseIP(hostOf(addr))
+}
+
+// hostOf отрезает порт от host:port.
+func hostOf(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewTrustedSubnet_Invalid(t *testing.T) {
+	if _, err := NewTrustedSubnet("not-a-cidr", ""); err == nil {
+		t.Fatal("expected error for invalid subnet")
+	}
+	if _, err := NewTrustedSubnet("10.0.0.0/8", "10.0.0.1/99"); err == nil {
+		t.Fatal("expected error for invalid proxy")
+	}
+}
+
+func TestTrustedSubnet_ClientIP(t *testing.T) {
+	withProxies, err := NewTrustedSubnet("10.0.0.0/8", "172.16.0.0/12, 192.168.0.1")
+	if err != nil {
+		t.Fatalf("NewTrustedSubnet() error = %v", err)
+	}
+	noProxies, err := NewTrustedSubnet("10.0.0.0/8", "")
+	if err != nil {
+		t.Fatalf("NewTrustedSubnet() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		subnet  *TrustedSubnet
+		remote  string
+		realIP  string
+		xff     string
+		want    string
+		wantErr error
+	}{
+		{name: "remote only", subnet: withProxies, remote: "10.1.1.1:5000", want: "10.1.1.1"},
+		{name: "headers ignored from untrusted peer", subnet: withProxies, remote: "8.8.8.8:5000", realIP: "10.1.1.1", xff: "10.1.1.1", want: "8.8.8.8"},
+		{name: "xff through proxy chain", subnet: withProxies, remote: "172.16.0.2:5000", xff: "1.1.1.1, 10.1.1.1, 192.168.0.1", want: "10.1.1.1"},
+		{name: "xff of proxies only", subnet: withProxies, remote: "172.16.0.2:5000", xff: "172.16.0.3", want: "172.16.0.3"},
+		{name: "real ip through proxy", subnet: withProxies, remote: "192.168.0.1:5000", realIP: "10.2.2.2", want: "10.2.2.2"},
+		{name: "invalid xff", subnet: withProxies, remote: "172.16.0.2:5000", xff: "nope", wantErr: ErrInvalidClientIP},
+		{name: "real ip ignored without proxies", subnet: noProxies, remote: "8.8.8.8:5000", realIP: "10.1.1.1", want: "8.8.8.8"},
+		{name: "xff ignored without proxies", subnet: noProxies, remote: "8.8.8.8:5000", xff: "10.1.1.1", want: "8.8.8.8"},
+		{name: "invalid real ip", subnet: withProxies, remote: "192.168.0.1:5000", realIP: "nope", wantErr: ErrInvalidClientIP},
+		{name: "real ip without peer", subnet: noProxies, realIP: "10.1.1.1", wantErr: ErrNoClientIP},
+		{name: "nothing known", subnet: noProxies, wantErr: ErrNoClientIP},
+		{name: "nil subnet", remote: "8.8.8.8:5000", realIP: "10.1.1.1", want: "8.8.8.8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := tt.subnet.ClientIP(tt.remote, tt.realIP, tt.xff)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClientIP() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && ip.String() != tt.want {
+				t.Fatalf("ClientIP() = %s, want %s", ip, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedSubnet_PeerIP(t *testing.T) {
+	withProxies, err := NewTrustedSubnet("", "172.16.0.0/12")
+	if err != nil {
+		t.Fatalf("NewTrustedSubnet() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		subnet *TrustedSubnet
+		remote string
+		realIP string
+		xff    string
+		want   string
+	}{
+		{name: "real ip ignored without proxies", subnet: &TrustedSubnet{}, remote: "8.8.8.8:5000", realIP: "10.1.1.1", want: "8.8.8.8"},
+		{name: "nil subnet", remote: "8.8.8.8:5000", realIP: "10.1.1.1", want: "8.8.8.8"},
+		{name: "headers from untrusted peer", subnet: withProxies, remote: "8.8.8.8:5000", realIP: "10.1.1.1", xff: "10.1.1.1", want: "8.8.8.8"},
+		{name: "xff through trusted proxy", subnet: withProxies, remote: "172.16.0.2:5000", xff: "10.1.1.1", want: "10.1.1.1"},
+		{name: "real ip through trusted proxy", subnet: withProxies, remote: "172.16.0.2:5000", realIP: "10.2.2.2", want: "10.2.2.2"},
+		{name: "invalid header from proxy", subnet: withProxies, remote: "172.16.0.2:5000", realIP: "nope", want: "172.16.0.2"},
+		{name: "unparsable remote without port", subnet: withProxies, remote: "pipe:5000", realIP: "10.1.1.1", want: "pipe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.subnet.PeerIP(tt.remote, tt.realIP, tt.xff); got != tt.want {
+				t.Fatalf("PeerIP() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustedSubnet_Check(t *testing.T) {
+	subnet, err := NewTrustedSubnet("10.0.0.0/8,192.168.5.5", "")
+	if err != nil {
+		t.Fatalf("NewTrustedSubnet() error = %v", err)
+	}
+
+	if err := subnet.Check("10.3.3.3:1", "", ""); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if err := subnet.Check("192.168.5.5:1", "", ""); err != nil {
+		t.Fatalf("Check() single address error = %v", err)
+	}
+	if err := subnet.Check("192.168.5.6:1", "", ""); !errors.Is(err, ErrUntrustedIP) {
+		t.Fatalf("Check() error = %v, want ErrUntrustedIP", err)
+	}
+
+	var disabled *TrustedSubnet
+	if err := disabled.Check("", "", ""); err != nil {
+		t.Fatalf("nil subnet must allow everything, got %v", err)
+	}
+}
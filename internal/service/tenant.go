@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultTenant — арендатор для запросов без токена с арендатором и без X-Tenant-ID.
+// Его метрики хранятся там же, где до появления арендаторов.
+const DefaultTenant = "default"
+
+// TenantHeader — заголовок с арендатором для клиентов, чей токен не привязан к арендатору.
+const TenantHeader = "X-Tenant-ID"
+
+var (
+	ErrInvalidTenant = errors.New("invalid tenant name")
+	ErrQuotaExceeded = errors.New("metric quota exceeded")
+)
+
+var tenantRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func ValidateTenant(tenant string) error {
+	if !tenantRe.MatchString(tenant) {
+		return fmt.Errorf("%w %q (expect [a-zA-Z0-9_-]{1,64})", ErrInvalidTenant, tenant)
+	}
+
+	return nil
+}
+
+// Quotas ограничивает число метрик у арендатора. Ноль означает отсутствие ограничения.
+type Quotas struct {
+	Default   int
+	PerTenant map[string]int
+}
+
+// ParseQuotas разбирает переопределения вида "team-a=1000,team-b=50".
+func ParseQuotas(defaultLimit int, overrides string) (Quotas, error) {
+	q := Quotas{Default: defaultLimit, PerTenant: map[string]int{}}
+
+	for _, item := range strings.Split(overrides, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		tenant, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return Quotas{}, fmt.Errorf("invalid tenant quota %q (expect tenant=limit)", item)
+		}
+
+		tenant = strings.TrimSpace(tenant)
+		if err := ValidateTenant(tenant); err != nil {
+			return Quotas{}, err
+		}
+
+		limit, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || limit < 0 {
+			return Quotas{}, fmt.Errorf("invalid tenant quota %q: limit must be a non-negative integer", item)
+		}
+
+		q.PerTenant[tenant] = limit
+	}
+
+	return q, nil
+}
+
+// Limit возвращает максимальное число метрик арендатора.
+func (q Quotas) Limit(tenant string) int {
+	if limit, ok := q.PerTenant[tenant]; ok {
+		return limit
+	}
+
+	return q.Default
+}
+
+type tenantKey struct{}
+
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext возвращает арендатора запроса или DefaultTenant.
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+
+	return DefaultTenant
+}
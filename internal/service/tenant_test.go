@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseQuotas(t *testing.T) {
+	q, err := ParseQuotas(10, "team-a=100, team-b=0")
+	if err != nil {
+		t.Fatalf("ParseQuotas() error = %v", err)
+	}
+
+	for tenant, want := range map[string]int{"team-a": 100, "team-b": 0, "team-c": 10} {
+		if got := q.Limit(tenant); got != want {
+			t.Errorf("Limit(%q) = %d, want %d", tenant, got, want)
+		}
+	}
+
+	for _, bad := range []string{"team-a", "team-a=x", "team-a=-1", "team a=1"} {
+		if _, err := ParseQuotas(0, bad); err == nil {
+			t.Errorf("ParseQuotas(%q) expected error", bad)
+		}
+	}
+}
+
+func TestTenantFromContext(t *testing.T) {
+	if got := TenantFromContext(context.Background()); got != DefaultTenant {
+		t.Fatalf("TenantFromContext() = %q, want %q", got, DefaultTenant)
+	}
+	if got := TenantFromContext(WithTenant(context.Background(), "team-a")); got != "team-a" {
+		t.Fatalf("TenantFromContext() = %q, want team-a", got)
+	}
+}
+
+func TestMemStorage_ForTenant(t *testing.T) {
+	ms := NewMemStorage("", nil)
+	a := ms.ForTenant("team-a")
+	b := ms.ForTenant("team-b")
+
+	if err := a.UpdateGauge("load", 1); err != nil {
+		t.Fatalf("UpdateGauge() error = %v", err)
+	}
+	if err := b.UpdateGauge("load", 2); err != nil {
+		t.Fatalf("UpdateGauge() error = %v", err)
+	}
+	if err := ms.UpdateCounter("hits", 3); err != nil {
+		t.Fatalf("UpdateCounter() error = %v", err)
+	}
+
+	if v, ok := a.GetGauge("load"); !ok || v != 1 {
+		t.Errorf("team-a load = %v, %v, want 1", v, ok)
+	}
+	if v, ok := b.GetGauge("load"); !ok || v != 2 {
+		t.Errorf("team-b load = %v, %v, want 2", v, ok)
+	}
+	if _, ok := ms.GetGauge("load"); ok {
+		t.Error("default tenant must not see team metrics")
+	}
+	if _, ok := a.GetCounter("hits"); ok {
+		t.Error("team-a must not see default tenant metrics")
+	}
+	if ms.ForTenant(DefaultTenant) != Storage(ms) {
+		t.Error("ForTenant(default) must return the storage itself")
+	}
+}
+
+func TestMemStorage_TenantWatch(t *testing.T) {
+	ms := NewMemStorage("", nil)
+
+	sub, err := ms.ForTenant("team-a").Watch(WatchFilter{})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer sub.Close()
+
+	_ = ms.ForTenant("team-b").UpdateGauge("load", 2)
+	_ = ms.ForTenant("team-a").UpdateGauge("load", 1)
+
+	if m := <-sub.Updates(); *m.Value != 1 {
+		t.Fatalf("got update %v, want only team-a value 1", *m.Value)
+	}
+}
+
+func TestMemStorage_Quota(t *testing.T) {
+	ms := NewMemStorage("", nil)
+	ms.SetQuotas(Quotas{Default: 2, PerTenant: map[string]int{"big": 0}})
+
+	small := ms.ForTenant("small")
+	if err := small.UpdateGauge("a", 1); err != nil {
+		t.Fatalf("UpdateGauge() error = %v", err)
+	}
+	if err := small.UpdateCounter("b", 1); err != nil {
+		t.Fatalf("UpdateCounter() error = %v", err)
+	}
+	if err := small.UpdateGauge("c", 1); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("UpdateGauge() error = %v, want ErrQuotaExceeded", err)
+	}
+	if _, ok := small.GetGauge("c"); ok {
+		t.Fatal("metric over quota must not be stored")
+	}
+	// Существующие метрики обновляются и после исчерпания квоты.
+	if err := small.UpdateCounter("b", 1); err != nil {
+		t.Fatalf("UpdateCounter() on existing metric error = %v", err)
+	}
+
+	big := ms.ForTenant("big")
+	for _, name := range []string{"a", "b", "c"} {
+		if err := big.UpdateGauge(name, 1); err != nil {
+			t.Fatalf("UpdateGauge(%q) for unlimited tenant error = %v", name, err)
+		}
+	}
+}
+
+func TestMemStorage_TenantSnapshot(t *testing.T) {
+	ms := NewMemStorage("", nil)
+	_ = ms.UpdateGauge("load", 0.5)
+	_ = ms.ForTenant("team-a").UpdateCounter("hits", 7)
+
+	data, err := json.Marshal(ms)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	restored := NewMemStorage("", nil)
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if v, ok := restored.GetGauge("load"); !ok || v != 0.5 {
+		t.Errorf("default load = %v, %v, want 0.5", v, ok)
+	}
+	if v, ok := restored.ForTenant("team-a").GetCounter("hits"); !ok || v != 7 {
+		t.Errorf("team-a hits = %v, %v, want 7", v, ok)
+	}
+	if _, ok := restored.GetCounter("hits"); ok {
+		t.Error("team-a metrics leaked into default tenant after restore")
+	}
+}
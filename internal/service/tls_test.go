@@ -0,0 +1,216 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "metrify test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue выпускает сертификат для localhost и записывает его вместе с ключом в dir.
+func (ca *testCA) issue(t *testing.T, dir, name string, serial int64, usage x509.ExtKeyUsage) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".crt")
+	keyFile := filepath.Join(dir, name+".key")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func (ca *testCA) write(t *testing.T, dir string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(path, ca.pem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestServerTLSConfig_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile := ca.write(t, dir)
+	serverCert, serverKey := ca.issue(t, dir, "server", 2, x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := ca.issue(t, dir, "client", 3, x509.ExtKeyUsageClientAuth)
+
+	serverCfg, err := ServerTLSConfig(serverCert, serverKey, caFile)
+	if err != nil {
+		t.Fatalf("ServerTLSConfig() error = %v", err)
+	}
+
+	// httptest.Server.StartTLS подставляет свой сертификат, поэтому слушатель собираем вручную.
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.Listener = tls.NewListener(ts.Listener, serverCfg)
+	ts.Start()
+	defer ts.Close()
+	ts.URL = "https://" + ts.Listener.Addr().String()
+
+	withCert, err := ClientTLSConfig(caFile, clientCert, clientKey)
+	if err != nil {
+		t.Fatalf("ClientTLSConfig() error = %v", err)
+	}
+
+	resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: withCert}}).Get(ts.URL)
+	if err != nil {
+		t.Fatalf("request with client certificate failed: %v", err)
+	}
+	resp.Body.Close()
+
+	withoutCert, err := ClientTLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatalf("ClientTLSConfig() error = %v", err)
+	}
+
+	if resp, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: withoutCert}}).Get(ts.URL); err == nil {
+		resp.Body.Close()
+		t.Fatal("expected handshake failure without client certificate")
+	}
+}
+
+func TestServerTLSConfig_Errors(t *testing.T) {
+	if _, err := ServerTLSConfig("", "", ""); err == nil {
+		t.Error("expected error without certificate")
+	}
+	if _, err := ServerTLSConfig("missing.crt", "missing.key", ""); err == nil {
+		t.Error("expected error for missing files")
+	}
+
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	cert, key := ca.issue(t, dir, "server", 2, x509.ExtKeyUsageServerAuth)
+	bogus := filepath.Join(dir, "bogus.pem")
+	_ = os.WriteFile(bogus, []byte("not a pem"), 0o600)
+
+	if _, err := ServerTLSConfig(cert, key, bogus); err == nil {
+		t.Error("expected error for invalid CA bundle")
+	}
+}
+
+func TestCertReloader_ReloadsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	certFile, keyFile := ca.issue(t, dir, "server", 10, x509.ExtKeyUsageServerAuth)
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("NewCertReloader() error = %v", err)
+	}
+	r.interval = 0
+
+	serial := func() int64 {
+		cert, _ := r.GetCertificate(&tls.ClientHelloInfo{})
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.Int64()
+	}
+
+	if got := serial(); got != 10 {
+		t.Fatalf("serial = %d, want 10", got)
+	}
+
+	ca.issue(t, dir, "server", 11, x509.ExtKeyUsageServerAuth)
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	_ = os.Chtimes(keyFile, future, future)
+
+	if got := serial(); got != 11 {
+		t.Fatalf("serial after reload = %d, want 11", got)
+	}
+
+	// Битый файл не должен ломать уже загруженный сертификат.
+	_ = os.WriteFile(certFile, []byte("garbage"), 0o600)
+	later := future.Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+
+	if got := serial(); got != 11 {
+		t.Fatalf("serial after broken write = %d, want 11", got)
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"time"
+
+	models "metrify/internal/model"
+)
+
+// Version — версия метрики или списка метрик арендатора для ETag и Last-Modified.
+// Seq растёт при каждом изменении хранилища и не повторяется после перезапуска,
+// так как начинается с времени запуска в наносекундах.
+type Version struct {
+	Seq      uint64
+	Modified time.Time
+}
+
+// touch отмечает изменение метрики и списка арендатора. Вызывается под ms.mu.
+func (ms *MemStorage) touch(tenant, mType, name string) {
+	v := ms.nextVersion()
+	if ms.versions == nil {
+		ms.versions = make(map[metricKey]Version)
+	}
+	ms.versions[metricKey{tenant, mType, name}] = v
+	ms.touchList(tenant, v)
+}
+
+// forget удаляет версию метрики; список арендатора при этом меняется. Вызывается под ms.mu.
+func (ms *MemStorage) forget(tenant, mType, name string) {
+	delete(ms.versions, metricKey{tenant, mType, name})
+	ms.touchList(tenant, ms.nextVersion())
+}
+
+func (ms *MemStorage) touchList(tenant string, v Version) {
+	if ms.lists == nil {
+		ms.lists = make(map[string]Version)
+	}
+	ms.lists[tenant] = v
+}
+
+// resetVersions вызывается при восстановлении: все метрики получают одну новую версию.
+// Вызывается под ms.mu.
+func (ms *MemStorage) resetVersions() {
+	ms.versions = nil
+	ms.lists = nil
+	ms.base = ms.nextVersion()
+}
+
+func (ms *MemStorage) nextVersion() Version {
+	now := time.Now()
+	if ms.seq == 0 {
+		ms.seq = uint64(now.UnixNano())
+	}
+	ms.seq++
+
+	return Version{Seq: ms.seq, Modified: now}
+}
+
+// baseVersion — версия метрик, не менявшихся с запуска или восстановления. Вызывается под ms.mu.
+func (ms *MemStorage) baseVersion() Version {
+	if ms.base.Seq == 0 {
+		ms.base = ms.nextVersion()
+	}
+
+	return ms.base
+}
+
+func (ms *MemStorage) version(tenant, mType, name string) (Version, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	m := ms.metrics(tenant, false)
+	if m == nil {
+		return Version{}, false
+	}
+
+	var exists bool
+	switch mType {
+	case models.Gauge:
+		_, exists = m.Gauges[name]
+	case models.Counter:
+		_, exists = m.Counters[name]
+	}
+	if !exists {
+		return Version{}, false
+	}
+
+	if v, ok := ms.versions[metricKey{tenant, mType, name}]; ok {
+		return v, true
+	}
+
+	return ms.baseVersion(), true
+}
+
+func (ms *MemStorage) listVersion(tenant string) Version {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if v, ok := ms.lists[tenant]; ok {
+		return v
+	}
+
+	return ms.baseVersion()
+}
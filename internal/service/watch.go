@@ -0,0 +1,157 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+
+	models "metrify/internal/model"
+)
+
+// ErrSlowConsumer возвращается подпиской, которую хаб отключил,
+// потому что она не успевала вычитывать обновления.
+var ErrSlowConsumer = errors.New("watcher is too slow, subscription dropped")
+
+const defaultWatchBuffer = 64
+
+type WatchFilter struct {
+	Pattern string
+	Types   []string
+}
+
+func (f WatchFilter) Validate() error {
+	if f.Pattern != "" {
+		if _, err := path.Match(f.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", f.Pattern, err)
+		}
+	}
+
+	for _, t := range f.Types {
+		if t != models.Gauge && t != models.Counter {
+			return fmt.Errorf("unknown metric type %q", t)
+		}
+	}
+
+	return nil
+}
+
+func (f WatchFilter) Match(m models.Metrics) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, m.MType) {
+		return false
+	}
+
+	if f.Pattern == "" {
+		return true
+	}
+
+	ok, _ := path.Match(f.Pattern, m.ID)
+
+	return ok
+}
+
+type Subscription struct {
+	hub     *Hub
+	filter  WatchFilter
+	updates chan models.Metrics
+	once    sync.Once
+	err     error
+}
+
+func (s *Subscription) Updates() <-chan models.Metrics {
+	return s.updates
+}
+
+// Err возвращает причину закрытия канала Updates: ErrSlowConsumer
+// или nil, если подписку закрыл сам клиент.
+func (s *Subscription) Err() error {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+
+	return s.err
+}
+
+func (s *Subscription) Close() {
+	s.hub.remove(s, nil)
+}
+
+// Hub рассылает обновления метрик подписчикам. Publish никогда не блокируется:
+// подписчик с заполненным буфером отключается с ErrSlowConsumer.
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[*Subscription]struct{}
+	buffer int
+}
+
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = defaultWatchBuffer
+	}
+
+	return &Hub{
+		subs:   make(map[*Subscription]struct{}),
+		buffer: buffer,
+	}
+}
+
+func (h *Hub) Subscribe(filter WatchFilter) (*Subscription, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		hub:     h,
+		filter:  filter,
+		updates: make(chan models.Metrics, h.buffer),
+	}
+
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+
+	return s, nil
+}
+
+func (h *Hub) Publish(m models.Metrics) {
+	if h == nil {
+		return
+	}
+
+	var lagging []*Subscription
+
+	h.mu.RLock()
+	for s := range h.subs {
+		if !s.filter.Match(m) {
+			continue
+		}
+
+		select {
+		case s.updates <- m:
+		default:
+			lagging = append(lagging, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range lagging {
+		h.remove(s, ErrSlowConsumer)
+	}
+}
+
+func (h *Hub) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subs)
+}
+
+func (h *Hub) remove(s *Subscription, reason error) {
+	s.once.Do(func() {
+		h.mu.Lock()
+		delete(h.subs, s)
+		s.err = reason
+		close(s.updates)
+		h.mu.Unlock()
+	})
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	models "metrify/internal/model"
+)
+
+func TestWatchFilter_Match(t *testing.T) {
+	v := 1.0
+	gauge := models.Metrics{ID: "CPUutilization1", MType: models.Gauge, Value: &v}
+	counter := models.Metrics{ID: "PollCount", MType: models.Counter}
+
+	tests := []struct {
+		name   string
+		filter WatchFilter
+		metric models.Metrics
+		want   bool
+	}{
+		{name: "empty filter", filter: WatchFilter{}, metric: gauge, want: true},
+		{name: "pattern match", filter: WatchFilter{Pattern: "CPU*"}, metric: gauge, want: true},
+		{name: "pattern mismatch", filter: WatchFilter{Pattern: "Heap*"}, metric: gauge, want: false},
+		{name: "type match", filter: WatchFilter{Types: []string{models.Counter}}, metric: counter, want: true},
+		{name: "type mismatch", filter: WatchFilter{Types: []string{models.Counter}}, metric: gauge, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(tt.metric); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchFilter_Validate(t *testing.T) {
+	if err := (WatchFilter{Pattern: "[", Types: nil}).Validate(); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+	if err := (WatchFilter{Types: []string{"histogram"}}).Validate(); err == nil {
+		t.Error("expected error for unknown type")
+	}
+	if err := (WatchFilter{Pattern: "Heap*", Types: []string{models.Gauge}}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestHub_PublishDeliversMatching(t *testing.T) {
+	h := NewHub(4)
+
+	sub, err := h.Subscribe(WatchFilter{Pattern: "Heap*"})
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+	defer sub.Close()
+
+	h.Publish(models.Metrics{ID: "Alloc", MType: models.Gauge})
+	h.Publish(models.Metrics{ID: "HeapAlloc", MType: models.Gauge})
+
+	select {
+	case m := <-sub.Updates():
+		if m.ID != "HeapAlloc" {
+			t.Fatalf("got %q, want HeapAlloc", m.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("update was not delivered")
+	}
+
+	select {
+	case m := <-sub.Updates():
+		t.Fatalf("unexpected update %q", m.ID)
+	default:
+	}
+}
+
+func TestHub_SlowConsumerIsDropped(t *testing.T) {
+	h := NewHub(1)
+
+	slow, _ := h.Subscribe(WatchFilter{})
+	fast, _ := h.Subscribe(WatchFilter{})
+	defer fast.Close()
+
+	h.Publish(models.Metrics{ID: "a", MType: models.Gauge})
+	<-fast.Updates()
+	h.Publish(models.Metrics{ID: "b", MType: models.Gauge})
+
+	if h.Len() != 1 {
+		t.Fatalf("subscribers = %d, want 1", h.Len())
+	}
+
+	if m := <-slow.Updates(); m.ID != "a" {
+		t.Fatalf("got %q, want buffered update a", m.ID)
+	}
+	if _, ok := <-slow.Updates(); ok {
+		t.Fatal("expected slow subscription channel to be closed")
+	}
+	if !errors.Is(slow.Err(), ErrSlowConsumer) {
+		t.Fatalf("Err() = %v, want ErrSlowConsumer", slow.Err())
+	}
+	if m := <-fast.Updates(); m.ID != "b" {
+		t.Fatalf("got %q, want b", m.ID)
+	}
+}
+
+func TestHub_Close(t *testing.T) {
+	h := NewHub(1)
+	sub, _ := h.Subscribe(WatchFilter{})
+
+	sub.Close()
+	sub.Close()
+
+	if _, ok := <-sub.Updates(); ok {
+		t.Fatal("expected channel to be closed")
+	}
+	if sub.Err() != nil {
+		t.Fatalf("Err() = %v, want nil", sub.Err())
+	}
+	if h.Len() != 0 {
+		t.Fatalf("subscribers = %d, want 0", h.Len())
+	}
+
+	h.Publish(models.Metrics{ID: "a"})
+}
+
+func TestMemStorage_WatchPublishesCounterTotal(t *testing.T) {
+	ms := NewMemStorage("", nil)
+
+	sub, err := ms.Watch(WatchFilter{Types: []string{models.Counter}})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+	defer sub.Close()
+
+	_ = ms.UpdateGauge("load", 1.5)
+	_ = ms.UpdateCounter("hits", 2)
+	_ = ms.UpdateCounter("hits", 3)
+
+	<-sub.Updates()
+	m := <-sub.Updates()
+
+	if m.ID != "hits" || m.Delta == nil || *m.Delta != 5 {
+		t.Fatalf("got %+v, want hits with total 5", m)
+	}
+}
+
+func TestMemStorage_WatchConcurrentCounterOrder(t *testing.T) {
+	const writers, perWriter = 4, 16 // вместе ровно defaultWatchBuffer обновлений
+
+	ms := NewMemStorage("", nil)
+
+	for round := range 200 {
+		name := fmt.Sprintf("hits%d", round)
+		sub, err := ms.Watch(WatchFilter{Pattern: name})
+		if err != nil {
+			t.Fatalf("Watch() error = %v", err)
+		}
+
+		var wg sync.WaitGroup
+		for range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range perWriter {
+					_ = ms.UpdateCounter(name, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		var last int64
+		for range writers * perWriter {
+			m := <-sub.Updates()
+			if *m.Delta <= last {
+				t.Fatalf("round %d: total %d after %d", round, *m.Delta, last)
+			}
+			last = *m.Delta
+		}
+		sub.Close()
+
+		if last != writers*perWriter {
+			t.Fatalf("round %d: last total %d, want %d", round, last, writers*perWriter)
+		}
+	}
+}
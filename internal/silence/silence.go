@@ -0,0 +1,303 @@
+// Package silence хранит окна обслуживания (silences).
+//
+// Окно задаёт селектор языка /query (Matchers) и интервал [StartsAt, EndsAt).
+// Пока окно активно, подходящие метрики помечаются в ответах на чтение как
+// silenced, а notify не отправляет уведомления по подходящим алертам. Алерт
+// сопоставляется по своим меткам вместе с alertname, severity и tenant, поэтому
+// селектор по имени метрики не задевает алерты агрегаций без метки __name__.
+// Окна сохраняются в снимке хранилища рядом с метриками.
+package silence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	models "metrify/internal/model"
+	"metrify/internal/query"
+	"metrify/internal/service"
+)
+
+// DefaultRetention — сколько закончившиеся окна остаются в списке.
+const DefaultRetention = 24 * time.Hour
+
+var (
+	ErrInvalidSilence = errors.New("invalid silence")
+	ErrNotFound       = errors.New("silence not found")
+)
+
+// Store — окна обслуживания всех арендаторов. Нулевой *Store не содержит окон.
+type Store struct {
+	// Retention — сколько закончившиеся окна остаются в списке.
+	Retention time.Duration
+
+	mu       sync.RWMutex
+	silences map[string]*entry
+}
+
+type entry struct {
+	silence  models.Silence
+	selector *query.Selector
+}
+
+func NewStore() *Store {
+	return &Store{
+		Retention: DefaultRetention,
+		silences:  make(map[string]*entry),
+	}
+}
+
+// Create проверяет и сохраняет новое окно. Начало в прошлом или пустое
+// заменяется на now; ID, Tenant по умолчанию и UpdatedAt заполняются здесь.
+func (s *Store) Create(silence models.Silence, now time.Time) (models.Silence, error) {
+	selector, err := compile(silence.Matchers)
+	if err != nil {
+		return models.Silence{}, err
+	}
+
+	if silence.Tenant == "" {
+		silence.Tenant = service.DefaultTenant
+	}
+	if silence.StartsAt.IsZero() || silence.StartsAt.Before(now) {
+		silence.StartsAt = now
+	}
+	switch {
+	case !silence.EndsAt.After(silence.StartsAt):
+		return models.Silence{}, fmt.Errorf("%w: ends_at must be after starts_at and now", ErrInvalidSilence)
+	case silence.CreatedBy == "":
+		return models.Silence{}, fmt.Errorf("%w: created_by is required", ErrInvalidSilence)
+	case silence.Comment == "":
+		return models.Silence{}, fmt.Errorf("%w: comment is required", ErrInvalidSilence)
+	}
+
+	silence.ID = service.NewNonce()
+	silence.UpdatedAt = now
+	silence.Status = ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gc(now)
+	s.silences[silence.ID] = &entry{silence: silence, selector: selector}
+
+	return withStatus(silence, now), nil
+}
+
+// List возвращает окна арендатора, отсортированные по началу.
+// Пустой state означает все состояния.
+func (s *Store) List(tenant, state string, now time.Time) []models.Silence {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gc(now)
+
+	var result []models.Silence
+	for _, e := range s.silences {
+		if e.silence.Tenant != tenant {
+			continue
+		}
+		silence := withStatus(e.silence, now)
+		if state != "" && silence.Status != state {
+			continue
+		}
+		result = append(result, silence)
+	}
+
+	sortSilences(result)
+
+	return result
+}
+
+// Expire досрочно завершает окно арендатора. Уже закончившееся окно
+// возвращается без изменений.
+func (s *Store) Expire(tenant, id string, now time.Time) (models.Silence, error) {
+	if s == nil {
+		return models.Silence{}, ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.silences[id]
+	if !ok || e.silence.Tenant != tenant {
+		return models.Silence{}, fmt.Errorf("%w: %q", ErrNotFound, id)
+	}
+
+	if withStatus(e.silence, now).Status != models.SilenceExpired {
+		if e.silence.StartsAt.After(now) {
+			e.silence.StartsAt = now
+		}
+		e.silence.EndsAt = now
+		e.silence.UpdatedAt = now
+	}
+
+	return withStatus(e.silence, now), nil
+}
+
+// Silenced возвращает ID активного окна арендатора, под которое попадают
+// метки, или пустую строку. Из нескольких окон выбирается окно с меньшим ID.
+func (s *Store) Silenced(tenant string, labels map[string]string, now time.Time) string {
+	if s == nil {
+		return ""
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var id string
+	for _, e := range s.silences {
+		if e.silence.Tenant != tenant || !active(e.silence, now) || !e.selector.Match(labels) {
+			continue
+		}
+		if id == "" || e.silence.ID < id {
+			id = e.silence.ID
+		}
+	}
+
+	return id
+}
+
+// Version описывает, как окна арендатора влияют на ответы: Seq зависит от
+// набора активных окон (0 — активных нет), Modified — последнее создание,
+// завершение, начало или конец окна к моменту now.
+func (s *Store) Version(tenant string, now time.Time) service.Version {
+	var v service.Version
+	if s == nil {
+		return v
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var ids []string
+	for _, e := range s.silences {
+		if e.silence.Tenant != tenant {
+			continue
+		}
+		for _, t := range []time.Time{e.silence.UpdatedAt, e.silence.StartsAt, e.silence.EndsAt} {
+			if !t.After(now) && t.After(v.Modified) {
+				v.Modified = t
+			}
+		}
+		if active(e.silence, now) {
+			ids = append(ids, e.silence.ID)
+		}
+	}
+
+	if len(ids) > 0 {
+		sort.Strings(ids)
+		h := fnv.New64a()
+		for _, id := range ids {
+			h.Write([]byte(id))
+		}
+		v.Seq = h.Sum64()
+	}
+
+	return v
+}
+
+// ValidState сообщает, можно ли фильтровать окна по state.
+func ValidState(state string) bool {
+	switch state {
+	case "", models.SilencePending, models.SilenceActive, models.SilenceExpired:
+		return true
+	}
+
+	return false
+}
+
+// MarshalJSON сохраняет окна всех арендаторов для снимка хранилища.
+func (s *Store) MarshalJSON() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	silences := make([]models.Silence, 0, len(s.silences))
+	for _, e := range s.silences {
+		silences = append(silences, e.silence)
+	}
+	sortSilences(silences)
+
+	return json.Marshal(silences)
+}
+
+// UnmarshalJSON заменяет окна окнами из снимка. Окна без ID или с
+// некорректным селектором отбрасываются, чтобы не мешать восстановлению метрик.
+func (s *Store) UnmarshalJSON(data []byte) error {
+	var silences []models.Silence
+	if err := json.Unmarshal(data, &silences); err != nil {
+		return err
+	}
+
+	restored := make(map[string]*entry, len(silences))
+	for _, silence := range silences {
+		selector, err := compile(silence.Matchers)
+		if err != nil || silence.ID == "" {
+			continue
+		}
+		silence.Status = ""
+		restored[silence.ID] = &entry{silence: silence, selector: selector}
+	}
+
+	s.mu.Lock()
+	s.silences = restored
+	s.mu.Unlock()
+
+	return nil
+}
+
+// gc удаляет окна, закончившиеся раньше Retention. Вызывается под s.mu.
+func (s *Store) gc(now time.Time) {
+	for id, e := range s.silences {
+		if now.Sub(e.silence.EndsAt) > s.Retention {
+			delete(s.silences, id)
+		}
+	}
+}
+
+func compile(matchers string) (*query.Selector, error) {
+	e, err := query.Parse(matchers)
+	if err != nil {
+		return nil, fmt.Errorf("%w: matchers: %w", ErrInvalidSilence, err)
+	}
+
+	selector, ok := e.(*query.Selector)
+	if !ok {
+		return nil, fmt.Errorf("%w: matchers must be a selector like HeapAlloc*{host=\"web-1\"}, got %s", ErrInvalidSilence, e)
+	}
+
+	return selector, nil
+}
+
+func active(silence models.Silence, now time.Time) bool {
+	return !now.Before(silence.StartsAt) && now.Before(silence.EndsAt)
+}
+
+func withStatus(silence models.Silence, now time.Time) models.Silence {
+	switch {
+	case now.Before(silence.StartsAt):
+		silence.Status = models.SilencePending
+	case now.Before(silence.EndsAt):
+		silence.Status = models.SilenceActive
+	default:
+		silence.Status = models.SilenceExpired
+	}
+
+	return silence
+}
+
+func sortSilences(silences []models.Silence) {
+	sort.Slice(silences, func(i, j int) bool {
+		if !silences[i].StartsAt.Equal(silences[j].StartsAt) {
+			return silences[i].StartsAt.Before(silences[j].StartsAt)
+		}
+		return silences[i].ID < silences[j].ID
+	})
+}
@@ -0,0 +1,190 @@
+package silence
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	models "metrify/internal/model"
+	"metrify/internal/query"
+	"metrify/internal/service"
+)
+
+func newSilence(matchers string, start, end time.Time) models.Silence {
+	return models.Silence{Matchers: matchers, StartsAt: start, EndsAt: end, CreatedBy: "ops", Comment: "deploy"}
+}
+
+func labels(name string) map[string]string {
+	return query.Labels(models.Metrics{ID: name, MType: models.Gauge})
+}
+
+func TestStore_Create_Invalid(t *testing.T) {
+	now := time.Now()
+	s := NewStore()
+
+	tests := []struct {
+		name    string
+		silence models.Silence
+	}{
+		{name: "bad matchers", silence: newSilence("HeapAlloc{", now, now.Add(time.Hour))},
+		{name: "aggregation instead of selector", silence: newSilence("max(HeapAlloc)", now, now.Add(time.Hour))},
+		{name: "ends before start", silence: newSilence("HeapAlloc", now.Add(time.Hour), now.Add(time.Minute))},
+		{name: "ends in the past", silence: newSilence("HeapAlloc", now.Add(-time.Hour), now.Add(-time.Minute))},
+		{name: "no author", silence: models.Silence{Matchers: "HeapAlloc", EndsAt: now.Add(time.Hour), Comment: "deploy"}},
+		{name: "no comment", silence: models.Silence{Matchers: "HeapAlloc", EndsAt: now.Add(time.Hour), CreatedBy: "ops"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Create(tt.silence, now); !errors.Is(err, ErrInvalidSilence) {
+				t.Fatalf("Create() error = %v, want ErrInvalidSilence", err)
+			}
+		})
+	}
+
+	if got := s.List(service.DefaultTenant, "", now); len(got) != 0 {
+		t.Fatalf("invalid silences must not be stored: %+v", got)
+	}
+}
+
+func TestStore_Lifecycle(t *testing.T) {
+	now := time.Now()
+	s := NewStore()
+
+	web, err := s.Create(newSilence(`HeapAlloc*{host="web-1"}`, time.Time{}, now.Add(time.Hour)), now)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if web.ID == "" || web.Status != models.SilenceActive || !web.StartsAt.Equal(now) || web.Tenant != service.DefaultTenant {
+		t.Fatalf("unexpected created silence: %+v", web)
+	}
+
+	later, err := s.Create(newSilence("/Gc.*/", now.Add(time.Hour), now.Add(2*time.Hour)), now)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if later.Status != models.SilencePending {
+		t.Fatalf("future silence must be pending: %+v", later)
+	}
+
+	if id := s.Silenced(service.DefaultTenant, labels("HeapAlloc:web-1"), now); id != web.ID {
+		t.Fatalf("Silenced(HeapAlloc:web-1) = %q, want %q", id, web.ID)
+	}
+	if id := s.Silenced(service.DefaultTenant, labels("HeapAlloc:web-2"), now); id != "" {
+		t.Fatalf("HeapAlloc:web-2 must not be silenced, got %q", id)
+	}
+	if id := s.Silenced("team", labels("HeapAlloc:web-1"), now); id != "" {
+		t.Fatalf("silence must not leak to another tenant, got %q", id)
+	}
+	if id := s.Silenced(service.DefaultTenant, labels("GcSys"), now); id != "" {
+		t.Fatalf("pending silence must not apply, got %q", id)
+	}
+	if id := s.Silenced(service.DefaultTenant, labels("GcSys"), now.Add(90*time.Minute)); id != later.ID {
+		t.Fatalf("silence must apply once started, got %q", id)
+	}
+
+	if got := s.List(service.DefaultTenant, models.SilencePending, now); len(got) != 1 || got[0].ID != later.ID {
+		t.Fatalf("List(pending) = %+v", got)
+	}
+
+	expired, err := s.Expire(service.DefaultTenant, web.ID, now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("Expire() error = %v", err)
+	}
+	if expired.Status != models.SilenceExpired || !expired.EndsAt.Equal(now.Add(time.Minute)) {
+		t.Fatalf("unexpected expired silence: %+v", expired)
+	}
+	if id := s.Silenced(service.DefaultTenant, labels("HeapAlloc:web-1"), now.Add(time.Minute)); id != "" {
+		t.Fatalf("expired silence must not apply, got %q", id)
+	}
+
+	again, err := s.Expire(service.DefaultTenant, web.ID, now.Add(time.Hour))
+	if err != nil || !again.EndsAt.Equal(expired.EndsAt) {
+		t.Fatalf("expiring twice must be a no-op: %+v, %v", again, err)
+	}
+
+	if _, err := s.Expire("team", later.ID, now); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expire() from another tenant error = %v, want ErrNotFound", err)
+	}
+
+	pending, err := s.Expire(service.DefaultTenant, later.ID, now)
+	if err != nil || !pending.StartsAt.Equal(now) || pending.Status != models.SilenceExpired {
+		t.Fatalf("expiring a pending silence: %+v, %v", pending, err)
+	}
+
+	if got := s.List(service.DefaultTenant, "", now.Add(DefaultRetention+2*time.Minute)); len(got) != 0 {
+		t.Fatalf("expired silences must be dropped after retention: %+v", got)
+	}
+}
+
+func TestStore_Version(t *testing.T) {
+	now := time.Now()
+	s := NewStore()
+
+	if v := s.Version(service.DefaultTenant, now); v.Seq != 0 || !v.Modified.IsZero() {
+		t.Fatalf("empty store version = %+v", v)
+	}
+
+	created, _ := s.Create(newSilence("HeapAlloc", now, now.Add(time.Hour)), now)
+	active := s.Version(service.DefaultTenant, now)
+	if active.Seq == 0 || !active.Modified.Equal(now) {
+		t.Fatalf("active version = %+v", active)
+	}
+	if v := s.Version("team", now); v.Seq != 0 {
+		t.Fatalf("other tenant version = %+v", v)
+	}
+
+	_, _ = s.Expire(service.DefaultTenant, created.ID, now.Add(time.Minute))
+	expired := s.Version(service.DefaultTenant, now.Add(time.Minute))
+	if expired.Seq != 0 || !expired.Modified.Equal(now.Add(time.Minute)) {
+		t.Fatalf("version after expire = %+v", expired)
+	}
+}
+
+func TestStore_JSON(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	s := NewStore()
+
+	created, err := s.Create(newSilence("HeapAlloc*", now, now.Add(time.Hour)), now)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var broken []models.Silence
+	_ = json.Unmarshal(data, &broken)
+	broken = append(broken, models.Silence{ID: "bad", Matchers: "{", EndsAt: now.Add(time.Hour)})
+	data, _ = json.Marshal(broken)
+
+	restored := NewStore()
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	got := restored.List(service.DefaultTenant, "", now)
+	if len(got) != 1 || got[0].ID != created.ID || got[0].Comment != "deploy" || !got[0].EndsAt.Equal(created.EndsAt) {
+		t.Fatalf("restored silences = %+v, want %+v", got, created)
+	}
+	if id := restored.Silenced(service.DefaultTenant, labels("HeapAlloc"), now); id != created.ID {
+		t.Fatalf("restored silence must apply, got %q", id)
+	}
+}
+
+func TestStore_Nil(t *testing.T) {
+	var s *Store
+
+	if got := s.List(service.DefaultTenant, "", time.Now()); got != nil {
+		t.Fatalf("nil store List() = %+v", got)
+	}
+	if id := s.Silenced(service.DefaultTenant, labels("HeapAlloc"), time.Now()); id != "" {
+		t.Fatalf("nil store Silenced() = %q", id)
+	}
+	if _, err := s.Expire(service.DefaultTenant, "x", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("nil store Expire() error = %v", err)
+	}
+}
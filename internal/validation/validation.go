@@ -0,0 +1,129 @@
+// Package validation содержит общие правила проверки метрик для HTTP, gRPC
+// и восстановления из файла.
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	models "metrify/internal/model"
+)
+
+// MaxNameLength совпадает с размером колонки name в таблице metrics.
+const MaxNameLength = 100
+
+// ErrInvalidMetric оборачивает все ошибки проверки.
+var ErrInvalidMetric = errors.New("invalid metric")
+
+var nameRe = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.:-]*$`)
+
+// Validator проверяет метрики. Имена с зарезервированными префиксами
+// (например, служебные "__") клиентам запрещены. Нулевой *Validator
+// применяет правила без зарезервированных префиксов.
+type Validator struct {
+	reserved []string
+}
+
+func New(reservedPrefixes ...string) *Validator {
+	v := &Validator{}
+
+	for _, prefix := range reservedPrefixes {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			v.reserved = append(v.reserved, prefix)
+		}
+	}
+
+	return v
+}
+
+// Name проверяет имя метрики: формат (см. Format) и отсутствие
+// зарезервированного префикса.
+func (v *Validator) Name(id string) error {
+	if err := v.Format(id); err != nil {
+		return err
+	}
+
+	if v != nil {
+		for _, prefix := range v.reserved {
+			if strings.HasPrefix(id, prefix) {
+				return fmt.Errorf("%w: metric id %q uses reserved prefix %q", ErrInvalidMetric, id, prefix)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Format проверяет только формат имени: 1..MaxNameLength символов из
+// [a-zA-Z0-9_.:-]. Зарезервированные префиксы не учитываются — так
+// проверяются уже сохранённые метрики.
+func (v *Validator) Format(id string) error {
+	switch {
+	case id == "":
+		return fmt.Errorf("%w: metric id is empty", ErrInvalidMetric)
+	case len(id) > MaxNameLength:
+		return fmt.Errorf("%w: metric id is longer than %d characters", ErrInvalidMetric, MaxNameLength)
+	case !nameRe.MatchString(id):
+		return fmt.Errorf("%w: metric id %q contains characters outside [a-zA-Z0-9_.:-]", ErrInvalidMetric, id)
+	}
+
+	return nil
+}
+
+// Type проверяет тип метрики.
+func (v *Validator) Type(mType string) error {
+	if mType != models.Gauge && mType != models.Counter {
+		return fmt.Errorf("%w: unknown metric type %q (expect counter|gauge)", ErrInvalidMetric, mType)
+	}
+
+	return nil
+}
+
+// Gauge проверяет, что значение gauge конечно.
+func (v *Validator) Gauge(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: gauge value must be finite, got %v", ErrInvalidMetric, value)
+	}
+
+	return nil
+}
+
+// Query проверяет запрос значения метрики: имя и тип.
+func (v *Validator) Query(m models.Metrics) error {
+	if err := v.Name(m.ID); err != nil {
+		return err
+	}
+
+	return v.Type(m.MType)
+}
+
+// Metric проверяет метрику для записи: у gauge задан только конечный value,
+// у counter — только delta.
+func (v *Validator) Metric(m models.Metrics) error {
+	if err := v.Query(m); err != nil {
+		return err
+	}
+
+	switch m.MType {
+	case models.Gauge:
+		if m.Value == nil {
+			return fmt.Errorf("%w: gauge value is missing", ErrInvalidMetric)
+		}
+		if m.Delta != nil {
+			return fmt.Errorf("%w: gauge must not have delta", ErrInvalidMetric)
+		}
+		return v.Gauge(*m.Value)
+	default:
+		if m.Delta == nil {
+			return fmt.Errorf("%w: counter delta is missing", ErrInvalidMetric)
+		}
+		if m.Value != nil {
+			return fmt.Errorf("%w: counter must not have value", ErrInvalidMetric)
+		}
+	}
+
+	return nil
+}
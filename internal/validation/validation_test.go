@@ -0,0 +1,75 @@
+package validation
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	models "metrify/internal/model"
+)
+
+func TestValidator_Metric(t *testing.T) {
+	v := New("__", " sys. ", "")
+	value := 1.5
+	nan := math.NaN()
+	inf := math.Inf(1)
+	delta := int64(2)
+
+	tests := []struct {
+		name    string
+		metric  models.Metrics
+		wantErr bool
+	}{
+		{name: "gauge", metric: models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value}},
+		{name: "counter", metric: models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &delta}},
+		{name: "dotted name", metric: models.Metrics{ID: "http.requests:total-2", MType: models.Counter, Delta: &delta}},
+		{name: "empty id", metric: models.Metrics{MType: models.Gauge, Value: &value}, wantErr: true},
+		{name: "long id", metric: models.Metrics{ID: strings.Repeat("a", MaxNameLength+1), MType: models.Gauge, Value: &value}, wantErr: true},
+		{name: "bad charset", metric: models.Metrics{ID: "cpu load", MType: models.Gauge, Value: &value}, wantErr: true},
+		{name: "leading dot", metric: models.Metrics{ID: ".hidden", MType: models.Gauge, Value: &value}, wantErr: true},
+		{name: "reserved prefix", metric: models.Metrics{ID: "__internal", MType: models.Gauge, Value: &value}, wantErr: true},
+		{name: "trimmed reserved prefix", metric: models.Metrics{ID: "sys.uptime", MType: models.Gauge, Value: &value}, wantErr: true},
+		{name: "unknown type", metric: models.Metrics{ID: "Alloc", MType: "histogram", Value: &value}, wantErr: true},
+		{name: "gauge without value", metric: models.Metrics{ID: "Alloc", MType: models.Gauge, Delta: &delta}, wantErr: true},
+		{name: "gauge with delta", metric: models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &value, Delta: &delta}, wantErr: true},
+		{name: "counter without delta", metric: models.Metrics{ID: "PollCount", MType: models.Counter}, wantErr: true},
+		{name: "counter with value", metric: models.Metrics{ID: "PollCount", MType: models.Counter, Delta: &delta, Value: &value}, wantErr: true},
+		{name: "nan gauge", metric: models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &nan}, wantErr: true},
+		{name: "inf gauge", metric: models.Metrics{ID: "Alloc", MType: models.Gauge, Value: &inf}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Metric(tt.metric)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Metric() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidMetric) {
+				t.Fatalf("error %v does not wrap ErrInvalidMetric", err)
+			}
+		})
+	}
+}
+
+func TestValidator_Nil(t *testing.T) {
+	var v *Validator
+
+	if err := v.Name("__internal"); err != nil {
+		t.Fatalf("nil validator must not reserve prefixes, got %v", err)
+	}
+	if err := v.Query(models.Metrics{ID: "Alloc", MType: "histogram"}); err == nil {
+		t.Fatal("nil validator must still check the type")
+	}
+}
+
+func TestValidator_Format(t *testing.T) {
+	v := New("__")
+
+	if err := v.Format("__internal"); err != nil {
+		t.Fatalf("Format() must ignore reserved prefixes, got %v", err)
+	}
+	if err := v.Format("cpu load"); !errors.Is(err, ErrInvalidMetric) {
+		t.Fatalf("Format() error = %v, want ErrInvalidMetric", err)
+	}
+}
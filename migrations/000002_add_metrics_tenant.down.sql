@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS tenant_name_idx;
+ALTER TABLE metrics DROP COLUMN IF EXISTS tenant;